@@ -0,0 +1,146 @@
+// Package account ties login accounts to the people they act for. An
+// account's ID is the ID of the student or teacher record it belongs to,
+// so it is taken from the record whose email address was verified rather
+// than from the caller, and faculty who register themselves wait for an
+// admin's approval before they can log in.
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"data-access/store"
+)
+
+// StatusPending marks an account that an admin still has to approve.
+// Accounts without a status are active.
+const StatusPending = "pending"
+
+// Errors from RecordFor
+var (
+	ErrNoRecord  = errors.New("no record has this email address")
+	ErrAmbiguous = errors.New("more than one record has this email address")
+)
+
+var errNotPending = errors.New("account is not awaiting approval")
+
+// findByField returns the documents whose field equals value as given or
+// in lower case, the way one-time codes store addresses
+func findByField(repo store.Repository, field, value string) ([]map[string]interface{}, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	values := []string{value}
+	if lower := strings.ToLower(value); lower != value {
+		values = append(values, lower)
+	}
+	var docs []map[string]interface{}
+	seen := map[string]bool{}
+	for _, v := range values {
+		page, err := repo.Find(store.Query{Filters: map[string]string{field: v}, Limit: 2})
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range page.Docs {
+			if id, _ := doc["_id"].(string); !seen[id] {
+				seen[id] = true
+				docs = append(docs, doc)
+			}
+		}
+	}
+	return docs, nil
+}
+
+// FindByEmail returns the user account with the given email address
+func FindByEmail(users Repository, email string) (map[string]interface{}, error) {
+	docs, err := findByField(users, "email", email)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return docs[0], nil
+}
+
+// RecordFor returns the ID of the one student or teacher document in
+// records whose email_address is email
+func RecordFor(records store.Repository, email string) (string, error) {
+	docs, err := findByField(records, "email_address", email)
+	if err != nil {
+		return "", err
+	}
+	switch len(docs) {
+	case 0:
+		return "", ErrNoRecord
+	case 1:
+		id, _ := docs[0]["_id"].(string)
+		return id, nil
+	}
+	return "", ErrAmbiguous
+}
+
+// Pending reports whether an account document still needs approval
+func Pending(doc map[string]interface{}) bool {
+	return doc["status"] == StatusPending
+}
+
+// GetPending lists the accounts awaiting approval, without their password
+// hashes
+func GetPending(w http.ResponseWriter, r *http.Request, users Repository) {
+	accounts := []map[string]interface{}{}
+	query := store.Query{Filters: map[string]string{"status": StatusPending}, Limit: store.MaxLimit}
+	for {
+		page, err := users.Find(query)
+		if err != nil {
+			http.Error(w, "Failed to fetch accounts", http.StatusInternalServerError)
+			return
+		}
+		for _, doc := range page.Docs {
+			delete(doc, "password")
+			delete(doc, "_rev")
+			accounts = append(accounts, doc)
+		}
+		if page.Next == "" {
+			break
+		}
+		query.Cursor = page.Next
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(accounts)
+}
+
+// Approve lets a pending account log in (?id=..)
+func Approve(w http.ResponseWriter, r *http.Request, users Repository) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Account ID missing", http.StatusBadRequest)
+		return
+	}
+	_, err := store.Modify(users, id, func(doc map[string]interface{}) error {
+		if !Pending(doc) {
+			return errNotPending
+		}
+		delete(doc, "status")
+		return nil
+	})
+	switch err {
+	case nil:
+	case store.ErrNotFound:
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	case errNotPending:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, "Failed to approve account", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account approved"})
+}
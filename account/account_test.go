@@ -0,0 +1,68 @@
+package account
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"data-access/store"
+)
+
+func TestRecordFor(t *testing.T) {
+	records := store.NewMemory()
+	records.Create("s1", map[string]interface{}{"full_name": "Ann", "email_address": "ann@example.com"})
+	records.Create("s2", map[string]interface{}{"full_name": "Ben", "email_address": "twins@example.com"})
+	records.Create("s3", map[string]interface{}{"full_name": "Bea", "email_address": "twins@example.com"})
+
+	tests := []struct {
+		email string
+		id    string
+		err   error
+	}{
+		{"ann@example.com", "s1", nil},
+		{" Ann@Example.com ", "s1", nil},
+		{"twins@example.com", "", ErrAmbiguous},
+		{"nobody@example.com", "", ErrNoRecord},
+		{"", "", ErrNoRecord},
+	}
+	for _, tt := range tests {
+		id, err := RecordFor(records, tt.email)
+		if id != tt.id || err != tt.err {
+			t.Errorf("RecordFor(%q) = %q, %v; want %q, %v", tt.email, id, err, tt.id, tt.err)
+		}
+	}
+}
+
+func TestApprove(t *testing.T) {
+	users := NewMemoryRepository()
+	users.Create("t1", map[string]interface{}{"email": "ann@example.com", "password": "hash", "status": StatusPending})
+	users.Create("t2", map[string]interface{}{"email": "ben@example.com", "password": "hash"})
+
+	if doc, err := FindByEmail(users, "Ann@example.com"); err != nil || !Pending(doc) {
+		t.Fatalf("FindByEmail = %v, %v; want the pending account", doc, err)
+	}
+
+	w := httptest.NewRecorder()
+	GetPending(w, httptest.NewRequest("GET", "/accounts/pending", nil), users)
+	if body := w.Body.String(); w.Code != http.StatusOK || body != "[{\"_id\":\"t1\",\"email\":\"ann@example.com\",\"status\":\"pending\"}]\n" {
+		t.Errorf("GetPending = %d %s", w.Code, body)
+	}
+
+	approve := func(id string) int {
+		w := httptest.NewRecorder()
+		Approve(w, httptest.NewRequest("POST", "/accounts/approve?id="+id, nil), users)
+		return w.Code
+	}
+	if code := approve("t1"); code != http.StatusOK {
+		t.Fatalf("Approve = %d", code)
+	}
+	if doc, _ := users.Get("t1"); Pending(doc) {
+		t.Errorf("account still pending after approval: %v", doc)
+	}
+	if code := approve("t2"); code != http.StatusConflict {
+		t.Errorf("Approve of an active account = %d, want 409", code)
+	}
+	if code := approve("t9"); code != http.StatusNotFound {
+		t.Errorf("Approve of a missing account = %d, want 404", code)
+	}
+}
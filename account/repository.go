@@ -0,0 +1,36 @@
+package account
+
+import (
+	"log"
+
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding user accounts
+const DBName = "education_management"
+
+// Repository is the storage backend for user accounts
+type Repository = store.Repository
+
+// Index lets accounts be looked up by email and pending accounts be listed
+var Index = store.Index{
+	Filters: []string{"email", "status"},
+}
+
+// NewCouchRepository returns a Repository backed by the
+// education_management database, creating the views accounts are looked
+// up through
+func NewCouchRepository(client *couchdb.Client) Repository {
+	db := store.NewCouchDB(client, DBName)
+	if err := db.EnsureIndex(Index); err != nil {
+		log.Printf("Failed to create %s query views: %v", DBName, err)
+	}
+	return db
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
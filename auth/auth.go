@@ -0,0 +1,85 @@
+package auth
+
+import "context"
+
+// Role identifies what a signed-in user is allowed to do
+type Role string
+
+const (
+	Admin   Role = "admin"
+	Faculty Role = "faculty"
+	Student Role = "student"
+	Staff   Role = "staff"
+	Parent  Role = "parent"
+	Nurse   Role = "nurse"
+)
+
+// ParseRole validates a role name taken from a request or user document
+func ParseRole(s string) (Role, bool) {
+	switch role := Role(s); role {
+	case Admin, Faculty, Student, Staff, Parent, Nurse:
+		return role, true
+	}
+	return "", false
+}
+
+// Identity is the caller described by a verified JWT
+type Identity struct {
+	ID    string
+	Email string
+	Role  Role
+	// Students lists the student records a parent may see
+	Students []string
+	// Session is the login session the token was issued for
+	Session string
+}
+
+// Claims returns the JWT claims that carry the identity
+func (id Identity) Claims() map[string]interface{} {
+	claims := map[string]interface{}{
+		"id":    id.ID,
+		"email": id.Email,
+		"role":  string(id.Role),
+	}
+	if len(id.Students) > 0 {
+		claims["students"] = id.Students
+	}
+	if id.Session != "" {
+		claims["sid"] = id.Session
+	}
+	return claims
+}
+
+// FromClaims rebuilds an identity from verified JWT claims. Tokens issued
+// before roles existed come back with an empty Role and are denied by
+// every rule.
+func FromClaims(claims map[string]interface{}) Identity {
+	var id Identity
+	id.ID, _ = claims["id"].(string)
+	id.Email, _ = claims["email"].(string)
+	id.Session, _ = claims["sid"].(string)
+	if s, ok := claims["role"].(string); ok {
+		id.Role, _ = ParseRole(s)
+	}
+	if list, ok := claims["students"].([]interface{}); ok {
+		for _, v := range list {
+			if s, ok := v.(string); ok {
+				id.Students = append(id.Students, s)
+			}
+		}
+	}
+	return id
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the caller's identity
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity stored by NewContext
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
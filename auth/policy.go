@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+)
+
+// Rule describes who may call a route
+type Rule struct {
+	// Roles may call the route for any record
+	Roles []Role
+	// Self roles may only call the route for their own record, named by
+	// the "id" query parameter. Parents match any of their students.
+	Self []Role
+}
+
+// Allow returns a rule admitting the given roles
+func Allow(roles ...Role) Rule {
+	return Rule{Roles: roles}
+}
+
+// OrSelf extends a rule so the given roles may reach their own record
+func (rule Rule) OrSelf(roles ...Role) Rule {
+	rule.Self = append(append([]Role{}, rule.Self...), roles...)
+	return rule
+}
+
+// Permits reports whether the caller may make the request
+func (rule Rule) Permits(id Identity, r *http.Request) bool {
+	if hasRole(rule.Roles, id.Role) {
+		return true
+	}
+	if !hasRole(rule.Self, id.Role) {
+		return false
+	}
+	return id.Owns(r.URL.Query().Get("id"))
+}
+
+// Owns reports whether the record ID belongs to the caller
+func (id Identity) Owns(recordID string) bool {
+	if recordID == "" {
+		return false
+	}
+	if id.Role == Parent {
+		for _, s := range id.Students {
+			if s == recordID {
+				return true
+			}
+		}
+		return false
+	}
+	return id.ID == recordID
+}
+
+// Policy maps route names to the rule guarding them
+type Policy map[string]Rule
+
+// Authorize wraps next so that only callers permitted by the route's rule
+// reach it. It must run after the JWT has been verified; routes missing
+// from the policy are denied.
+func (p Policy) Authorize(route string, next http.Handler) http.Handler {
+	rule, ok := p[route]
+	if !ok {
+		log.Printf("no access policy for route %q, denying all callers", route)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := FromContext(r.Context())
+		if !ok || !rule.Permits(id, r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hasRole(roles []Role, role Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package checkin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"data-access/qrtoken"
+	"data-access/staff"
+	"data-access/store"
+	"data-access/student"
+	"data-access/teacher"
+)
+
+// DefaultWindow is how long after a check-in a repeat scan of the same
+// card is rejected as a duplicate
+const DefaultWindow = 5 * time.Minute
+
+// ErrDuplicate is returned when a person scans in again within the window
+var ErrDuplicate = errors.New("already checked in")
+
+// Directory resolves people across the student, teacher and staff stores
+type Directory struct {
+	Students store.Repository
+	Teachers store.Repository
+	Staff    store.Repository
+}
+
+// Repository returns the store holding people of the given kind, or nil
+func (d Directory) Repository(kind string) store.Repository {
+	switch kind {
+	case student.Kind:
+		return d.Students
+	case teacher.Kind:
+		return d.Teachers
+	case staff.Kind:
+		return d.Staff
+	}
+	return nil
+}
+
+// Resolve finds the person with the given ID. If kind is empty every store
+// is searched, students first.
+func (d Directory) Resolve(id, kind string) (string, store.Repository, error) {
+	for _, candidate := range []string{student.Kind, teacher.Kind, staff.Kind} {
+		if kind != "" && kind != candidate {
+			continue
+		}
+		repo := d.Repository(candidate)
+		_, err := repo.Get(id)
+		if err == nil {
+			return candidate, repo, nil
+		}
+		if err != store.ErrNotFound {
+			return "", nil, err
+		}
+	}
+	return "", nil, store.ErrNotFound
+}
+
+// record is one entry in a person's attendance_records list
+type record struct {
+	Date        string     `json:"date"`
+	Status      string     `json:"status"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// Record appends a timestamped attendance record to the person's document.
+// It returns ErrDuplicate if their previous check-in is within window.
+func Record(repo store.Repository, id string, at time.Time, window time.Duration) (map[string]interface{}, error) {
+	return store.Modify(repo, id, func(doc map[string]interface{}) error {
+		var person struct {
+			AttendanceRecords []json.RawMessage `json:"attendance_records"`
+		}
+		if err := store.Decode(doc, &person); err != nil {
+			return err
+		}
+		for _, raw := range person.AttendanceRecords {
+			var rec record
+			if json.Unmarshal(raw, &rec) != nil || rec.CheckedInAt == nil {
+				continue
+			}
+			if d := at.Sub(*rec.CheckedInAt); d >= 0 && d < window {
+				return ErrDuplicate
+			}
+		}
+
+		// Keep existing entries byte-for-byte; only the new one is ours
+		rec, _ := json.Marshal(record{Date: at.Format("2006-01-02"), Status: "present", CheckedInAt: &at})
+		doc["attendance_records"] = append(person.AttendanceRecords, rec)
+		return nil
+	})
+}
+
+// verifyToken reads {"token": ...} from the request and checks it,
+// writing the error response itself when the token is unusable
+func verifyToken(w http.ResponseWriter, r *http.Request, signer *qrtoken.Signer) (qrtoken.Claims, bool) {
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Token == "" {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return qrtoken.Claims{}, false
+	}
+	claims, err := signer.Verify(payload.Token, time.Now())
+	if err == qrtoken.ErrExpired {
+		http.Error(w, "QR code has expired", http.StatusUnauthorized)
+		return qrtoken.Claims{}, false
+	}
+	if err != nil {
+		http.Error(w, "invalid QR code", http.StatusUnauthorized)
+		return qrtoken.Claims{}, false
+	}
+	return claims, true
+}
+
+// CheckIn accepts the token read from a QR code scanned at the gate and
+// records the holder's attendance
+func CheckIn(w http.ResponseWriter, r *http.Request, dir Directory, signer *qrtoken.Signer, window time.Duration) {
+	claims, ok := verifyToken(w, r, signer)
+	if !ok {
+		return
+	}
+
+	kind, repo, err := dir.Resolve(claims.ID, claims.Kind)
+	if err == store.ErrNotFound {
+		http.Error(w, "no student, teacher or staff member with that ID", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to look up QR code holder", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	doc, err := Record(repo, claims.ID, now, window)
+	if err == ErrDuplicate {
+		http.Error(w, "already checked in", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to record check-in", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Check-in recorded",
+		"id":            claims.ID,
+		"kind":          kind,
+		"full_name":     doc["full_name"],
+		"checked_in_at": now.Format(time.RFC3339),
+	})
+}
+
+// VerifyQR checks a scanned QR token and reports who it belongs to without
+// recording anything
+func VerifyQR(w http.ResponseWriter, r *http.Request, dir Directory, signer *qrtoken.Signer) {
+	claims, ok := verifyToken(w, r, signer)
+	if !ok {
+		return
+	}
+
+	kind, repo, err := dir.Resolve(claims.ID, claims.Kind)
+	if err == store.ErrNotFound {
+		http.Error(w, "QR code holder no longer exists", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to look up QR code holder", http.StatusInternalServerError)
+		return
+	}
+	doc, err := repo.Get(claims.ID)
+	if err != nil {
+		http.Error(w, "failed to look up QR code holder", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"valid":      true,
+		"id":         claims.ID,
+		"kind":       kind,
+		"full_name":  doc["full_name"],
+		"issued_at":  time.Unix(claims.IssuedAt, 0).UTC().Format(time.RFC3339),
+		"expires_at": time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,37 @@
+package checkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"data-access/qrtoken"
+	"data-access/store"
+)
+
+func TestCheckInKind(t *testing.T) {
+	dir := Directory{Students: store.NewMemory(), Teachers: store.NewMemory(), Staff: store.NewMemory()}
+	dir.Students.Create("p1", map[string]interface{}{"full_name": "Ann"})
+	signer := qrtoken.NewSigner([]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+
+	tests := []struct {
+		kind string
+		code int
+	}{
+		{"teacher", http.StatusNotFound}, // a student's ID on a teacher's card
+		{"student", http.StatusOK},
+	}
+	for _, tt := range tests {
+		token, _, _ := signer.Sign("p1", tt.kind, time.Now())
+		w := httptest.NewRecorder()
+		CheckIn(w, httptest.NewRequest("POST", "/checkin", strings.NewReader(`{"token":"`+token+`"}`)), dir, signer, time.Minute)
+		if w.Code != tt.code {
+			t.Errorf("CheckIn with kind %s = %d %s, want %d", tt.kind, w.Code, w.Body, tt.code)
+		}
+	}
+	if doc, _ := dir.Students.Get("p1"); doc["attendance_records"] == nil {
+		t.Errorf("check-in not recorded: %v", doc)
+	}
+}
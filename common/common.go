@@ -3,23 +3,13 @@ package main
 import (
 	"log"
 	"time"
-)
 
-// Common fields for all staff and students
-type Person struct {
-	ID               string    `json:"id"`
-	FullName         string    `json:"full_name"`
-	DateOfBirth      time.Time `json:"date_of_birth"`
-	Gender           string    `json:"gender"`
-	Address          string    `json:"address"`
-	ContactNumber    string    `json:"contact_number"`
-	EmailAddress     string    `json:"email_address"`
-	EmergencyContact string    `json:"emergency_contact"`
-}
+	"data-access/domain"
+)
 
 // Student-specific fields
 type Student struct {
-	Person
+	domain.Person
 	Class                     string             `json:"class"`
 	Section                   string             `json:"section"`
 	RollNumber                string             `json:"roll_number"`
@@ -37,7 +27,7 @@ type Student struct {
 
 // Teacher-specific fields
 type Teacher struct {
-	Person
+	domain.Person
 	SubjectsTaught      []string             `json:"subjects_taught"`
 	ClassAssigned       string               `json:"class_assigned"`
 	Qualifications      []string             `json:"qualifications"`
@@ -53,38 +43,6 @@ type Teacher struct {
 	DisciplinaryRecords []DisciplinaryRecord `json:"disciplinary_records"`
 }
 
-// Cook staff-specific fields
-type CookStaff struct {
-	Person
-	JobTitle            string               `json:"job_title"`
-	Qualifications      []string             `json:"qualifications"`
-	YearsOfExperience   int                  `json:"years_of_experience"`
-	PreviousEmployment  []EmploymentRecord   `json:"previous_employment"`
-	Certifications      []string             `json:"certifications"`
-	AttendanceRecords   []AttendanceRecord   `json:"attendance_records"`
-	PerformanceReviews  []PerformanceReview  `json:"performance_reviews"`
-	DateOfJoining       time.Time            `json:"date_of_joining"`
-	SalaryDetails       Salary               `json:"salary_details"`
-	LeaveRecords        []LeaveRecord        `json:"leave_records"`
-	DisciplinaryRecords []DisciplinaryRecord `json:"disciplinary_records"`
-}
-
-// Cleaning staff-specific fields
-type CleaningStaff struct {
-	Person
-	JobTitle            string               `json:"job_title"`
-	Qualifications      []string             `json:"qualifications"`
-	YearsOfExperience   int                  `json:"years_of_experience"`
-	PreviousEmployment  []EmploymentRecord   `json:"previous_employment"`
-	Certifications      []string             `json:"certifications"`
-	AttendanceRecords   []AttendanceRecord   `json:"attendance_records"`
-	PerformanceReviews  []PerformanceReview  `json:"performance_reviews"`
-	DateOfJoining       time.Time            `json:"date_of_joining"`
-	SalaryDetails       Salary               `json:"salary_details"`
-	LeaveRecords        []LeaveRecord        `json:"leave_records"`
-	DisciplinaryRecords []DisciplinaryRecord `json:"disciplinary_records"`
-}
-
 // Supporting structures
 type AttendanceRecord struct {
 	Date   time.Time `json:"date"`
@@ -161,10 +119,10 @@ type DisciplinaryRecord struct {
 func main() {
 	// Example usage
 	student := Student{
-		Person: Person{
+		Person: domain.Person{
 			ID:               "1",
 			FullName:         "John Doe",
-			DateOfBirth:      time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC),
+			DateOfBirth:      domain.Date{Time: time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC)},
 			Gender:           "Male",
 			Address:          "123 Main St",
 			ContactNumber:    "123-456-7890",
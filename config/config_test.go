@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want map[string]string
+		err  string
+	}{
+		{"empty", "# nothing set\n\n", map[string]string{}, ""},
+		{"values", "addr = \":9000\" # main server\n\n[smtp]\nhost = \"mail.example.com\"\nport = 25\n",
+			map[string]string{"addr": ":9000", "smtp.host": "mail.example.com", "smtp.port": "25"}, ""},
+		{"hash in string", `jwt_secret = "a#b\"#c" # comment`, map[string]string{"jwt_secret": `a#b"#c`}, ""},
+		{"malformed section", "[smtp\nhost = \"x\"", nil, ":1: malformed section header"},
+		{"no equals", "addr\n", nil, ":1: expected key = value"},
+		{"unquoted string", "\naddr = localhost", nil, ":2: addr: value must be a quoted string or an integer"},
+		{"set twice", "addr = \":1\"\naddr = \":2\"", nil, ":2: addr set twice"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		os.WriteFile(path, []byte(tt.file), 0o600)
+		got, err := readFile(path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: readFile error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || len(got) != len(tt.want) {
+			t.Errorf("%s: readFile = %v, %v; want %v", tt.name, got, err, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %q, want %q", tt.name, k, got[k], v)
+			}
+		}
+	}
+	if _, err := readFile(filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Errorf("readFile of a missing file succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := strings.Repeat("s", MinSecretLength)
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		err    string
+	}{
+		{"defaults with a secret", func(c *Config) {}, ""},
+		{"short secret", func(c *Config) { c.JWTSecret = "short" }, "jwt_secret"},
+		{"short student secret", func(c *Config) { c.NewStudentJWTSecret = "short" }, "new_student_jwt_secret (NEW_STUDENT_JWT_SECRET) must be at least"},
+		{"shared student secret", func(c *Config) { c.NewStudentJWTSecret = secret }, "must differ from jwt_secret"},
+		{"couchdb scheme", func(c *Config) { c.CouchDBURL = "ftp://localhost/" }, "couchdb_url"},
+		{"couchdb host", func(c *Config) { c.CouchDBURL = "http:///" }, "couchdb_url"},
+		{"addr", func(c *Config) { c.Addr = "8080" }, "addr (ADDR)"},
+		{"login addr", func(c *Config) { c.LoginAddr = "" }, "login_addr (LOGIN_ADDR)"},
+		{"checkin window", func(c *Config) { c.CheckinWindow = 0 }, "checkin_window"},
+		{"principal email", func(c *Config) { c.PrincipalEmail = "principal" }, "principal_email"},
+		{"smtp port", func(c *Config) { c.SMTP = SMTP{Host: "mail", Port: 70000, From: "a@b"} }, "smtp.port"},
+		{"smtp from", func(c *Config) { c.SMTP = SMTP{Host: "mail", Port: 25} }, "smtp.from"},
+		{"smtp set, no outbox", func(c *Config) { c.SMTP = SMTP{Host: "mail", Port: 25, From: "a@b"}; c.MailOutboxDir = "" }, ""},
+		{"no outbox", func(c *Config) { c.MailOutboxDir = "" }, "mail_outbox_dir"},
+	}
+	for _, tt := range tests {
+		c := Defaults()
+		c.JWTSecret = secret
+		tt.change(&c)
+		err := c.Validate()
+		if (tt.err == "") != (err == nil) || err != nil && !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Validate = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	// every problem is reported at once
+	c := Config{CheckinWindow: -time.Second}
+	if err := c.Validate(); err == nil || strings.Count(err.Error(), ";") < 4 {
+		t.Errorf("Validate of an empty config = %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readFile parses the subset of TOML the config file uses: comments,
+// [section] headers and key = value lines whose values are quoted strings
+// or integers. Keys inside a section are returned as "section.key".
+func readFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: malformed section header", path, n)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		key, raw = strings.TrimSpace(key), strings.TrimSpace(raw)
+		if section != "" {
+			key = section + "." + key
+		}
+		value, err := parseValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %v", path, n, key, err)
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("%s:%d: %s set twice", path, n, key)
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// parseValue unquotes a string or checks an integer
+func parseValue(raw string) (string, error) {
+	if strings.HasPrefix(raw, `"`) {
+		return strconv.Unquote(raw)
+	}
+	if _, err := strconv.Atoi(raw); err != nil {
+		return "", fmt.Errorf("value must be a quoted string or an integer")
+	}
+	return raw, nil
+}
+
+// stripComment drops a trailing # comment that isn't inside a string
+func stripComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"time"
+
+	"data-access/store"
+)
+
+// legacyFields maps field names older documents were written with, mostly
+// by the staff endpoints, to their canonical names
+var legacyFields = map[string]string{
+	"fullName":                "full_name",
+	"dateOfBirth":             "date_of_birth",
+	"contactNumber":           "contact_number",
+	"emailAddress":            "email_address",
+	"emergencyContact":        "emergency_contact",
+	"jobTitle":                "job_title",
+	"startDate":               "start_date",
+	"educationLevel":          "education_level",
+	"professionalDevelopment": "professional_development",
+	"CEUs":                    "ceus",
+	"employeeID":              "employee_id",
+	"employmentStatus":        "employment_status",
+	"workHours":               "work_hours",
+	"timeOff":                 "time_off",
+	"payrollInfo":             "payroll_info",
+}
+
+// legacyPayrollFields are the old names inside payroll_info
+var legacyPayrollFields = map[string]string{
+	"directDeposit":   "direct_deposit",
+	"taxWithholdings": "tax_withholdings",
+}
+
+// dateFields are the top-level dates, which must be "YYYY-MM-DD"
+var dateFields = []string{"date_of_birth", "start_date", "joining_date", "admission_date"}
+
+// rename moves fields to their canonical names. Where a document has both,
+// the canonical field wins.
+func rename(doc map[string]interface{}, names map[string]string) bool {
+	changed := false
+	for old, canonical := range names {
+		value, ok := doc[old]
+		if !ok {
+			continue
+		}
+		if _, exists := doc[canonical]; !exists {
+			doc[canonical] = value
+		}
+		delete(doc, old)
+		changed = true
+	}
+	return changed
+}
+
+// Canonicalize rewrites a student, teacher or staff document in place to
+// the canonical field names and date format, reporting whether anything
+// changed
+func Canonicalize(doc map[string]interface{}) bool {
+	changed := rename(doc, legacyFields)
+	if payroll, ok := doc["payroll_info"].(map[string]interface{}); ok && rename(payroll, legacyPayrollFields) {
+		changed = true
+	}
+	for _, field := range dateFields {
+		s, ok := doc[field].(string)
+		if !ok {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			doc[field] = t.Format(DateLayout)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Migrate canonicalizes every document in repo that needs it and returns
+// how many were rewritten. It is safe to run repeatedly.
+func Migrate(repo store.Repository) (int, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, doc := range docs {
+		if !Canonicalize(doc) {
+			continue
+		}
+		id, _ := doc["_id"].(string)
+		_, err := store.Modify(repo, id, func(doc map[string]interface{}) error {
+			Canonicalize(doc)
+			return nil
+		})
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
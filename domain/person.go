@@ -0,0 +1,64 @@
+// Package domain holds the types shared by the student, teacher and staff
+// packages, so that every person is stored and served in one JSON shape.
+package domain
+
+import "time"
+
+// DateLayout is how dates are written in documents and requests
+const DateLayout = "2006-01-02"
+
+// Date is a calendar day, encoded as "YYYY-MM-DD"
+type Date struct {
+	time.Time
+}
+
+func (d *Date) UnmarshalJSON(b []byte) (err error) {
+	s := string(b)
+	if s == "null" {
+		d.Time = time.Time{}
+		return
+	}
+	d.Time, err = time.Parse(`"`+DateLayout+`"`, s)
+	if err != nil {
+		// Older documents stored dates as full RFC 3339 timestamps
+		d.Time, err = time.Parse(`"`+time.RFC3339+`"`, s)
+	}
+	return
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.Format(DateLayout) + `"`), nil
+}
+
+// Person is the identity and contact details every student, teacher and
+// staff member has
+type Person struct {
+	ID               string `json:"id"`
+	FullName         string `json:"full_name"`
+	DateOfBirth      Date   `json:"date_of_birth"`
+	Gender           string `json:"gender"`
+	Address          string `json:"address"`
+	ContactNumber    string `json:"contact_number"`
+	EmailAddress     string `json:"email_address"`
+	EmergencyContact string `json:"emergency_contact"`
+}
+
+// Document adds the person's fields, ID excluded, to the other fields of
+// their document and returns it
+func (p Person) Document(fields map[string]interface{}) map[string]interface{} {
+	fields["full_name"] = p.FullName
+	fields["date_of_birth"] = p.DateOfBirth.Format(DateLayout)
+	fields["gender"] = p.Gender
+	fields["address"] = p.Address
+	fields["contact_number"] = p.ContactNumber
+	fields["email_address"] = p.EmailAddress
+	fields["emergency_contact"] = p.EmergencyContact
+	return fields
+}
+
+// PayrollInfo is the account an employee is paid into and the percentages
+// of pay withheld, by name
+type PayrollInfo struct {
+	DirectDeposit   string             `json:"direct_deposit"`
+	TaxWithholdings map[string]float64 `json:"tax_withholdings"`
+}
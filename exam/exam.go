@@ -0,0 +1,235 @@
+package exam
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/store"
+)
+
+// docType tells exam documents apart from the grading scale in exam_db
+const docType = "exam"
+
+// Exam is one assessment of a subject for a class in a term. Section is
+// optional; an exam without one covers every section of the class.
+// Weightage is the exam's relative weight among the term's exams in the
+// same subject.
+type Exam struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Term      string  `json:"term"`
+	Subject   string  `json:"subject"`
+	Class     string  `json:"class"`
+	Section   string  `json:"section"`
+	Date      string  `json:"date"`
+	MaxMarks  float64 `json:"max_marks"`
+	Weightage float64 `json:"weightage"`
+}
+
+func (e Exam) validate() error {
+	switch {
+	case e.ID == "":
+		return errors.New("exam ID missing")
+	case e.ID == scaleID:
+		// The grading scale shares exam_db
+		return errors.New("exam ID " + scaleID + " is reserved")
+	case e.Term == "" || e.Subject == "" || e.Class == "":
+		return errors.New("term, subject and class are required")
+	case e.MaxMarks <= 0:
+		return errors.New("max_marks must be positive")
+	case e.Weightage <= 0:
+		return errors.New("weightage must be positive")
+	}
+	if _, err := time.Parse("2006-01-02", e.Date); err != nil {
+		return errors.New("date must be formatted as YYYY-MM-DD")
+	}
+	return nil
+}
+
+// covers reports whether a student in class and section sits the exam
+func (e Exam) covers(class, section string) bool {
+	return e.Class == class && (e.Section == "" || e.Section == section)
+}
+
+func (e Exam) doc() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      docType,
+		"name":      e.Name,
+		"term":      e.Term,
+		"subject":   e.Subject,
+		"class":     e.Class,
+		"section":   e.Section,
+		"date":      e.Date,
+		"max_marks": e.MaxMarks,
+		"weightage": e.Weightage,
+	}
+}
+
+// fromDoc decodes an exam document, reporting false for other document types
+func fromDoc(doc map[string]interface{}) (Exam, bool) {
+	if t, _ := doc["type"].(string); t != docType {
+		return Exam{}, false
+	}
+	var e Exam
+	if store.Decode(doc, &e) != nil {
+		return Exam{}, false
+	}
+	e.ID, _ = doc["_id"].(string)
+	return e, true
+}
+
+// Load returns the exam with the given ID
+func Load(repo Repository, id string) (Exam, error) {
+	doc, err := repo.Get(id)
+	if err != nil {
+		return Exam{}, err
+	}
+	e, ok := fromDoc(doc)
+	if !ok {
+		return Exam{}, store.ErrNotFound
+	}
+	return e, nil
+}
+
+// List returns the exams matching every non-empty filter, ordered by date
+func List(repo Repository, term, class, subject string) ([]Exam, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	exams := []Exam{}
+	for _, doc := range docs {
+		e, ok := fromDoc(doc)
+		if !ok {
+			continue
+		}
+		if (term != "" && e.Term != term) || (class != "" && e.Class != class) || (subject != "" && e.Subject != subject) {
+			continue
+		}
+		exams = append(exams, e)
+	}
+	sort.Slice(exams, func(i, j int) bool {
+		if exams[i].Date != exams[j].Date {
+			return exams[i].Date < exams[j].Date
+		}
+		return exams[i].ID < exams[j].ID
+	})
+	return exams, nil
+}
+
+// CreateExam schedules a new exam
+func CreateExam(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var e Exam
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := e.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err := repo.Create(e.ID, e.doc())
+	if err == store.ErrConflict {
+		http.Error(w, "Exam ID already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to create exam", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Exam created successfully"})
+}
+
+// GetExam retrieves an exam by ID
+func GetExam(w http.ResponseWriter, r *http.Request, repo Repository) {
+	examID := r.URL.Query().Get("id")
+	if examID == "" {
+		http.Error(w, "Exam ID missing", http.StatusBadRequest)
+		return
+	}
+
+	e, err := Load(repo, examID)
+	if err != nil {
+		http.Error(w, "Exam not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(e)
+}
+
+// GetAllExams lists exams, optionally filtered by term, class and subject
+func GetAllExams(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	exams, err := List(repo, query.Get("term"), query.Get("class"), query.Get("subject"))
+	if err != nil {
+		http.Error(w, "Failed to fetch exams", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(exams)
+}
+
+// UpdateExam replaces an exam's details. Scores already entered keep their
+// marks; report cards use the exam's current max_marks and weightage.
+func UpdateExam(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var e Exam
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := e.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existingDoc, err := repo.Get(e.ID)
+	if _, ok := fromDoc(existingDoc); err != nil || !ok {
+		http.Error(w, "Exam not found", http.StatusNotFound)
+		return
+	}
+
+	_, err = repo.Update(e.ID, e.doc(), store.Rev(existingDoc))
+	if err == store.ErrConflict {
+		http.Error(w, "exam was modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update exam", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Exam updated successfully"})
+}
+
+// DeleteExam removes an exam. Scores recorded against it stay on the
+// student records but no longer count towards report cards.
+func DeleteExam(w http.ResponseWriter, r *http.Request, repo Repository) {
+	examID := r.URL.Query().Get("id")
+	if examID == "" {
+		http.Error(w, "Exam ID missing", http.StatusBadRequest)
+		return
+	}
+
+	existingDoc, err := repo.Get(examID)
+	if _, ok := fromDoc(existingDoc); err != nil || !ok {
+		http.Error(w, "Exam not found", http.StatusNotFound)
+		return
+	}
+
+	if err := repo.Delete(examID, store.Rev(existingDoc)); err != nil {
+		http.Error(w, "failed to delete exam", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Exam deleted successfully"})
+}
@@ -0,0 +1,144 @@
+package exam
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"data-access/store"
+)
+
+// scaleID is the ID of the grading scale document in exam_db
+const scaleID = "grading_scale"
+
+// Band is one grade of a grading scale: percentages at or above Min get Grade
+type Band struct {
+	Grade string  `json:"grade"`
+	Min   float64 `json:"min"`
+}
+
+// Scale maps percentages to grades. Bands are kept sorted by Min, highest
+// first, and the lowest band starts at 0 so every score gets a grade.
+type Scale []Band
+
+// DefaultScale is used until an admin configures one
+var DefaultScale = Scale{
+	{Grade: "A+", Min: 90},
+	{Grade: "A", Min: 80},
+	{Grade: "B", Min: 70},
+	{Grade: "C", Min: 60},
+	{Grade: "D", Min: 50},
+	{Grade: "E", Min: 40},
+	{Grade: "F", Min: 0},
+}
+
+// Grade returns the grade for a percentage
+func (s Scale) Grade(percentage float64) string {
+	for _, band := range s {
+		if percentage >= band.Min {
+			return band.Grade
+		}
+	}
+	return ""
+}
+
+// normalize sorts the bands and checks the scale is usable
+func (s Scale) normalize() (Scale, error) {
+	if len(s) == 0 {
+		return nil, errors.New("grading scale has no bands")
+	}
+	sorted := append(Scale(nil), s...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Min > sorted[j].Min })
+	seen := make(map[string]bool)
+	for i, band := range sorted {
+		if band.Grade == "" {
+			return nil, errors.New("grading scale band without a grade")
+		}
+		if seen[band.Grade] {
+			return nil, errors.New("grade " + band.Grade + " appears twice")
+		}
+		seen[band.Grade] = true
+		if band.Min < 0 || band.Min > 100 {
+			return nil, errors.New("band minimums must be between 0 and 100")
+		}
+		if i > 0 && band.Min == sorted[i-1].Min {
+			return nil, errors.New("two bands share the same minimum")
+		}
+	}
+	if sorted[len(sorted)-1].Min != 0 {
+		return nil, errors.New("lowest band must start at 0")
+	}
+	return sorted, nil
+}
+
+// LoadScale returns the configured grading scale, or DefaultScale if none
+// has been saved
+func LoadScale(repo Repository) (Scale, error) {
+	doc, err := repo.Get(scaleID)
+	if err == store.ErrNotFound {
+		return DefaultScale, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored struct {
+		Bands Scale `json:"bands"`
+	}
+	if err := store.Decode(doc, &stored); err != nil {
+		return nil, err
+	}
+	return stored.Bands.normalize()
+}
+
+// GetGradingScale returns the grading scale in use
+func GetGradingScale(w http.ResponseWriter, r *http.Request, repo Repository) {
+	scale, err := LoadScale(repo)
+	if err != nil {
+		http.Error(w, "failed to load grading scale", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"bands": scale})
+}
+
+// SetGradingScale replaces the grading scale. Grades already stored on
+// student records are not recomputed.
+func SetGradingScale(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var request struct {
+		Bands Scale `json:"bands"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	scale, err := request.Bands.normalize()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc := map[string]interface{}{"type": "grading_scale", "bands": scale}
+	existing, err := repo.Get(scaleID)
+	switch err {
+	case nil:
+		_, err = repo.Update(scaleID, doc, store.Rev(existing))
+	case store.ErrNotFound:
+		_, err = repo.Create(scaleID, doc)
+	}
+	if err == store.ErrConflict {
+		http.Error(w, "grading scale was modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save grading scale", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Grading scale updated successfully",
+		"bands":   scale,
+	})
+}
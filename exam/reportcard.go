@@ -0,0 +1,138 @@
+package exam
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"data-access/store"
+	"data-access/student"
+)
+
+// ExamResult is a student's result in one exam
+type ExamResult struct {
+	ExamID     string  `json:"exam_id"`
+	Name       string  `json:"name"`
+	Date       string  `json:"date"`
+	Score      float64 `json:"score"`
+	MaxMarks   float64 `json:"max_marks"`
+	Weightage  float64 `json:"weightage"`
+	Percentage float64 `json:"percentage"`
+	Grade      string  `json:"grade"`
+}
+
+// SubjectResult combines a subject's exam results, weighted by weightage
+type SubjectResult struct {
+	Subject    string       `json:"subject"`
+	Exams      []ExamResult `json:"exams"`
+	Percentage float64      `json:"percentage"`
+	Grade      string       `json:"grade"`
+}
+
+// ReportCard is a student's results for one term. The overall percentage
+// is the mean of the subject percentages.
+type ReportCard struct {
+	StudentID  string          `json:"student_id"`
+	FullName   string          `json:"full_name"`
+	Class      string          `json:"class"`
+	Section    string          `json:"section"`
+	Term       string          `json:"term"`
+	Subjects   []SubjectResult `json:"subjects"`
+	Percentage float64         `json:"percentage"`
+	Grade      string          `json:"grade"`
+}
+
+// BuildReportCard computes a student's term report card from their exam
+// scores. Exams not yet scored, and scores for exams that no longer exist,
+// are left out.
+func BuildReportCard(exams Repository, doc map[string]interface{}, term string) (ReportCard, error) {
+	var info studentInfo
+	if err := store.Decode(doc, &info); err != nil {
+		return ReportCard{}, err
+	}
+	card := ReportCard{FullName: info.FullName, Class: info.Class, Section: info.Section, Term: term, Subjects: []SubjectResult{}}
+	card.StudentID, _ = doc["_id"].(string)
+
+	termExams, err := List(exams, term, info.Class, "")
+	if err != nil {
+		return ReportCard{}, err
+	}
+	scale, err := LoadScale(exams)
+	if err != nil {
+		return ReportCard{}, err
+	}
+
+	scores := make(map[string]student.ExamScore)
+	for _, s := range info.ExamScores {
+		if s.ExamID != "" {
+			scores[s.ExamID] = s
+		}
+	}
+
+	bySubject := make(map[string]*SubjectResult)
+	weights := make(map[string]float64)
+	for _, e := range termExams {
+		s, ok := scores[e.ID]
+		if !ok || !e.covers(info.Class, info.Section) {
+			continue
+		}
+		pct := s.Score / e.MaxMarks * 100
+		subject := bySubject[e.Subject]
+		if subject == nil {
+			subject = &SubjectResult{Subject: e.Subject}
+			bySubject[e.Subject] = subject
+		}
+		subject.Exams = append(subject.Exams, ExamResult{
+			ExamID:     e.ID,
+			Name:       e.Name,
+			Date:       e.Date,
+			Score:      s.Score,
+			MaxMarks:   e.MaxMarks,
+			Weightage:  e.Weightage,
+			Percentage: round2(pct),
+			Grade:      scale.Grade(pct),
+		})
+		subject.Percentage += pct * e.Weightage
+		weights[e.Subject] += e.Weightage
+	}
+
+	var total float64
+	for name, subject := range bySubject {
+		pct := subject.Percentage / weights[name]
+		subject.Percentage = round2(pct)
+		subject.Grade = scale.Grade(pct)
+		total += pct
+		card.Subjects = append(card.Subjects, *subject)
+	}
+	sort.Slice(card.Subjects, func(i, j int) bool { return card.Subjects[i].Subject < card.Subjects[j].Subject })
+	if len(card.Subjects) > 0 {
+		pct := total / float64(len(card.Subjects))
+		card.Percentage = round2(pct)
+		card.Grade = scale.Grade(pct)
+	}
+	return card, nil
+}
+
+// GetReportCard returns a student's report card for a term (?id=..&term=..)
+func GetReportCard(w http.ResponseWriter, r *http.Request, exams Repository, students student.Repository) {
+	query := r.URL.Query()
+	studentID, term := query.Get("id"), query.Get("term")
+	if studentID == "" || term == "" {
+		http.Error(w, "Student ID and term are required", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := students.Get(studentID)
+	if err != nil {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+	card, err := BuildReportCard(exams, doc, term)
+	if err != nil {
+		http.Error(w, "failed to build report card", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(card)
+}
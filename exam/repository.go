@@ -0,0 +1,23 @@
+package exam
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding exams and the grading scale
+const DBName = "exam_db"
+
+// Repository is the storage backend for exam documents
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the exam_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
@@ -0,0 +1,174 @@
+package exam
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+
+	"data-access/store"
+	"data-access/student"
+)
+
+// round2 rounds a percentage to two decimal places
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// studentInfo is the part of a student document the gradebook reads
+type studentInfo struct {
+	FullName   string              `json:"full_name"`
+	Class      string              `json:"class"`
+	Section    string              `json:"section"`
+	ExamScores []student.ExamScore `json:"exam_scores"`
+}
+
+// setScore records a student's score for an exam, replacing any earlier
+// score for the same exam
+func setScore(scores []student.ExamScore, score student.ExamScore) []student.ExamScore {
+	for i := range scores {
+		if scores[i].ExamID == score.ExamID {
+			scores[i] = score
+			return scores
+		}
+	}
+	return append(scores, score)
+}
+
+// EnterScores records marks for many students in one exam. Every student
+// must sit the exam and every score must be within 0..max_marks; nothing
+// is written if any entry is invalid. Grades come from the grading scale.
+func EnterScores(w http.ResponseWriter, r *http.Request, exams Repository, students student.Repository) {
+	var request struct {
+		ExamID string `json:"exam_id"`
+		Scores []struct {
+			StudentID string  `json:"student_id"`
+			Score     float64 `json:"score"`
+		} `json:"scores"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if len(request.Scores) == 0 {
+		http.Error(w, "no scores given", http.StatusBadRequest)
+		return
+	}
+
+	e, err := Load(exams, request.ExamID)
+	if err != nil {
+		http.Error(w, "Exam not found", http.StatusNotFound)
+		return
+	}
+	scale, err := LoadScale(exams)
+	if err != nil {
+		http.Error(w, "failed to load grading scale", http.StatusInternalServerError)
+		return
+	}
+
+	// Validate the whole batch before writing anything
+	for _, entry := range request.Scores {
+		if entry.Score < 0 || entry.Score > e.MaxMarks {
+			http.Error(w, "score for student "+entry.StudentID+" is outside 0..max_marks", http.StatusBadRequest)
+			return
+		}
+		doc, err := students.Get(entry.StudentID)
+		if err != nil {
+			http.Error(w, "student "+entry.StudentID+" not found", http.StatusBadRequest)
+			return
+		}
+		var info studentInfo
+		if err := store.Decode(doc, &info); err != nil || !e.covers(info.Class, info.Section) {
+			http.Error(w, "student "+entry.StudentID+" does not sit this exam", http.StatusBadRequest)
+			return
+		}
+	}
+
+	failed := []string{}
+	for _, entry := range request.Scores {
+		score := student.ExamScore{
+			ExamID:   e.ID,
+			Term:     e.Term,
+			Subject:  e.Subject,
+			Score:    entry.Score,
+			MaxMarks: e.MaxMarks,
+			Grade:    scale.Grade(entry.Score / e.MaxMarks * 100),
+		}
+		_, err := store.Modify(students, entry.StudentID, func(doc map[string]interface{}) error {
+			var info studentInfo
+			if err := store.Decode(doc, &info); err != nil {
+				return err
+			}
+			doc["exam_scores"] = setScore(info.ExamScores, score)
+			return nil
+		})
+		if err != nil {
+			failed = append(failed, entry.StudentID)
+		}
+	}
+	sort.Strings(failed)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Scores recorded",
+		"recorded": len(request.Scores) - len(failed),
+		"failed":   failed,
+	})
+}
+
+// GetExamScores lists the scores entered for an exam, along with the
+// students who sit it but have no score yet
+func GetExamScores(w http.ResponseWriter, r *http.Request, exams Repository, students student.Repository) {
+	examID := r.URL.Query().Get("exam_id")
+	if examID == "" {
+		http.Error(w, "Exam ID missing", http.StatusBadRequest)
+		return
+	}
+	e, err := Load(exams, examID)
+	if err != nil {
+		http.Error(w, "Exam not found", http.StatusNotFound)
+		return
+	}
+
+	docs, err := students.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch students", http.StatusInternalServerError)
+		return
+	}
+
+	type row struct {
+		StudentID  string  `json:"student_id"`
+		FullName   string  `json:"full_name"`
+		Score      float64 `json:"score"`
+		Percentage float64 `json:"percentage"`
+		Grade      string  `json:"grade"`
+	}
+	scores, missing := []row{}, []string{}
+	for _, doc := range docs {
+		var info studentInfo
+		if store.Decode(doc, &info) != nil || !e.covers(info.Class, info.Section) {
+			continue
+		}
+		id, _ := doc["_id"].(string)
+		found := false
+		for _, s := range info.ExamScores {
+			if s.ExamID == e.ID {
+				scores = append(scores, row{id, info.FullName, s.Score, round2(s.Score / e.MaxMarks * 100), s.Grade})
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, id)
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].StudentID < scores[j].StudentID })
+	sort.Strings(missing)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"exam":    e,
+		"scores":  scores,
+		"missing": missing,
+	})
+}
@@ -0,0 +1,369 @@
+package fee
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/store"
+	"data-access/student"
+)
+
+// Invoice statuses
+const (
+	StatusUnpaid  = "unpaid"
+	StatusPartial = "partial"
+	StatusPaid    = "paid"
+)
+
+// Discount reduces an invoice, e.g. a scholarship
+type Discount struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// Payment is one (possibly partial) payment against an invoice
+type Payment struct {
+	ReceiptNo  string    `json:"receipt_no"`
+	Amount     float64   `json:"amount"`
+	PaidAt     time.Time `json:"paid_at"`
+	Method     string    `json:"method"`
+	Reference  string    `json:"reference,omitempty"`
+	ReceivedBy string    `json:"received_by,omitempty"`
+}
+
+// Invoice is what a student owes for a term. Items, discounts and the
+// late-fee rule are copied from the fee structure when the invoice is
+// issued, so later changes to the structure don't alter it.
+type Invoice struct {
+	ID          string      `json:"id"`
+	StudentID   string      `json:"student_id"`
+	StudentName string      `json:"student_name"`
+	Class       string      `json:"class"`
+	Section     string      `json:"section"`
+	Term        string      `json:"term"`
+	Items       []Item      `json:"items"`
+	Subtotal    float64     `json:"subtotal"`
+	Discounts   []Discount  `json:"discounts"`
+	Total       float64     `json:"total"`
+	DueDate     string      `json:"due_date"`
+	LateFeeRule LateFeeRule `json:"late_fee_rule"`
+	IssuedAt    time.Time   `json:"issued_at"`
+	Payments    []Payment   `json:"payments"`
+}
+
+// Statement is an invoice with its balance worked out as of some date
+type Statement struct {
+	Invoice
+	LateFee float64 `json:"late_fee"`
+	Paid    float64 `json:"paid"`
+	Balance float64 `json:"balance"`
+	Status  string  `json:"status"`
+	Overdue bool    `json:"overdue"`
+}
+
+func invoiceID(studentID, term string) string {
+	return "invoice:" + studentID + ":" + term
+}
+
+// Statement works out the late fee, amount paid and balance at now. The
+// late fee applies to whatever was still unpaid when the grace period
+// ended, so paying late doesn't make it go away.
+func (inv Invoice) Statement(now time.Time) Statement {
+	s := Statement{Invoice: inv}
+	today := now.Format(dateLayout)
+
+	deadline := ""
+	if due, err := time.Parse(dateLayout, inv.DueDate); err == nil {
+		deadline = due.AddDate(0, 0, inv.LateFeeRule.GraceDays).Format(dateLayout)
+	}
+	var paidByDeadline float64
+	for _, p := range inv.Payments {
+		s.Paid += p.Amount
+		if p.PaidAt.Format(dateLayout) <= deadline {
+			paidByDeadline += p.Amount
+		}
+	}
+	if unpaid := inv.Total - paidByDeadline; deadline != "" && today > deadline && unpaid > 0.005 {
+		s.LateFee = round2(inv.LateFeeRule.Flat + unpaid*inv.LateFeeRule.Percent/100)
+	}
+
+	s.Paid = round2(s.Paid)
+	s.Balance = math.Max(0, round2(inv.Total+s.LateFee-s.Paid))
+	switch {
+	case s.Balance == 0:
+		s.Status = StatusPaid
+	case s.Paid > 0:
+		s.Status = StatusPartial
+	default:
+		s.Status = StatusUnpaid
+	}
+	s.Overdue = s.Balance > 0 && today > inv.DueDate
+	return s
+}
+
+// scholarshipUse totals, per student and scholarship name, what the
+// student's invoices for other terms have already deducted
+func scholarshipUse(repo Repository, term string) (map[string]map[string]float64, error) {
+	invoices, err := listInvoices(repo, func(inv Invoice) bool { return inv.Term != term })
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]map[string]float64{}
+	for _, inv := range invoices {
+		if used[inv.StudentID] == nil {
+			used[inv.StudentID] = map[string]float64{}
+		}
+		for _, d := range inv.Discounts {
+			used[inv.StudentID][d.Name] += d.Amount
+		}
+	}
+	return used, nil
+}
+
+// newInvoice issues an invoice from a fee structure. Scholarships awarded
+// on or before the due date are deducted up to the subtotal; used holds
+// what earlier invoices already took from each scholarship, and only the
+// rest of it is left to deduct.
+func newInvoice(s Structure, studentID string, doc map[string]interface{}, used map[string]float64, now time.Time) (Invoice, error) {
+	var info struct {
+		FullName     string                `json:"full_name"`
+		Section      string                `json:"section"`
+		Scholarships []student.Scholarship `json:"scholarships"`
+	}
+	if err := store.Decode(doc, &info); err != nil {
+		return Invoice{}, err
+	}
+
+	inv := Invoice{
+		ID:          invoiceID(studentID, s.Term),
+		StudentID:   studentID,
+		StudentName: info.FullName,
+		Class:       s.Class,
+		Section:     info.Section,
+		Term:        s.Term,
+		Items:       s.Items,
+		Subtotal:    s.Total(),
+		Discounts:   []Discount{},
+		DueDate:     s.DueDate,
+		LateFeeRule: s.LateFee,
+		IssuedAt:    now,
+		Payments:    []Payment{},
+	}
+	// Work on a copy so scholarships sharing a name each consume their part
+	spent := map[string]float64{}
+	for name, amount := range used {
+		spent[name] = amount
+	}
+	remaining := inv.Subtotal
+	for _, sch := range info.Scholarships {
+		if sch.Amount <= 0 || sch.DateAwarded.IsZero() || sch.DateAwarded.Format(dateLayout) > s.DueDate {
+			continue
+		}
+		consumed := math.Min(spent[sch.Name], sch.Amount)
+		spent[sch.Name] -= consumed
+		left := sch.Amount - consumed
+		if left < 0.005 || remaining <= 0 {
+			continue
+		}
+		amount := math.Min(left, remaining)
+		inv.Discounts = append(inv.Discounts, Discount{Name: sch.Name, Amount: round2(amount)})
+		remaining -= amount
+	}
+	inv.Total = round2(remaining)
+	return inv, nil
+}
+
+func loadInvoice(repo Repository, id string) (Invoice, error) {
+	doc, err := repo.Get(id)
+	if err != nil {
+		return Invoice{}, err
+	}
+	var inv Invoice
+	if !decode(doc, typeInvoice, &inv) {
+		return Invoice{}, store.ErrNotFound
+	}
+	inv.ID = id
+	return inv, nil
+}
+
+// listInvoices returns the invoices accepted by keep
+func listInvoices(repo Repository, keep func(Invoice) bool) ([]Invoice, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	invoices := []Invoice{}
+	for _, doc := range docs {
+		var inv Invoice
+		if !decode(doc, typeInvoice, &inv) {
+			continue
+		}
+		inv.ID, _ = doc["_id"].(string)
+		if keep(inv) {
+			invoices = append(invoices, inv)
+		}
+	}
+	sort.Slice(invoices, func(i, j int) bool { return invoices[i].ID < invoices[j].ID })
+	return invoices, nil
+}
+
+// GenerateInvoices issues invoices for every student in a class (or one
+// section of it) from the class's fee structure for the term. Students who
+// already have an invoice for the term are skipped.
+func GenerateInvoices(w http.ResponseWriter, r *http.Request, repo Repository, students student.Repository) {
+	var request struct {
+		Class   string `json:"class"`
+		Section string `json:"section"`
+		Term    string `json:"term"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if request.Class == "" || request.Term == "" {
+		http.Error(w, "class and term are required", http.StatusBadRequest)
+		return
+	}
+
+	structure, err := loadStructure(repo, request.Class, request.Term)
+	if err != nil {
+		http.Error(w, "no fee structure for that class and term", http.StatusNotFound)
+		return
+	}
+	used, err := scholarshipUse(repo, request.Term)
+	if err != nil {
+		http.Error(w, "Failed to fetch invoices", http.StatusInternalServerError)
+		return
+	}
+	docs, err := students.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch students", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	created, skipped, failed := []string{}, []string{}, []string{}
+	for _, doc := range docs {
+		class, _ := doc["class"].(string)
+		section, _ := doc["section"].(string)
+		if class != request.Class || (request.Section != "" && section != request.Section) {
+			continue
+		}
+		studentID, _ := doc["_id"].(string)
+		inv, err := newInvoice(structure, studentID, doc, used[studentID], now)
+		var invDoc map[string]interface{}
+		if err == nil {
+			invDoc, err = toDoc(typeInvoice, inv)
+		}
+		if err == nil {
+			_, err = repo.Create(inv.ID, invDoc)
+		}
+		switch err {
+		case nil:
+			created = append(created, inv.ID)
+		case store.ErrConflict:
+			skipped = append(skipped, inv.ID)
+		default:
+			failed = append(failed, studentID)
+		}
+	}
+	sort.Strings(created)
+	sort.Strings(skipped)
+	sort.Strings(failed)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Invoices generated",
+		"created": created,
+		"skipped": skipped,
+		"failed":  failed,
+	})
+}
+
+// GetInvoice returns one invoice with its current balance (?id=..)
+func GetInvoice(w http.ResponseWriter, r *http.Request, repo Repository) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Invoice ID missing", http.StatusBadRequest)
+		return
+	}
+	inv, err := loadInvoice(repo, id)
+	if err != nil {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(inv.Statement(time.Now()))
+}
+
+// GetStudentInvoices returns every invoice of a student (?id=..)
+func GetStudentInvoices(w http.ResponseWriter, r *http.Request, repo Repository) {
+	studentID := r.URL.Query().Get("id")
+	if studentID == "" {
+		http.Error(w, "Student ID missing", http.StatusBadRequest)
+		return
+	}
+	invoices, err := listInvoices(repo, func(inv Invoice) bool { return inv.StudentID == studentID })
+	if err != nil {
+		http.Error(w, "Failed to fetch invoices", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	statements := make([]Statement, 0, len(invoices))
+	for _, inv := range invoices {
+		statements = append(statements, inv.Statement(now))
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(statements)
+}
+
+// GetDues lists invoices with money outstanding, filtered by any of class,
+// section, term and student_id; overdue=true keeps only those past due
+func GetDues(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	filters := map[string]string{
+		"class":      query.Get("class"),
+		"section":    query.Get("section"),
+		"term":       query.Get("term"),
+		"student_id": query.Get("student_id"),
+	}
+	onlyOverdue := query.Get("overdue") == "true"
+
+	invoices, err := listInvoices(repo, func(inv Invoice) bool {
+		fields := map[string]string{"class": inv.Class, "section": inv.Section, "term": inv.Term, "student_id": inv.StudentID}
+		for key, want := range filters {
+			if want != "" && fields[key] != want {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		http.Error(w, "Failed to fetch invoices", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	dues := []Statement{}
+	var outstanding float64
+	for _, inv := range invoices {
+		s := inv.Statement(now)
+		if s.Balance == 0 || (onlyOverdue && !s.Overdue) {
+			continue
+		}
+		dues = append(dues, s)
+		outstanding += s.Balance
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invoices":    dues,
+		"count":       len(dues),
+		"outstanding": round2(outstanding),
+	})
+}
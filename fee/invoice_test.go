@@ -0,0 +1,119 @@
+package fee
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"data-access/student"
+)
+
+func setStructure(t *testing.T, repo Repository, s Structure) {
+	t.Helper()
+	doc, err := toDoc(typeStructure, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(structureID(s.Class, s.Term), doc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func generate(t *testing.T, repo Repository, students student.Repository, term string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	body := `{"class":"5","term":"` + term + `"}`
+	GenerateInvoices(w, httptest.NewRequest("POST", "/invoices/generate", strings.NewReader(body)), repo, students)
+	var result struct {
+		Created []string `json:"created"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || len(result.Created) != 1 {
+		t.Fatalf("GenerateInvoices(%s) = %d %v", term, w.Code, result)
+	}
+}
+
+func TestScholarshipSpreadsAcrossTerms(t *testing.T) {
+	repo, students := NewMemoryRepository(), student.NewMemoryRepository()
+	students.Create("s1", map[string]interface{}{
+		"full_name": "Ann",
+		"class":     "5",
+		"scholarships": []interface{}{
+			map[string]interface{}{"name": "Merit", "amount": 1500, "date_awarded": "2024-04-01"},
+		},
+	})
+	for _, term := range []string{"T1", "T2", "T3"} {
+		setStructure(t, repo, Structure{
+			Class:   "5",
+			Term:    term,
+			Items:   []Item{{Name: "Tuition", Amount: 1000}},
+			DueDate: "2024-06-30",
+		})
+	}
+
+	// The first term uses 1000 of the 1500, the second the remaining 500
+	want := map[string]float64{"T1": 0, "T2": 500, "T3": 1000}
+	for _, term := range []string{"T1", "T2", "T3"} {
+		generate(t, repo, students, term)
+		inv, err := loadInvoice(repo, invoiceID("s1", term))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inv.Total != want[term] {
+			t.Errorf("%s total = %v, want %v (discounts %v)", term, inv.Total, want[term], inv.Discounts)
+		}
+	}
+}
+
+func TestNewInvoiceSkipsLateScholarships(t *testing.T) {
+	s := Structure{Class: "5", Term: "T1", Items: []Item{{Name: "Tuition", Amount: 800}, {Name: "Bus", Amount: 200}}, DueDate: "2024-06-30"}
+	doc := map[string]interface{}{
+		"scholarships": []interface{}{
+			map[string]interface{}{"name": "Sports", "amount": 300, "date_awarded": "2024-06-01"},
+			map[string]interface{}{"name": "Merit", "amount": 500, "date_awarded": "2024-07-15"},
+		},
+	}
+	inv, err := newInvoice(s, "s1", doc, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Subtotal != 1000 || inv.Total != 700 || len(inv.Discounts) != 1 || inv.Discounts[0].Name != "Sports" {
+		t.Errorf("invoice = subtotal %v, total %v, discounts %v; want 1000, 700, [Sports]", inv.Subtotal, inv.Total, inv.Discounts)
+	}
+
+	// Part of the scholarship went to an earlier term
+	inv, _ = newInvoice(s, "s1", doc, map[string]float64{"Sports": 250}, time.Now())
+	if inv.Total != 950 {
+		t.Errorf("total with 250 already used = %v, want 950", inv.Total)
+	}
+}
+
+func TestStatementLateFee(t *testing.T) {
+	due := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	inv := Invoice{
+		Total:       1000,
+		DueDate:     "2024-06-30",
+		LateFeeRule: LateFeeRule{GraceDays: 5, Flat: 50, Percent: 10},
+		Payments:    []Payment{{Amount: 400, PaidAt: due.AddDate(0, 0, 2)}},
+	}
+
+	s := inv.Statement(due.AddDate(0, 0, 3))
+	if s.LateFee != 0 || s.Balance != 600 || s.Status != StatusPartial || !s.Overdue {
+		t.Errorf("within grace period = %+v", s)
+	}
+
+	// 600 unpaid at the end of the grace period: 50 + 10%
+	s = inv.Statement(due.AddDate(0, 0, 10))
+	if s.LateFee != 110 || s.Balance != 710 {
+		t.Errorf("after grace period late fee = %v, balance = %v; want 110, 710", s.LateFee, s.Balance)
+	}
+
+	inv.Payments = append(inv.Payments, Payment{Amount: 710, PaidAt: due.AddDate(0, 0, 12)})
+	s = inv.Statement(due.AddDate(0, 0, 20))
+	if s.Balance != 0 || s.Status != StatusPaid || s.Overdue {
+		t.Errorf("paid late = %+v", s)
+	}
+}
@@ -0,0 +1,179 @@
+package fee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"data-access/auth"
+	"data-access/store"
+)
+
+// counterID is the document holding the last issued receipt number
+const counterID = "receipt_counter"
+
+var errOverpayment = errors.New("payment exceeds the balance due")
+
+// nextReceiptNumber reserves the next receipt number. Numbers are never
+// reused; a payment that fails after reserving one leaves a gap.
+func nextReceiptNumber(repo Repository) (string, error) {
+	for {
+		var number int
+		_, err := store.Modify(repo, counterID, func(doc map[string]interface{}) error {
+			last, _ := doc["last"].(float64)
+			number = int(last) + 1
+			doc["last"] = number
+			return nil
+		})
+		if err == store.ErrNotFound {
+			number = 1
+			_, err = repo.Create(counterID, map[string]interface{}{"type": "counter", "last": number})
+			if err == store.ErrConflict {
+				continue // someone else created it first
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("RCPT-%06d", number), nil
+	}
+}
+
+// Receipt confirms one payment
+type Receipt struct {
+	Payment
+	InvoiceID   string  `json:"invoice_id"`
+	StudentID   string  `json:"student_id"`
+	StudentName string  `json:"student_name"`
+	Term        string  `json:"term"`
+	Balance     float64 `json:"balance"`
+}
+
+// RecordPayment records a full or partial payment against an invoice and
+// issues a numbered receipt. Payments larger than the balance are refused.
+func RecordPayment(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var request struct {
+		InvoiceID string  `json:"invoice_id"`
+		Amount    float64 `json:"amount"`
+		Method    string  `json:"method"`
+		Reference string  `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	request.Amount = round2(request.Amount)
+	if request.Amount <= 0 {
+		http.Error(w, "amount must be positive", http.StatusBadRequest)
+		return
+	}
+	if request.Method == "" {
+		request.Method = "cash"
+	}
+
+	now := time.Now()
+	inv, err := loadInvoice(repo, request.InvoiceID)
+	if err != nil {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+	if request.Amount > inv.Statement(now).Balance {
+		http.Error(w, errOverpayment.Error(), http.StatusBadRequest)
+		return
+	}
+
+	receiptNo, err := nextReceiptNumber(repo)
+	if err != nil {
+		http.Error(w, "failed to issue receipt number", http.StatusInternalServerError)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	payment := Payment{
+		ReceiptNo:  receiptNo,
+		Amount:     request.Amount,
+		PaidAt:     now,
+		Method:     request.Method,
+		Reference:  request.Reference,
+		ReceivedBy: caller.Email,
+	}
+
+	// Check the balance again against the stored invoice in case another
+	// payment landed in the meantime
+	_, err = store.Modify(repo, inv.ID, func(doc map[string]interface{}) error {
+		if !decode(doc, typeInvoice, &inv) {
+			return store.ErrNotFound
+		}
+		if payment.Amount > inv.Statement(now).Balance {
+			return errOverpayment
+		}
+		inv.Payments = append(inv.Payments, payment)
+		doc["payments"] = inv.Payments
+		return nil
+	})
+	if err == errOverpayment {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to record payment", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Receipt{
+		Payment:     payment,
+		InvoiceID:   inv.ID,
+		StudentID:   inv.StudentID,
+		StudentName: inv.StudentName,
+		Term:        inv.Term,
+		Balance:     inv.Statement(now).Balance,
+	})
+}
+
+// GetReceipt looks up a receipt by number (?number=..). The balance shown
+// is the invoice's balance right after that payment.
+func GetReceipt(w http.ResponseWriter, r *http.Request, repo Repository) {
+	number := r.URL.Query().Get("number")
+	if number == "" {
+		http.Error(w, "Receipt number missing", http.StatusBadRequest)
+		return
+	}
+
+	invoices, err := listInvoices(repo, func(inv Invoice) bool {
+		for _, p := range inv.Payments {
+			if p.ReceiptNo == number {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		http.Error(w, "Failed to fetch invoices", http.StatusInternalServerError)
+		return
+	}
+	if len(invoices) == 0 {
+		http.Error(w, "Receipt not found", http.StatusNotFound)
+		return
+	}
+
+	inv := invoices[0]
+	for i, p := range inv.Payments {
+		if p.ReceiptNo != number {
+			continue
+		}
+		upTo := inv
+		upTo.Payments = inv.Payments[:i+1]
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(Receipt{
+			Payment:     p,
+			InvoiceID:   inv.ID,
+			StudentID:   inv.StudentID,
+			StudentName: inv.StudentName,
+			Term:        inv.Term,
+			Balance:     upTo.Statement(p.PaidAt).Balance,
+		})
+		return
+	}
+}
@@ -0,0 +1,76 @@
+package fee
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/notify"
+)
+
+// SendReminders emails the parents of every student with fees outstanding,
+// filtered by any of class, section and term ({"class", "section",
+// "term"}). Only overdue invoices are reminded unless include_upcoming is
+// true. Students without a parent account to write to are listed in
+// no_recipients.
+func SendReminders(w http.ResponseWriter, r *http.Request, repo Repository, notifier notify.Notifier, parents notify.Recipients) {
+	var request struct {
+		Class           string `json:"class"`
+		Section         string `json:"section"`
+		Term            string `json:"term"`
+		IncludeUpcoming bool   `json:"include_upcoming"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	invoices, err := listInvoices(repo, func(inv Invoice) bool {
+		return (request.Class == "" || inv.Class == request.Class) &&
+			(request.Section == "" || inv.Section == request.Section) &&
+			(request.Term == "" || inv.Term == request.Term)
+	})
+	if err != nil {
+		http.Error(w, "Failed to fetch invoices", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	queued := 0
+	noRecipients, failed := []string{}, []string{}
+	for _, inv := range invoices {
+		s := inv.Statement(now)
+		if s.Balance == 0 || (!s.Overdue && !request.IncludeUpcoming) {
+			continue
+		}
+		to, err := parents(inv.StudentID)
+		if err != nil {
+			failed = append(failed, inv.StudentID)
+			continue
+		}
+		if len(to) == 0 {
+			noRecipients = append(noRecipients, inv.StudentID)
+			continue
+		}
+		err = notifier.Send(notify.FeeReminder(to, notify.FeeReminderData{
+			StudentName: inv.StudentName, Term: inv.Term, InvoiceID: inv.ID,
+			DueDate: inv.DueDate, Balance: s.Balance, Overdue: s.Overdue,
+		}))
+		if err != nil {
+			failed = append(failed, inv.StudentID)
+			continue
+		}
+		queued++
+	}
+	sort.Strings(noRecipients)
+	sort.Strings(failed)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Fee reminders queued",
+		"queued":        queued,
+		"no_recipients": noRecipients,
+		"failed":        failed,
+	})
+}
@@ -0,0 +1,24 @@
+package fee
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding fee structures, invoices and
+// the receipt counter
+const DBName = "fee_db"
+
+// Repository is the storage backend for fee documents
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the fee_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
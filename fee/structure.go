@@ -0,0 +1,194 @@
+package fee
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/store"
+)
+
+// Document types stored in fee_db
+const (
+	typeStructure = "fee_structure"
+	typeInvoice   = "invoice"
+)
+
+const dateLayout = "2006-01-02"
+
+// Item is one line of a fee structure or invoice (tuition, transport, ...)
+type Item struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// LateFeeRule is charged once on whatever part of an invoice is still
+// unpaid GraceDays after the due date: a flat amount plus a percentage of
+// the unpaid amount.
+type LateFeeRule struct {
+	GraceDays int     `json:"grace_days"`
+	Flat      float64 `json:"flat"`
+	Percent   float64 `json:"percent"`
+}
+
+// Structure is the fee schedule for a class in a term
+type Structure struct {
+	ID      string      `json:"id"`
+	Class   string      `json:"class"`
+	Term    string      `json:"term"`
+	Items   []Item      `json:"items"`
+	DueDate string      `json:"due_date"`
+	LateFee LateFeeRule `json:"late_fee"`
+}
+
+// structureID gives each class one fee structure per term
+func structureID(class, term string) string {
+	return "structure:" + class + ":" + term
+}
+
+func (s Structure) validate() error {
+	if s.Class == "" || s.Term == "" {
+		return errors.New("class and term are required")
+	}
+	if len(s.Items) == 0 {
+		return errors.New("fee structure has no items")
+	}
+	for _, item := range s.Items {
+		if item.Name == "" || item.Amount <= 0 {
+			return errors.New("every item needs a name and a positive amount")
+		}
+	}
+	if _, err := time.Parse(dateLayout, s.DueDate); err != nil {
+		return errors.New("due_date must be formatted as YYYY-MM-DD")
+	}
+	if s.LateFee.GraceDays < 0 || s.LateFee.Flat < 0 || s.LateFee.Percent < 0 || s.LateFee.Percent > 100 {
+		return errors.New("invalid late_fee rule")
+	}
+	return nil
+}
+
+// Total is the sum of the structure's items
+func (s Structure) Total() float64 {
+	var total float64
+	for _, item := range s.Items {
+		total += item.Amount
+	}
+	return round2(total)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// toDoc converts a fee record into a document of the given type
+func toDoc(docType string, v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "id")
+	doc["type"] = docType
+	return doc, nil
+}
+
+// decode reads a document of the given type into v, reporting false for
+// documents of other types
+func decode(doc map[string]interface{}, docType string, v interface{}) bool {
+	if t, _ := doc["type"].(string); t != docType {
+		return false
+	}
+	if store.Decode(doc, v) != nil {
+		return false
+	}
+	return true
+}
+
+func loadStructure(repo Repository, class, term string) (Structure, error) {
+	doc, err := repo.Get(structureID(class, term))
+	if err != nil {
+		return Structure{}, err
+	}
+	var s Structure
+	if !decode(doc, typeStructure, &s) {
+		return Structure{}, store.ErrNotFound
+	}
+	s.ID, _ = doc["_id"].(string)
+	return s, nil
+}
+
+// SetStructure creates or replaces the fee structure for a class and term.
+// Invoices already generated keep the amounts they were issued with.
+func SetStructure(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var s Structure
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := s.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.ID = structureID(s.Class, s.Term)
+
+	doc, err := toDoc(typeStructure, s)
+	if err != nil {
+		http.Error(w, "failed to save fee structure", http.StatusInternalServerError)
+		return
+	}
+	existing, err := repo.Get(s.ID)
+	switch err {
+	case nil:
+		_, err = repo.Update(s.ID, doc, store.Rev(existing))
+	case store.ErrNotFound:
+		_, err = repo.Create(s.ID, doc)
+	}
+	if err == store.ErrConflict {
+		http.Error(w, "fee structure was modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save fee structure", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Fee structure saved successfully",
+		"id":      s.ID,
+		"total":   s.Total(),
+	})
+}
+
+// GetStructures lists fee structures, optionally filtered by class and term
+func GetStructures(w http.ResponseWriter, r *http.Request, repo Repository) {
+	class, term := r.URL.Query().Get("class"), r.URL.Query().Get("term")
+
+	docs, err := repo.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch fee structures", http.StatusInternalServerError)
+		return
+	}
+	structures := []Structure{}
+	for _, doc := range docs {
+		var s Structure
+		if !decode(doc, typeStructure, &s) {
+			continue
+		}
+		if (class != "" && s.Class != class) || (term != "" && s.Term != term) {
+			continue
+		}
+		s.ID, _ = doc["_id"].(string)
+		structures = append(structures, s)
+	}
+	sort.Slice(structures, func(i, j int) bool { return structures[i].ID < structures[j].ID })
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(structures)
+}
@@ -3,8 +3,9 @@ module data-access
 go 1.23.0
 
 require (
-	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
-	github.com/fjl/go-couchdb v0.1.0 // indirect
-	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e // indirect
-	golang.org/x/crypto v0.27.0 // indirect
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/fjl/go-couchdb v0.1.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	golang.org/x/crypto v0.27.0
+	golang.org/x/image v0.18.0
 )
@@ -0,0 +1,98 @@
+package health
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/auth"
+	"data-access/store"
+)
+
+// What an access log entry records being done
+const (
+	ActionView   = "view"
+	ActionUpdate = "update"
+	ActionAlerts = "alerts"
+)
+
+const typeAccess = "access_log"
+
+// AccessEntry records one read or write of health data
+type AccessEntry struct {
+	At         string   `json:"at"`
+	Action     string   `json:"action"`
+	UserID     string   `json:"user_id"`
+	Email      string   `json:"email"`
+	Role       string   `json:"role"`
+	StudentIDs []string `json:"student_ids"`
+	RemoteAddr string   `json:"remote_addr,omitempty"`
+}
+
+// logAccess records that the caller performed action on the health data
+// of the given students. Handlers must not return health data if it fails.
+func logAccess(repo Repository, r *http.Request, action string, studentIDs []string) error {
+	caller, _ := auth.FromContext(r.Context())
+	now := time.Now().UTC()
+	b := make([]byte, 4)
+	rand.Read(b)
+	_, err := repo.Create("access:"+now.Format("20060102T150405.000000000")+":"+hex.EncodeToString(b), map[string]interface{}{
+		"type":        typeAccess,
+		"at":          now.Format("2006-01-02T15:04:05.000000000Z07:00"),
+		"action":      action,
+		"user_id":     caller.ID,
+		"email":       caller.Email,
+		"role":        string(caller.Role),
+		"student_ids": studentIDs,
+		"remote_addr": r.RemoteAddr,
+	})
+	return err
+}
+
+// GetAccessLog lists who accessed health data, newest first, optionally
+// only for one student (?id=..), one user (?email=..) or between dates
+// (?from=YYYY-MM-DD&to=YYYY-MM-DD)
+func GetAccessLog(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	studentID, email, from, to := query.Get("id"), query.Get("email"), query.Get("from"), query.Get("to")
+
+	docs, err := repo.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch access log", http.StatusInternalServerError)
+		return
+	}
+	entries := []AccessEntry{}
+	for _, doc := range docs {
+		var e AccessEntry
+		if doc["type"] != typeAccess || store.Decode(doc, &e) != nil {
+			continue
+		}
+		day := e.At
+		if len(day) > 10 {
+			day = day[:10]
+		}
+		if (email != "" && e.Email != email) || (from != "" && day < from) || (to != "" && day > to) {
+			continue
+		}
+		if studentID != "" && !contains(e.StudentIDs, studentID) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At > entries[j].At })
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
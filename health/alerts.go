@@ -0,0 +1,85 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"data-access/store"
+	"data-access/student"
+)
+
+// Alert is what staff looking after a student need to know: their
+// allergies, the medication they take at school and what to do in an
+// emergency. Conditions and notes stay confidential.
+type Alert struct {
+	StudentID             string       `json:"student_id"`
+	Name                  string       `json:"name"`
+	Class                 string       `json:"class"`
+	Section               string       `json:"section"`
+	Allergies             []Allergy    `json:"allergies"`
+	Medications           []Medication `json:"medications"`
+	EmergencyInstructions string       `json:"emergency_instructions,omitempty"`
+	// Severe is set when any allergy is severe or life threatening
+	Severe bool `json:"severe"`
+}
+
+// GetAlerts lists allergy and medication alerts for the students of a
+// class (?class=..&section=.., both optional), the most severe first. The
+// students shown are logged.
+func GetAlerts(w http.ResponseWriter, r *http.Request, repo Repository, students student.Repository) {
+	class, section := r.URL.Query().Get("class"), r.URL.Query().Get("section")
+
+	docs, err := repo.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch health records", http.StatusInternalServerError)
+		return
+	}
+	alerts := []Alert{}
+	var shown []string
+	for _, doc := range docs {
+		var rec Record
+		if doc["type"] != typeRecord || store.Decode(doc, &rec) != nil {
+			continue
+		}
+		if len(rec.Allergies) == 0 && len(rec.Medications) == 0 {
+			continue
+		}
+		s, err := students.Get(rec.StudentID)
+		if err != nil {
+			continue
+		}
+		a := Alert{
+			StudentID: rec.StudentID, Allergies: rec.Allergies, Medications: rec.Medications,
+			EmergencyInstructions: rec.EmergencyInstructions,
+		}
+		a.Name, _ = s["full_name"].(string)
+		a.Class, _ = s["class"].(string)
+		a.Section, _ = s["section"].(string)
+		if (class != "" && a.Class != class) || (section != "" && a.Section != section) {
+			continue
+		}
+		for _, allergy := range rec.Allergies {
+			if allergy.Severity == SeveritySevere || allergy.Severity == SeverityLifeThreatening {
+				a.Severe = true
+			}
+		}
+		alerts = append(alerts, a)
+		shown = append(shown, a.StudentID)
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		if alerts[i].Severe != alerts[j].Severe {
+			return alerts[i].Severe
+		}
+		return alerts[i].Name < alerts[j].Name
+	})
+	if len(shown) > 0 {
+		if err := logAccess(repo, r, ActionAlerts, shown); err != nil {
+			http.Error(w, "failed to record access", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(alerts)
+}
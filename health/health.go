@@ -0,0 +1,259 @@
+// Package health keeps students' confidential health records, which only
+// nurses and admins may read. Teachers and staff see just the allergy and
+// medication alerts they need to keep a student safe, and every access is
+// logged.
+package health
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"data-access/auth"
+	"data-access/store"
+	"data-access/student"
+)
+
+const typeRecord = "health_record"
+
+// Allergy severities
+const (
+	SeverityMild            = "mild"
+	SeverityModerate        = "moderate"
+	SeveritySevere          = "severe"
+	SeverityLifeThreatening = "life_threatening"
+)
+
+// Condition is a diagnosed condition. Its fields match the health_records
+// entries student documents used to carry.
+type Condition struct {
+	Condition string `json:"condition"`
+	Notes     string `json:"notes"`
+}
+
+// Allergy is something the student must avoid
+type Allergy struct {
+	Allergen string `json:"allergen"`
+	Reaction string `json:"reaction,omitempty"`
+	Severity string `json:"severity"`
+}
+
+// Medication is a medicine the student takes during school hours
+type Medication struct {
+	Name     string `json:"name"`
+	Dosage   string `json:"dosage,omitempty"`
+	Schedule string `json:"schedule,omitempty"` // e.g. "12:30 daily", "as needed"
+	// AdministeredBy is "nurse" or "self"
+	AdministeredBy string `json:"administered_by,omitempty"`
+}
+
+// Record is a student's health record
+type Record struct {
+	StudentID             string       `json:"student_id"`
+	BloodGroup            string       `json:"blood_group,omitempty"`
+	Conditions            []Condition  `json:"conditions"`
+	Allergies             []Allergy    `json:"allergies"`
+	Medications           []Medication `json:"medications"`
+	EmergencyInstructions string       `json:"emergency_instructions,omitempty"`
+	UpdatedBy             string       `json:"updated_by,omitempty"`
+	UpdatedAt             string       `json:"updated_at,omitempty"`
+}
+
+func recordID(studentID string) string {
+	return "health:" + studentID
+}
+
+func (rec Record) document() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                   typeRecord,
+		"student_id":             rec.StudentID,
+		"blood_group":            rec.BloodGroup,
+		"conditions":             rec.Conditions,
+		"allergies":              rec.Allergies,
+		"medications":            rec.Medications,
+		"emergency_instructions": rec.EmergencyInstructions,
+		"updated_by":             rec.UpdatedBy,
+		"updated_at":             rec.UpdatedAt,
+	}
+}
+
+// validSeverity reports whether an allergy severity is known
+func validSeverity(s string) bool {
+	switch s {
+	case SeverityMild, SeverityModerate, SeveritySevere, SeverityLifeThreatening:
+		return true
+	}
+	return false
+}
+
+// Load reads a student's health record
+func Load(repo Repository, studentID string) (Record, error) {
+	doc, err := repo.Get(recordID(studentID))
+	if err != nil {
+		return Record{}, err
+	}
+	var rec Record
+	if doc["type"] != typeRecord || store.Decode(doc, &rec) != nil {
+		return Record{}, store.ErrNotFound
+	}
+	return rec, nil
+}
+
+// GetHealthRecord returns a student's health record (?id=..). The read is
+// logged before anything is returned.
+func GetHealthRecord(w http.ResponseWriter, r *http.Request, repo Repository) {
+	studentID := r.URL.Query().Get("id")
+	if studentID == "" {
+		http.Error(w, "Student ID missing", http.StatusBadRequest)
+		return
+	}
+	rec, err := Load(repo, studentID)
+	if err != nil {
+		http.Error(w, "Health record not found", http.StatusNotFound)
+		return
+	}
+	if err := logAccess(repo, r, ActionView, []string{studentID}); err != nil {
+		http.Error(w, "failed to record access", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rec)
+}
+
+// SetHealthRecord creates or replaces a student's health record
+func SetHealthRecord(w http.ResponseWriter, r *http.Request, repo Repository, students student.Repository) {
+	var rec Record
+	if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if rec.StudentID == "" {
+		http.Error(w, "Student ID missing", http.StatusBadRequest)
+		return
+	}
+	for _, a := range rec.Allergies {
+		if a.Allergen == "" || !validSeverity(a.Severity) {
+			http.Error(w, "every allergy needs an allergen and a severity of mild, moderate, severe or life_threatening", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, m := range rec.Medications {
+		if m.Name == "" {
+			http.Error(w, "every medication needs a name", http.StatusBadRequest)
+			return
+		}
+	}
+	if _, err := students.Get(rec.StudentID); err != nil {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+	if rec.Conditions == nil {
+		rec.Conditions = []Condition{}
+	}
+	if rec.Allergies == nil {
+		rec.Allergies = []Allergy{}
+	}
+	if rec.Medications == nil {
+		rec.Medications = []Medication{}
+	}
+
+	caller, _ := auth.FromContext(r.Context())
+	rec.UpdatedBy = caller.Email
+	rec.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	id := recordID(rec.StudentID)
+	existing, err := repo.Get(id)
+	switch err {
+	case nil:
+		_, err = repo.Update(id, rec.document(), store.Rev(existing))
+	case store.ErrNotFound:
+		_, err = repo.Create(id, rec.document())
+	}
+	if err == store.ErrConflict {
+		http.Error(w, "health record was modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save health record", http.StatusInternalServerError)
+		return
+	}
+	if err := logAccess(repo, r, ActionUpdate, []string{rec.StudentID}); err != nil {
+		http.Error(w, "health record saved but the access could not be logged", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Health record saved successfully"})
+}
+
+// Migrate moves the health_records still held in student documents into
+// the health store, as conditions on each student's record, and removes
+// them from student_db. Students whose records can't be read are logged
+// and left as they are; the field is only removed once the conditions are
+// stored. It returns how many student documents were rewritten and is
+// safe to run repeatedly.
+func Migrate(students student.Repository, repo Repository) (int, error) {
+	docs, err := students.List()
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, doc := range docs {
+		if _, ok := doc["health_records"]; !ok {
+			continue
+		}
+		var legacy struct {
+			HealthRecords []Condition `json:"health_records"`
+		}
+		studentID, _ := doc["_id"].(string)
+		if err := store.Decode(doc, &legacy); err != nil {
+			// Left in place to be fixed by hand rather than dropped
+			log.Printf("Not migrating the health records of student %s: %v", studentID, err)
+			continue
+		}
+
+		if len(legacy.HealthRecords) > 0 {
+			rec, err := Load(repo, studentID)
+			switch err {
+			case nil:
+			case store.ErrNotFound:
+				rec = Record{StudentID: studentID, Allergies: []Allergy{}, Medications: []Medication{}}
+			default:
+				return moved, err
+			}
+			known := make(map[Condition]bool)
+			for _, c := range rec.Conditions {
+				known[c] = true
+			}
+			for _, c := range legacy.HealthRecords {
+				if !known[c] {
+					rec.Conditions = append(rec.Conditions, c)
+					known[c] = true
+				}
+			}
+			rec.UpdatedBy = "migration"
+			rec.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			existing, err := repo.Get(recordID(studentID))
+			switch err {
+			case nil:
+				_, err = repo.Update(recordID(studentID), rec.document(), store.Rev(existing))
+			case store.ErrNotFound:
+				_, err = repo.Create(recordID(studentID), rec.document())
+			}
+			if err != nil {
+				return moved, err
+			}
+		}
+
+		_, err := store.Modify(students, studentID, func(doc map[string]interface{}) error {
+			delete(doc, "health_records")
+			return nil
+		})
+		if err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
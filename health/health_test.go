@@ -0,0 +1,131 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"data-access/auth"
+	"data-access/student"
+)
+
+func TestMigrate(t *testing.T) {
+	students, repo := student.NewMemoryRepository(), NewMemoryRepository()
+	students.Create("s1", map[string]interface{}{
+		"full_name":      "Ann",
+		"health_records": []interface{}{map[string]interface{}{"condition": "Asthma", "notes": "Inhaler in bag"}},
+	})
+	// A record of the wrong shape can't be read
+	students.Create("s2", map[string]interface{}{
+		"full_name":      "Ben",
+		"health_records": []interface{}{"Diabetes"},
+	})
+
+	n, err := Migrate(students, repo)
+	if err != nil || n != 1 {
+		t.Fatalf("Migrate = %d, %v; want 1 student", n, err)
+	}
+	rec, err := Load(repo, "s1")
+	if err != nil || len(rec.Conditions) != 1 || rec.Conditions[0].Condition != "Asthma" {
+		t.Errorf("migrated record = %+v, %v", rec, err)
+	}
+	if doc, _ := students.Get("s1"); doc["health_records"] != nil {
+		t.Errorf("health_records still on the student: %v", doc)
+	}
+	if doc, _ := students.Get("s2"); doc["health_records"] == nil {
+		t.Errorf("unreadable health_records dropped: %v", doc)
+	}
+
+	if n, err := Migrate(students, repo); err != nil || n != 0 {
+		t.Errorf("second Migrate = %d, %v", n, err)
+	}
+}
+
+func TestGetAlerts(t *testing.T) {
+	students, repo := student.NewMemoryRepository(), NewMemoryRepository()
+	students.Create("s1", map[string]interface{}{"full_name": "Zoe", "class": "5"})
+	students.Create("s2", map[string]interface{}{"full_name": "Amy", "class": "5"})
+	students.Create("s3", map[string]interface{}{"full_name": "Ben", "class": "6"})
+	students.Create("s4", map[string]interface{}{"full_name": "Cal", "class": "5"})
+	records := []Record{
+		{StudentID: "s1", Allergies: []Allergy{{Allergen: "Pollen", Severity: SeverityMild}}},
+		{StudentID: "s2", Allergies: []Allergy{{Allergen: "Peanuts", Severity: SeverityLifeThreatening}}, EmergencyInstructions: "EpiPen"},
+		{StudentID: "s3", Medications: []Medication{{Name: "Insulin"}}},
+		{StudentID: "s4", Conditions: []Condition{{Condition: "Asthma"}}},
+	}
+	for _, rec := range records {
+		repo.Create(recordID(rec.StudentID), rec.document())
+	}
+
+	r := httptest.NewRequest("GET", "/health/alerts?class=5", nil)
+	r = r.WithContext(auth.NewContext(r.Context(), auth.Identity{ID: "n1", Email: "nurse@example.com", Role: auth.Nurse}))
+	w := httptest.NewRecorder()
+	GetAlerts(w, r, repo, students)
+	if w.Code != 200 {
+		t.Fatalf("GetAlerts = %d %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "Asthma") {
+		t.Errorf("conditions shown in alerts: %s", w.Body)
+	}
+	var alerts []Alert
+	json.NewDecoder(w.Body).Decode(&alerts)
+	// s3 is in another class and s4 has nothing to alert on
+	if len(alerts) != 2 || alerts[0].StudentID != "s2" || !alerts[0].Severe || alerts[1].StudentID != "s1" || alerts[1].Severe {
+		t.Fatalf("alerts = %+v, want s2 (severe) then s1", alerts)
+	}
+
+	entries := accessLog(t, repo, "")
+	if len(entries) != 1 || entries[0].Action != ActionAlerts || entries[0].UserID != "n1" {
+		t.Fatalf("access log = %+v", entries)
+	}
+	if shown := entries[0].StudentIDs; len(shown) != 2 || !contains(shown, "s1") || !contains(shown, "s2") {
+		t.Errorf("access log = %+v", entries)
+	}
+}
+
+func accessLog(t *testing.T, repo Repository, query string) []AccessEntry {
+	w := httptest.NewRecorder()
+	GetAccessLog(w, httptest.NewRequest("GET", "/health/access?"+query, nil), repo)
+	if w.Code != 200 {
+		t.Fatalf("GetAccessLog(%s) = %d %s", query, w.Code, w.Body)
+	}
+	var entries []AccessEntry
+	json.NewDecoder(w.Body).Decode(&entries)
+	return entries
+}
+
+func TestGetAccessLog(t *testing.T) {
+	repo := NewMemoryRepository()
+	entry := func(id, at, email string, students ...string) {
+		repo.Create(id, map[string]interface{}{
+			"type": typeAccess, "at": at, "action": ActionView, "email": email, "student_ids": students,
+		})
+	}
+	entry("access:1", "2024-03-01T09:00:00Z", "ann@example.com", "s1")
+	entry("access:2", "2024-03-02T09:00:00Z", "ben@example.com", "s1", "s2")
+	entry("access:3", "2024-03-03T09:00:00Z", "ann@example.com", "s2")
+	repo.Create(recordID("s1"), Record{StudentID: "s1"}.document())
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "3,2,1"},
+		{"id=s1", "2,1"},
+		{"email=ann@example.com", "3,1"},
+		{"from=2024-03-02", "3,2"},
+		{"to=2024-03-02", "2,1"},
+		{"from=2024-03-02&to=2024-03-02&id=s2", "2"},
+		{"id=s9", ""},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range accessLog(t, repo, tt.query) {
+			got = append(got, e.At[9:10])
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("GetAccessLog(%s) = %v, want %s", tt.query, got, tt.want)
+		}
+	}
+}
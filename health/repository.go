@@ -0,0 +1,25 @@
+package health
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding students' confidential health
+// records and the log of who accessed them. It is kept apart from
+// student_db so that access to it can be restricted.
+const DBName = "health_db"
+
+// Repository is the storage backend for health documents
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the health_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
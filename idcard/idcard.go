@@ -0,0 +1,220 @@
+package idcard
+
+import (
+	"fmt"
+	"image/png"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/auth"
+	"data-access/checkin"
+	"data-access/pdf"
+	"data-access/qrtoken"
+	"data-access/staff"
+	"data-access/student"
+	"data-access/teacher"
+)
+
+// Batch layout: cards are printed at their real size, two across and five
+// down an A4 sheet
+const (
+	mmToPt      = 72 / 25.4
+	cardWidthPt = 85.6 * mmToPt
+	cardHeight  = 54 * mmToPt
+	columns     = 2
+	rows        = 5
+)
+
+// cardFor builds the card for a person document. A fresh QR token is signed
+// for every card so the printed validity date matches the code.
+func cardFor(kind string, doc map[string]interface{}, signer *qrtoken.Signer) (Card, error) {
+	str := func(key string) string {
+		s, _ := doc[key].(string)
+		return s
+	}
+	id := str("_id")
+
+	var details []string
+	switch kind {
+	case student.Kind:
+		class := "Class " + str("class")
+		if section := str("section"); section != "" {
+			class += " - " + section
+		}
+		details = append(details, class)
+		if roll := str("roll_number"); roll != "" {
+			details = append(details, "Roll No: "+roll)
+		}
+	case teacher.Kind:
+		details = append(details, str("department"))
+	case staff.Kind:
+		details = append(details, str("job_title"), str("department"))
+	}
+
+	token, claims, err := signer.Sign(id, kind, time.Now())
+	if err != nil {
+		return Card{}, err
+	}
+	return Card{
+		Kind:       kind,
+		ID:         id,
+		Name:       str("full_name"),
+		Details:    details,
+		ValidUntil: time.Unix(claims.ExpiresAt, 0).UTC(),
+		Token:      token,
+	}, nil
+}
+
+// GetCard renders the ID card of one student, teacher or staff member as PNG
+func GetCard(w http.ResponseWriter, r *http.Request, dir checkin.Directory, signer *qrtoken.Signer) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "ID missing", http.StatusBadRequest)
+		return
+	}
+
+	caller, _ := auth.FromContext(r.Context())
+	kind, ok := kindOf(caller, r.URL.Query().Get("kind"))
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	kind, repo, err := dir.Resolve(id, kind)
+	if err != nil {
+		http.Error(w, "no student, teacher or staff member with that ID", http.StatusNotFound)
+		return
+	}
+	doc, err := repo.Get(id)
+	if err != nil {
+		http.Error(w, "failed to fetch card holder", http.StatusInternalServerError)
+		return
+	}
+
+	card, err := cardFor(kind, doc, signer)
+	if err != nil {
+		http.Error(w, "failed to sign QR code token", http.StatusInternalServerError)
+		return
+	}
+	img, err := Render(card)
+	if err != nil {
+		http.Error(w, "failed to render ID card", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", "idcard-"+id+".png"))
+	w.WriteHeader(http.StatusOK)
+	png.Encode(w, img)
+}
+
+// kindOf returns the kind of card holder a request is about. Students,
+// teachers and staff can only reach their own kind of record, since IDs are
+// not unique across the stores; admins name it with kind or leave it empty
+// to search them all. ok is false when the caller asked for another kind.
+func kindOf(caller auth.Identity, kind string) (string, bool) {
+	own := map[auth.Role]string{
+		auth.Student: student.Kind,
+		auth.Faculty: teacher.Kind,
+		auth.Staff:   staff.Kind,
+	}[caller.Role]
+	if own == "" {
+		return kind, true
+	}
+	return own, kind == "" || kind == own
+}
+
+// GetBatch renders a printable PDF of ID cards for a whole class
+// (kind=student&class=..&section=..) or department (kind=teacher|staff&department=..)
+func GetBatch(w http.ResponseWriter, r *http.Request, dir checkin.Directory, signer *qrtoken.Signer) {
+	query := r.URL.Query()
+	kind := query.Get("kind")
+
+	var filters map[string]string
+	switch kind {
+	case student.Kind:
+		if query.Get("class") == "" {
+			http.Error(w, "class missing", http.StatusBadRequest)
+			return
+		}
+		filters = map[string]string{"class": query.Get("class"), "section": query.Get("section")}
+	case teacher.Kind, staff.Kind:
+		if query.Get("department") == "" {
+			http.Error(w, "department missing", http.StatusBadRequest)
+			return
+		}
+		filters = map[string]string{"department": query.Get("department")}
+	default:
+		http.Error(w, "kind must be student, teacher or staff", http.StatusBadRequest)
+		return
+	}
+
+	docs, err := dir.Repository(kind).List()
+	if err != nil {
+		http.Error(w, "failed to fetch card holders", http.StatusInternalServerError)
+		return
+	}
+
+	var cards []Card
+	for _, doc := range docs {
+		if !matches(doc, filters) {
+			continue
+		}
+		card, err := cardFor(kind, doc, signer)
+		if err != nil {
+			http.Error(w, "failed to sign QR code token", http.StatusInternalServerError)
+			return
+		}
+		cards = append(cards, card)
+	}
+	if len(cards) == 0 {
+		http.Error(w, "no card holders match", http.StatusNotFound)
+		return
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Name < cards[j].Name })
+
+	doc := pdf.New()
+	marginX := (pdf.A4Width - columns*cardWidthPt) / 3
+	marginY := (pdf.A4Height - rows*cardHeight) / 6
+	var page *pdf.Page
+	for i, card := range cards {
+		slot := i % (columns * rows)
+		if slot == 0 {
+			page = doc.AddPage(pdf.A4Width, pdf.A4Height)
+		}
+		img, err := Render(card)
+		if err != nil {
+			http.Error(w, "failed to render ID card", http.StatusInternalServerError)
+			return
+		}
+		col, row := float64(slot%columns), float64(slot/columns)
+		x := marginX + col*(cardWidthPt+marginX)
+		y := marginY + row*(cardHeight+marginY)
+		page.Image(img, x, y, cardWidthPt, cardHeight)
+		page.Rect(x, y, cardWidthPt, cardHeight, -1) // cutting guide
+	}
+
+	data, err := doc.Bytes()
+	if err != nil {
+		http.Error(w, "failed to build PDF", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "idcards-"+kind+".pdf"))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// matches reports whether every non-empty filter equals the document field
+func matches(doc map[string]interface{}, filters map[string]string) bool {
+	for key, want := range filters {
+		if want == "" {
+			continue
+		}
+		if got, _ := doc[key].(string); got != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package idcard
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Card dimensions in pixels, ISO/IEC 7810 ID-1 (85.6 x 54 mm) at 10px/mm
+const (
+	Width  = 856
+	Height = 540
+)
+
+var (
+	headerColor = color.RGBA{0x1f, 0x3a, 0x68, 0xff}
+	textColor   = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	mutedColor  = color.RGBA{0x66, 0x66, 0x66, 0xff}
+	photoColor  = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+)
+
+// Card is everything printed on an ID card
+type Card struct {
+	Kind       string
+	ID         string
+	Name       string
+	Details    []string // class, job title, department...
+	ValidUntil time.Time
+	// Token is the signed QR payload
+	Token string
+}
+
+// Render draws the card
+func Render(c Card) (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	// Header band
+	fill(img, image.Rect(0, 0, Width, 96), headerColor)
+	drawText(img, 32, 22, strings.ToUpper(c.Kind)+" ID CARD", 4, color.White)
+
+	// Photo placeholder
+	photo := image.Rect(32, 128, 202, 342)
+	fill(img, photo, photoColor)
+	outline(img, photo, mutedColor)
+	drawText(img, photo.Min.X+50, photo.Min.Y+94, "PHOTO", 2, mutedColor)
+
+	// Holder details
+	y := 132
+	drawText(img, 230, y, truncate(c.Name, 26), 2, textColor)
+	y += 48
+	drawText(img, 230, y, "ID: "+truncate(c.ID, 28), 2, textColor)
+	for _, line := range c.Details {
+		if line == "" {
+			continue
+		}
+		y += 40
+		drawText(img, 230, y, truncate(line, 30), 2, mutedColor)
+	}
+	drawText(img, 32, 372, "Valid until: "+c.ValidUntil.Format("2006-01-02"), 2, textColor)
+
+	// QR code
+	qr, err := qrcode.New(c.Token, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	qr.DisableBorder = true
+	draw.Draw(img, image.Rect(618, 120, 828, 330), qr.Image(210), image.Point{}, draw.Src)
+
+	// Footer
+	fill(img, image.Rect(0, 500, Width, Height), headerColor)
+	drawText(img, 32, 507, "If found, please return to the school office.", 2, color.White)
+
+	return img, nil
+}
+
+// drawText renders s with the 7x13 bitmap font, scaled up by an integer
+// factor; (x, y) is the top-left corner of the text
+func drawText(dst draw.Image, x, y int, s string, scale int, c color.Color) {
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, s).Ceil()
+	if width == 0 {
+		return
+	}
+	tmp := image.NewRGBA(image.Rect(0, 0, width, face.Height))
+	d := &font.Drawer{
+		Dst:  tmp,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	d.DrawString(s)
+	target := image.Rect(x, y, x+width*scale, y+face.Height*scale)
+	xdraw.NearestNeighbor.Scale(dst, target, tmp, tmp.Bounds(), draw.Over, nil)
+}
+
+func fill(dst draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func outline(dst draw.Image, r image.Rectangle, c color.Color) {
+	fill(dst, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+2), c)
+	fill(dst, image.Rect(r.Min.X, r.Max.Y-2, r.Max.X, r.Max.Y), c)
+	fill(dst, image.Rect(r.Min.X, r.Min.Y, r.Min.X+2, r.Max.Y), c)
+	fill(dst, image.Rect(r.Max.X-2, r.Min.Y, r.Max.X, r.Max.Y), c)
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,318 @@
+package incident
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"data-access/auth"
+	"data-access/store"
+)
+
+// Who an escalation notifies
+const (
+	NotifyPrincipal = "principal"
+	NotifyParent    = "parent"
+)
+
+const (
+	typeRules      = "escalation_rules"
+	typeEscalation = "escalation"
+	rulesID        = "escalation_rules"
+)
+
+// Rule escalates a student once they have been the subject of Threshold
+// incidents in a term, counting only incidents at least as serious as
+// MinSeverity and, if set, of Category. It fires again at every further
+// multiple of Threshold.
+type Rule struct {
+	Name        string   `json:"name"`
+	Threshold   int      `json:"threshold"`
+	MinSeverity string   `json:"min_severity,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	Notify      []string `json:"notify"`
+}
+
+// DefaultRules apply until an admin sets the school's own
+var DefaultRules = []Rule{
+	{Name: "repeated", Threshold: 3, Notify: []string{NotifyPrincipal, NotifyParent}},
+	{Name: "serious", Threshold: 1, MinSeverity: SeverityMajor, Notify: []string{NotifyPrincipal, NotifyParent}},
+}
+
+// matches reports whether an incident counts towards the rule
+func (rule Rule) matches(inc Incident) bool {
+	return (rule.MinSeverity == "" || inc.AtLeast(rule.MinSeverity)) &&
+		(rule.Category == "" || inc.Category == rule.Category)
+}
+
+func validateRules(rules []Rule) error {
+	seen := make(map[string]bool)
+	for i, rule := range rules {
+		switch {
+		case rule.Name == "" || strings.Contains(rule.Name, ":"):
+			return fmt.Errorf("rule %d: name is required and can't contain ':'", i+1)
+		case seen[rule.Name]:
+			return fmt.Errorf("rule %q is defined twice", rule.Name)
+		case rule.Threshold < 1:
+			return fmt.Errorf("rule %q: threshold must be at least 1", rule.Name)
+		case rule.MinSeverity != "" && severityRank[rule.MinSeverity] == 0:
+			return fmt.Errorf("rule %q: unknown severity %q", rule.Name, rule.MinSeverity)
+		case rule.Category != "" && !validCategory(rule.Category):
+			return fmt.Errorf("rule %q: unknown category %q", rule.Name, rule.Category)
+		case len(rule.Notify) == 0:
+			return fmt.Errorf("rule %q: notify must name principal and/or parent", rule.Name)
+		}
+		for _, who := range rule.Notify {
+			if who != NotifyPrincipal && who != NotifyParent {
+				return fmt.Errorf("rule %q: can only notify principal or parent", rule.Name)
+			}
+		}
+		seen[rule.Name] = true
+	}
+	return nil
+}
+
+// LoadRules returns the school's escalation rules, or DefaultRules if none
+// have been set
+func LoadRules(repo Repository) ([]Rule, error) {
+	doc, err := repo.Get(rulesID)
+	if err == store.ErrNotFound {
+		return DefaultRules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := store.Decode(doc, &set); err != nil {
+		return nil, err
+	}
+	return set.Rules, nil
+}
+
+// Escalation records that a student crossed a rule's threshold in a term
+// and who was told
+type Escalation struct {
+	ID          string   `json:"id"`
+	Rule        string   `json:"rule"`
+	StudentID   string   `json:"student_id"`
+	StudentName string   `json:"student_name"`
+	Class       string   `json:"class,omitempty"`
+	Section     string   `json:"section,omitempty"`
+	Term        string   `json:"term"`
+	Count       int      `json:"count"`
+	IncidentIDs []string `json:"incident_ids"`
+	Notify      []string `json:"notify"`
+	NotifiedTo  []string `json:"notified_to"`
+	NotifyError string   `json:"notify_error,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// Subject is the subject line of the notice sent about the escalation
+func (e Escalation) Subject() string {
+	return fmt.Sprintf("Disciplinary escalation: %s (%s)", e.StudentName, e.Term)
+}
+
+// Body is the text of the notice sent about the escalation
+func (e Escalation) Body() string {
+	noun := "incidents"
+	if e.Count == 1 {
+		noun = "incident"
+	}
+	return fmt.Sprintf("%s of class %s %s has been the subject of %d %s this term (%s), "+
+		"which meets the school's %q escalation rule.\n\nIncidents: %s\n",
+		e.StudentName, e.Class, e.Section, e.Count, noun, e.Term, e.Rule, strings.Join(e.IncidentIDs, ", "))
+}
+
+// Notify delivers an escalation to the people its rule names and returns
+// the addresses it reached
+type Notify func(e Escalation) ([]string, error)
+
+func escalationID(rule, term, studentID string, count int) string {
+	return fmt.Sprintf("escalation:%s:%s:%s:%d", rule, term, studentID, count)
+}
+
+// escalate applies the escalation rules to the students a newly reported
+// incident is about. An escalation is stored once per rule, term, student
+// and count, so reapplying the rules never notifies twice.
+func escalate(repo Repository, inc Incident, notify Notify) ([]Escalation, error) {
+	escalations := []Escalation{}
+	subjects := inc.Subjects()
+	if len(subjects) == 0 {
+		return escalations, nil
+	}
+	rules, err := LoadRules(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range subjects {
+		termIncidents, err := List(repo, func(other Incident) bool {
+			if other.Term != inc.Term {
+				return false
+			}
+			for _, p := range other.Subjects() {
+				if p.PersonID == s.PersonID {
+					return true
+				}
+			}
+			return false
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			if !rule.matches(inc) {
+				continue
+			}
+			var ids []string
+			for _, other := range termIncidents {
+				if rule.matches(other) {
+					ids = append(ids, other.ID)
+				}
+			}
+			if len(ids)%rule.Threshold != 0 {
+				continue
+			}
+
+			e := Escalation{
+				ID: escalationID(rule.Name, inc.Term, s.PersonID, len(ids)), Rule: rule.Name,
+				StudentID: s.PersonID, StudentName: s.Name, Class: s.Class, Section: s.Section,
+				Term: inc.Term, Count: len(ids), IncidentIDs: ids, Notify: rule.Notify,
+				NotifiedTo: []string{}, CreatedAt: time.Now().UTC().Format(time.RFC3339),
+			}
+			doc := map[string]interface{}{
+				"type":         typeEscalation,
+				"rule":         e.Rule,
+				"student_id":   e.StudentID,
+				"student_name": e.StudentName,
+				"class":        e.Class,
+				"section":      e.Section,
+				"term":         e.Term,
+				"count":        e.Count,
+				"incident_ids": e.IncidentIDs,
+				"notify":       e.Notify,
+				"notified_to":  e.NotifiedTo,
+				"created_at":   e.CreatedAt,
+			}
+			if _, err := repo.Create(e.ID, doc); err == store.ErrConflict {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			if notify != nil {
+				to, err := notify(e)
+				if to != nil {
+					e.NotifiedTo = to
+				}
+				if err != nil {
+					e.NotifyError = err.Error()
+				}
+				_, err = store.Modify(repo, e.ID, func(doc map[string]interface{}) error {
+					doc["notified_to"] = e.NotifiedTo
+					if e.NotifyError != "" {
+						doc["notify_error"] = e.NotifyError
+					}
+					return nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+			escalations = append(escalations, e)
+		}
+	}
+	return escalations, nil
+}
+
+// GetEscalations lists escalations, newest first, optionally for one
+// ?term=.. or ?student_id=..
+func GetEscalations(w http.ResponseWriter, r *http.Request, repo Repository) {
+	term, studentID := r.URL.Query().Get("term"), r.URL.Query().Get("student_id")
+	docs, err := repo.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch escalations", http.StatusInternalServerError)
+		return
+	}
+	escalations := []Escalation{}
+	for _, doc := range docs {
+		var e Escalation
+		if doc["type"] != typeEscalation || store.Decode(doc, &e) != nil {
+			continue
+		}
+		if (term != "" && e.Term != term) || (studentID != "" && e.StudentID != studentID) {
+			continue
+		}
+		e.ID, _ = doc["_id"].(string)
+		escalations = append(escalations, e)
+	}
+	sort.Slice(escalations, func(i, j int) bool { return escalations[i].CreatedAt > escalations[j].CreatedAt })
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(escalations)
+}
+
+// GetRules returns the escalation rules in force
+func GetRules(w http.ResponseWriter, r *http.Request, repo Repository) {
+	rules, err := LoadRules(repo)
+	if err != nil {
+		http.Error(w, "Failed to fetch escalation rules", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rules)
+}
+
+// SetRules replaces the escalation rules ({"rules": [{"name", "threshold",
+// "min_severity", "category", "notify": ["principal", "parent"]}]}). An
+// empty list turns escalation off.
+func SetRules(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var request struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if request.Rules == nil {
+		request.Rules = []Rule{}
+	}
+	if err := validateRules(request.Rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	caller, _ := auth.FromContext(r.Context())
+	doc := map[string]interface{}{
+		"type":       typeRules,
+		"rules":      request.Rules,
+		"updated_by": caller.Email,
+		"updated_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	existing, err := repo.Get(rulesID)
+	switch err {
+	case nil:
+		_, err = repo.Update(rulesID, doc, store.Rev(existing))
+	case store.ErrNotFound:
+		_, err = repo.Create(rulesID, doc)
+	}
+	if err == store.ErrConflict {
+		http.Error(w, "escalation rules were modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save escalation rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Escalation rules saved successfully",
+		"rules":   request.Rules,
+	})
+}
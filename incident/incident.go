@@ -0,0 +1,534 @@
+// Package incident tracks disciplinary and behavioral incidents as records
+// of their own, rather than as free text on a student, and escalates
+// students who keep turning up in them.
+package incident
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/auth"
+	"data-access/checkin"
+	"data-access/domain"
+	"data-access/store"
+	"data-access/student"
+)
+
+// Severities, from least to most serious
+const (
+	SeverityMinor    = "minor"
+	SeverityModerate = "moderate"
+	SeverityMajor    = "major"
+	SeveritySevere   = "severe"
+)
+
+var severityRank = map[string]int{SeverityMinor: 1, SeverityModerate: 2, SeverityMajor: 3, SeveritySevere: 4}
+
+// Categories an incident can be filed under
+var Categories = []string{
+	"bullying", "fighting", "disruption", "defiance", "academic_dishonesty",
+	"vandalism", "theft", "substance", "attendance", "safety", "other",
+}
+
+// Roles people play in an incident. Only subjects count towards a
+// student's escalations.
+const (
+	RoleSubject = "subject"
+	RoleVictim  = "victim"
+	RoleWitness = "witness"
+)
+
+// Incident statuses
+const (
+	StatusOpen     = "open"
+	StatusResolved = "resolved"
+)
+
+const typeIncident = "incident"
+
+// Involvement is someone who took part in an incident. Name, class and
+// section are copied from their record when the incident is reported.
+type Involvement struct {
+	PersonID string `json:"person_id"`
+	Kind     string `json:"kind"` // student, teacher or staff; found if empty
+	Role     string `json:"role"`
+	Name     string `json:"name"`
+	Class    string `json:"class,omitempty"`
+	Section  string `json:"section,omitempty"`
+}
+
+// Action is something done in response to an incident, e.g. a detention
+// or a meeting with the parents
+type Action struct {
+	Date    string `json:"date"`
+	Action  string `json:"action"`
+	Notes   string `json:"notes,omitempty"`
+	TakenBy string `json:"taken_by"`
+}
+
+// Incident is one disciplinary or behavioral incident
+type Incident struct {
+	ID          string        `json:"id"`
+	Date        string        `json:"date"`
+	Term        string        `json:"term"`
+	Category    string        `json:"category"`
+	Severity    string        `json:"severity"`
+	Description string        `json:"description"`
+	Location    string        `json:"location,omitempty"`
+	Involved    []Involvement `json:"involved"`
+	Actions     []Action      `json:"actions"`
+	Status      string        `json:"status"`
+	ReportedBy  string        `json:"reported_by"`
+	ReportedAt  string        `json:"reported_at"`
+}
+
+// Involves reports whether the person took part in the incident, in any
+// role
+func (inc Incident) Involves(personID string) bool {
+	for _, p := range inc.Involved {
+		if p.PersonID == personID {
+			return true
+		}
+	}
+	return false
+}
+
+// Subjects returns the students whose conduct the incident is about
+func (inc Incident) Subjects() []Involvement {
+	var subjects []Involvement
+	for _, p := range inc.Involved {
+		if p.Role == RoleSubject && p.Kind == student.Kind {
+			subjects = append(subjects, p)
+		}
+	}
+	return subjects
+}
+
+// AtLeast reports whether the incident is at least as serious as severity
+func (inc Incident) AtLeast(severity string) bool {
+	return severityRank[inc.Severity] >= severityRank[severity]
+}
+
+func (inc Incident) document() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        typeIncident,
+		"date":        inc.Date,
+		"term":        inc.Term,
+		"category":    inc.Category,
+		"severity":    inc.Severity,
+		"description": inc.Description,
+		"location":    inc.Location,
+		"involved":    inc.Involved,
+		"actions":     inc.Actions,
+		"status":      inc.Status,
+		"reported_by": inc.ReportedBy,
+		"reported_at": inc.ReportedAt,
+	}
+}
+
+func newIncidentID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "incident-" + hex.EncodeToString(b)
+}
+
+func validCategory(category string) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the reported fields, before the people involved are
+// looked up
+func (inc *Incident) validate() error {
+	if _, err := time.Parse(domain.DateLayout, inc.Date); err != nil {
+		return errors.New("date must be formatted as YYYY-MM-DD")
+	}
+	if inc.Term == "" || inc.Description == "" {
+		return errors.New("term and description are required")
+	}
+	if !validCategory(inc.Category) {
+		return fmt.Errorf("unknown category %q", inc.Category)
+	}
+	if severityRank[inc.Severity] == 0 {
+		return errors.New("severity must be minor, moderate, major or severe")
+	}
+	if len(inc.Involved) == 0 {
+		return errors.New("at least one person must be involved")
+	}
+	seen := make(map[string]bool)
+	for i, p := range inc.Involved {
+		if p.PersonID == "" {
+			return fmt.Errorf("involved %d: person_id missing", i+1)
+		}
+		if p.Role != RoleSubject && p.Role != RoleVictim && p.Role != RoleWitness {
+			return fmt.Errorf("involved %d: role must be subject, victim or witness", i+1)
+		}
+		if seen[p.PersonID] {
+			return fmt.Errorf("%s is listed twice", p.PersonID)
+		}
+		seen[p.PersonID] = true
+	}
+	return nil
+}
+
+// resolve looks up everyone involved and fills in their kind, name, class
+// and section
+func resolve(people checkin.Directory, involved []Involvement) error {
+	for i := range involved {
+		p := &involved[i]
+		kind, repo, err := people.Resolve(p.PersonID, p.Kind)
+		if err == store.ErrNotFound {
+			return fmt.Errorf("%s not found", p.PersonID)
+		}
+		if err != nil {
+			return err
+		}
+		doc, err := repo.Get(p.PersonID)
+		if err != nil {
+			return err
+		}
+		p.Kind = kind
+		p.Name, _ = doc["full_name"].(string)
+		p.Class, _ = doc["class"].(string)
+		p.Section, _ = doc["section"].(string)
+	}
+	return nil
+}
+
+// List returns the incidents matching keep, newest first
+func List(repo Repository, keep func(Incident) bool) ([]Incident, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	incidents := []Incident{}
+	for _, doc := range docs {
+		var inc Incident
+		if doc["type"] != typeIncident || store.Decode(doc, &inc) != nil {
+			continue
+		}
+		inc.ID, _ = doc["_id"].(string)
+		if keep(inc) {
+			incidents = append(incidents, inc)
+		}
+	}
+	sort.SliceStable(incidents, func(i, j int) bool {
+		if incidents[i].Date != incidents[j].Date {
+			return incidents[i].Date > incidents[j].Date
+		}
+		return incidents[i].ReportedAt > incidents[j].ReportedAt
+	})
+	return incidents, nil
+}
+
+// Load reads one incident
+func Load(repo Repository, id string) (Incident, error) {
+	doc, err := repo.Get(id)
+	if err != nil {
+		return Incident{}, err
+	}
+	var inc Incident
+	if doc["type"] != typeIncident || store.Decode(doc, &inc) != nil {
+		return Incident{}, store.ErrNotFound
+	}
+	inc.ID = id
+	return inc, nil
+}
+
+// ReportIncident records a new incident ({"date", "term", "category",
+// "severity", "description", "location", "involved": [{"person_id",
+// "kind", "role"}], "actions": [..]}) and applies the escalation rules to
+// the students it is about
+func ReportIncident(w http.ResponseWriter, r *http.Request, repo Repository, people checkin.Directory, notify Notify) {
+	var inc Incident
+	if err := json.NewDecoder(r.Body).Decode(&inc); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := inc.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := resolve(people, inc.Involved); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	caller, _ := auth.FromContext(r.Context())
+	now := time.Now().UTC()
+	inc.ID = newIncidentID()
+	inc.Status = StatusOpen
+	inc.ReportedBy = caller.Email
+	inc.ReportedAt = now.Format(time.RFC3339)
+	if inc.Actions == nil {
+		inc.Actions = []Action{}
+	}
+	for i := range inc.Actions {
+		if inc.Actions[i].Date == "" {
+			inc.Actions[i].Date = now.Format(domain.DateLayout)
+		}
+		inc.Actions[i].TakenBy = caller.Email
+	}
+	if _, err := repo.Create(inc.ID, inc.document()); err != nil {
+		http.Error(w, "failed to save incident", http.StatusInternalServerError)
+		return
+	}
+
+	escalations, err := escalate(repo, inc, notify)
+	if err != nil {
+		http.Error(w, "incident saved but escalation rules could not be applied", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Incident reported successfully",
+		"incident":    inc,
+		"escalations": escalations,
+	})
+}
+
+// GetIncident returns one incident (?id=..)
+func GetIncident(w http.ResponseWriter, r *http.Request, repo Repository) {
+	inc, err := Load(repo, r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(inc)
+}
+
+// GetIncidents lists incidents, newest first, filtered by any of
+// ?student_id=.., class, section, term, category, status, severity (the
+// least serious to include) and from/to dates
+func GetIncidents(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	studentID, class, section := query.Get("student_id"), query.Get("class"), query.Get("section")
+	term, category, status := query.Get("term"), query.Get("category"), query.Get("status")
+	severity, from, to := query.Get("severity"), query.Get("from"), query.Get("to")
+	if severity != "" && severityRank[severity] == 0 {
+		http.Error(w, "severity must be minor, moderate, major or severe", http.StatusBadRequest)
+		return
+	}
+
+	incidents, err := List(repo, func(inc Incident) bool {
+		switch {
+		case studentID != "" && !inc.Involves(studentID),
+			term != "" && inc.Term != term,
+			category != "" && inc.Category != category,
+			status != "" && inc.Status != status,
+			severity != "" && !inc.AtLeast(severity),
+			from != "" && inc.Date < from,
+			to != "" && inc.Date > to:
+			return false
+		}
+		if class == "" && section == "" {
+			return true
+		}
+		for _, p := range inc.Involved {
+			if p.Kind == student.Kind && (class == "" || p.Class == class) && (section == "" || p.Section == section) {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		http.Error(w, "Failed to fetch incidents", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(incidents)
+}
+
+// GetStudentIncidents returns the incidents a student was involved in
+// (?id=.., optionally &term=..) with how many they were the subject of per
+// term. Students and parents only see their own part in each incident.
+func GetStudentIncidents(w http.ResponseWriter, r *http.Request, repo Repository) {
+	studentID, term := r.URL.Query().Get("id"), r.URL.Query().Get("term")
+	if studentID == "" {
+		http.Error(w, "Student ID missing", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	redact := caller.Role == auth.Student || caller.Role == auth.Parent
+
+	incidents, err := List(repo, func(inc Incident) bool {
+		return inc.Involves(studentID) && (term == "" || inc.Term == term)
+	})
+	if err != nil {
+		http.Error(w, "Failed to fetch incidents", http.StatusInternalServerError)
+		return
+	}
+	perTerm := map[string]int{}
+	for i, inc := range incidents {
+		for _, p := range inc.Involved {
+			if p.PersonID == studentID && p.Role == RoleSubject {
+				perTerm[inc.Term]++
+			}
+			if redact && p.PersonID == studentID {
+				incidents[i].Involved = []Involvement{p}
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"student_id": studentID,
+		"per_term":   perTerm,
+		"incidents":  incidents,
+	})
+}
+
+var errResolved = errors.New("incident is resolved")
+
+// RecordAction adds an action taken on an incident ({"incident_id",
+// "action", "notes", "date", "resolve": true to close the incident})
+func RecordAction(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var request struct {
+		IncidentID string `json:"incident_id"`
+		Action
+		Resolve bool `json:"resolve"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if request.Action.Action == "" {
+		http.Error(w, "action is required", http.StatusBadRequest)
+		return
+	}
+	if request.Date == "" {
+		request.Date = time.Now().Format(domain.DateLayout)
+	}
+	if _, err := time.Parse(domain.DateLayout, request.Date); err != nil {
+		http.Error(w, "date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	request.TakenBy = caller.Email
+
+	var inc Incident
+	_, err := store.Modify(repo, request.IncidentID, func(doc map[string]interface{}) error {
+		inc = Incident{}
+		if doc["type"] != typeIncident || store.Decode(doc, &inc) != nil {
+			return store.ErrNotFound
+		}
+		if inc.Status == StatusResolved {
+			return errResolved
+		}
+		inc.Actions = append(inc.Actions, request.Action)
+		doc["actions"] = inc.Actions
+		if request.Resolve {
+			inc.Status = StatusResolved
+			doc["status"] = inc.Status
+		}
+		return nil
+	})
+	switch {
+	case err == store.ErrNotFound:
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	case err == errResolved:
+		http.Error(w, "incident is already resolved", http.StatusConflict)
+		return
+	case err == store.ErrConflict:
+		http.Error(w, "incident was modified concurrently, retry", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "failed to save incident", http.StatusInternalServerError)
+		return
+	}
+	inc.ID = request.IncidentID
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Action recorded",
+		"incident": inc,
+	})
+}
+
+// legacyID names the incident migrated from the i-th behavioral record of
+// a student, so running Migrate again doesn't duplicate it
+func legacyID(studentID string, i int) string {
+	return fmt.Sprintf("incident-legacy-%s-%d", studentID, i)
+}
+
+// Migrate turns the behavioral_records still held in student documents
+// into resolved minor incidents with the student as subject, and removes
+// them from student_db. The old records carry no term, category or
+// severity, so those are left empty, "other" and minor; migrated incidents
+// don't trigger escalations. Students whose records can't be read are
+// logged and left as they are; the field is only removed once all of a
+// student's records are stored as incidents. It returns how many student
+// documents were rewritten and is safe to run repeatedly.
+func Migrate(students student.Repository, repo Repository) (int, error) {
+	docs, err := students.List()
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, doc := range docs {
+		if _, ok := doc["behavioral_records"]; !ok {
+			continue
+		}
+		var legacy struct {
+			FullName          string                     `json:"full_name"`
+			Class             string                     `json:"class"`
+			Section           string                     `json:"section"`
+			BehavioralRecords []student.BehavioralRecord `json:"behavioral_records"`
+		}
+		studentID, _ := doc["_id"].(string)
+		if err := store.Decode(doc, &legacy); err != nil {
+			// Left in place to be fixed by hand rather than dropped
+			log.Printf("Not migrating the behavioral records of student %s: %v", studentID, err)
+			continue
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		for i, rec := range legacy.BehavioralRecords {
+			inc := Incident{
+				Date:        rec.Date.Format(domain.DateLayout),
+				Category:    "other",
+				Severity:    SeverityMinor,
+				Description: rec.Incident,
+				Involved: []Involvement{{
+					PersonID: studentID, Kind: student.Kind, Role: RoleSubject,
+					Name: legacy.FullName, Class: legacy.Class, Section: legacy.Section,
+				}},
+				Actions:    []Action{},
+				Status:     StatusResolved,
+				ReportedBy: "migration",
+				ReportedAt: now,
+			}
+			if rec.ActionTaken != "" {
+				inc.Actions = append(inc.Actions, Action{Date: inc.Date, Action: rec.ActionTaken, TakenBy: "migration"})
+			}
+			if _, err := repo.Create(legacyID(studentID, i), inc.document()); err != nil && err != store.ErrConflict {
+				return moved, err
+			}
+		}
+
+		_, err := store.Modify(students, studentID, func(doc map[string]interface{}) error {
+			delete(doc, "behavioral_records")
+			return nil
+		})
+		if err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
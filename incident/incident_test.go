@@ -0,0 +1,61 @@
+package incident
+
+import (
+	"testing"
+
+	"data-access/student"
+)
+
+func TestMigrateBehavioralRecords(t *testing.T) {
+	students, repo := student.NewMemoryRepository(), NewMemoryRepository()
+	students.Create("s1", map[string]interface{}{
+		"full_name": "Ann",
+		"class":     "5",
+		"section":   "A",
+		"behavioral_records": []interface{}{
+			map[string]interface{}{"date": "2024-02-01", "incident": "Late to class", "action_taken": "Warning"},
+			map[string]interface{}{"date": "2024-03-01", "incident": "Talking in assembly"},
+		},
+	})
+	students.Create("s2", map[string]interface{}{"full_name": "Ben"})
+	students.Create("s3", map[string]interface{}{
+		"full_name":          "Cal",
+		"behavioral_records": []interface{}{map[string]interface{}{"date": "", "incident": "Undated remark"}},
+	})
+
+	n, err := Migrate(students, repo)
+	if err != nil || n != 1 {
+		t.Fatalf("Migrate = %d, %v; want 1 student", n, err)
+	}
+	if doc, _ := students.Get("s1"); doc["behavioral_records"] != nil {
+		t.Errorf("behavioral_records still on the student: %v", doc)
+	}
+	// Records that can't be read stay where they are
+	if doc, _ := students.Get("s3"); doc["behavioral_records"] == nil {
+		t.Errorf("unreadable behavioral_records dropped: %v", doc)
+	}
+
+	incidents, err := List(repo, func(inc Incident) bool { return inc.Involves("s1") })
+	if err != nil || len(incidents) != 2 {
+		t.Fatalf("List = %v, %v; want 2 incidents", incidents, err)
+	}
+	// Newest first
+	late := incidents[1]
+	if late.Date != "2024-02-01" || late.Description != "Late to class" || late.Status != StatusResolved {
+		t.Errorf("migrated incident = %+v", late)
+	}
+	if len(late.Actions) != 1 || late.Actions[0].Action != "Warning" || len(incidents[0].Actions) != 0 {
+		t.Errorf("actions = %v and %v", late.Actions, incidents[0].Actions)
+	}
+	if subjects := late.Subjects(); len(subjects) != 1 || subjects[0].Class != "5" || subjects[0].Name != "Ann" {
+		t.Errorf("subjects = %v", subjects)
+	}
+
+	// Running it again changes nothing
+	if n, err := Migrate(students, repo); err != nil || n != 0 {
+		t.Errorf("second Migrate = %d, %v", n, err)
+	}
+	if all, _ := repo.List(); len(all) != 2 {
+		t.Errorf("incident_db holds %d documents after a second run, want 2", len(all))
+	}
+}
@@ -0,0 +1,24 @@
+package incident
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding disciplinary incidents, their
+// escalations and the escalation rules
+const DBName = "incident_db"
+
+// Repository is the storage backend for incident documents
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the incident_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
@@ -0,0 +1,96 @@
+// Package notify sends email notifications. A Notifier delivers messages
+// through SMTP, or into an outbox directory when testing locally; a Queue
+// in front of either sends in the background and retries failures so
+// handlers don't wait on the mail server.
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages
+type Notifier interface {
+	Send(m Message) error
+}
+
+// Recipients finds the addresses to notify about a student, i.e. their
+// parents' accounts
+type Recipients func(studentID string) ([]string, error)
+
+// ErrNoRecipients is returned for a message addressed to no one
+var ErrNoRecipients = errors.New("message has no recipients")
+
+// bytes formats the message as RFC 5322 text
+func (m Message) bytes(from string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// SMTP sends messages through a mail server. Username and Password may be
+// empty for servers that don't need authentication.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers m through the server
+func (s SMTP) Send(m Message) error {
+	if len(m.To) == 0 {
+		return ErrNoRecipients
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	return smtp.SendMail(addr, auth, s.From, m.To, m.bytes(s.From))
+}
+
+// Outbox writes each message to its own .eml file in Dir instead of
+// sending it, for local testing
+type Outbox struct {
+	Dir string
+}
+
+// Send writes m to the outbox
+func (o Outbox) Send(m Message) error {
+	if len(m.To) == 0 {
+		return ErrNoRecipients
+	}
+	if err := os.MkdirAll(o.Dir, 0o755); err != nil {
+		return err
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(b) + ".eml"
+	return os.WriteFile(filepath.Join(o.Dir, name), m.bytes("outbox@localhost"), 0o644)
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when a message can't be queued without waiting
+var ErrQueueFull = errors.New("notification queue is full")
+
+// ErrQueueClosed is returned for messages sent after the queue was closed
+var ErrQueueClosed = errors.New("notification queue is closed")
+
+// QueueOptions tune a Queue. Zero values take the defaults.
+type QueueOptions struct {
+	Workers     int           // messages delivered at once, default 2
+	Size        int           // messages waiting before Send fails, default 100
+	MaxAttempts int           // deliveries tried per message, default 5
+	Backoff     time.Duration // wait before the first retry, doubling after each, default 2s
+}
+
+// Queue delivers messages through another Notifier in the background.
+// Send returns as soon as the message is queued; failed deliveries are
+// retried with exponential backoff and logged once they run out of
+// attempts.
+type Queue struct {
+	next    Notifier
+	opts    QueueOptions
+	jobs    chan Message
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// NewQueue starts a queue delivering through next
+func NewQueue(next Notifier, opts QueueOptions) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.Size <= 0 {
+		opts.Size = 100
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 2 * time.Second
+	}
+	q := &Queue{next: next, opts: opts, jobs: make(chan Message, opts.Size), done: make(chan struct{})}
+	for i := 0; i < opts.Workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Send queues m for delivery
+func (q *Queue) Send(m Message) error {
+	if len(m.To) == 0 {
+		return ErrNoRecipients
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.jobs <- m:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting messages and waits for the queued ones to be
+// delivered. Retries still waiting on their backoff are attempted once
+// more straight away.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.jobs)
+	close(q.done)
+	q.mu.Unlock()
+	q.workers.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for m := range q.jobs {
+		q.deliver(m)
+	}
+}
+
+// deliver tries m until it is sent or out of attempts
+func (q *Queue) deliver(m Message) {
+	wait := q.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		err := q.next.Send(m)
+		if err == nil {
+			return
+		}
+		if attempt >= q.opts.MaxAttempts {
+			log.Printf("Giving up on notification %q to %v after %d attempts: %v", m.Subject, m.To, attempt, err)
+			return
+		}
+		select {
+		case <-time.After(wait):
+		case <-q.done:
+			if err := q.next.Send(m); err != nil {
+				log.Printf("Dropping notification %q to %v on shutdown: %v", m.Subject, m.To, err)
+			}
+			return
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// templates holds a subject and a body for each kind of message, named
+// "<kind>.subject" and "<kind>.body"
+var templates = template.Must(template.New("notify").Funcs(template.FuncMap{
+	"money": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}).Parse(`
+{{define "otp.subject"}}Your one-time code{{end}}
+{{define "otp.body"}}Your one-time code is {{.Code}}. It expires in {{.Minutes}} minutes.
+
+If you didn't ask for it, you can ignore this email.
+{{end}}
+
+{{define "fee_reminder.subject"}}{{if .Overdue}}Overdue{{else}}Upcoming{{end}} fees for {{.StudentName}} ({{.Term}}){{end}}
+{{define "fee_reminder.body"}}Dear parent,
+
+{{if .Overdue -}}
+The fees for {{.StudentName}} for {{.Term}} were due on {{.DueDate}} and {{money .Balance}} is still outstanding.
+{{- else -}}
+The fees for {{.StudentName}} for {{.Term}} are due on {{.DueDate}}. The balance is {{money .Balance}}.
+{{- end}}
+
+Please quote invoice {{.InvoiceID}} when paying. If you have already paid, thank you, and please ignore this reminder.
+{{end}}
+
+{{define "absence_alert.subject"}}{{.StudentName}} was marked absent on {{.Date}}{{end}}
+{{define "absence_alert.body"}}Dear parent,
+
+{{.StudentName}} of class {{.Class}} {{.Section}} was marked absent on {{.Date}}.
+
+If you didn't expect this, please contact the school office.
+{{end}}
+`))
+
+// Render fills in the subject and body templates of a kind of message
+func Render(kind string, to []string, data interface{}) (Message, error) {
+	var subject, body bytes.Buffer
+	if err := templates.ExecuteTemplate(&subject, kind+".subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := templates.ExecuteTemplate(&body, kind+".body", data); err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: subject.String(), Body: body.String()}, nil
+}
+
+// OTPData fills the "otp" message
+type OTPData struct {
+	Code    string
+	Minutes int // how long the code is valid
+}
+
+// FeeReminderData fills the "fee_reminder" message
+type FeeReminderData struct {
+	StudentName string
+	Term        string
+	InvoiceID   string
+	DueDate     string
+	Balance     float64
+	Overdue     bool
+}
+
+// AbsenceAlertData fills the "absence_alert" message
+type AbsenceAlertData struct {
+	StudentName string
+	Class       string
+	Section     string
+	Date        string
+}
+
+// OTP is the message carrying a one-time login code
+func OTP(to, code string, minutes int) Message {
+	m, _ := Render("otp", []string{to}, OTPData{Code: code, Minutes: minutes})
+	return m
+}
+
+// FeeReminder is the message reminding parents of fees due
+func FeeReminder(to []string, data FeeReminderData) Message {
+	m, _ := Render("fee_reminder", to, data)
+	return m
+}
+
+// AbsenceAlert is the message telling parents their child was absent
+func AbsenceAlert(to []string, data AbsenceAlertData) Message {
+	m, _ := Render("absence_alert", to, data)
+	return m
+}
@@ -0,0 +1,266 @@
+// Package otp issues and checks the one-time codes emailed to users before
+// they register. Codes are kept in CouchDB so every server sees the same
+// ones and they survive restarts, and only a bcrypt hash of each code is
+// stored. Requests are rate limited per email address and too many wrong
+// guesses lock the address out for a while.
+package otp
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"data-access/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Errors returned by Issue and Verify
+var (
+	ErrInvalid     = errors.New("invalid or expired code")
+	ErrRateLimited = errors.New("too many codes requested")
+	ErrLocked      = errors.New("too many failed attempts")
+)
+
+const typeCode = "otp"
+
+// maxUpdateRetries bounds how often an update is retried after losing a
+// race with another server
+const maxUpdateRetries = 3
+
+// Options tune a Service. Zero values take the defaults.
+type Options struct {
+	Length        int           // digits in a code, default 6
+	TTL           time.Duration // how long a code is valid, default 10m
+	MaxRequests   int           // codes an address may request per RequestWindow, default 5
+	RequestWindow time.Duration // default 1h
+	MaxAttempts   int           // wrong guesses before the address is locked, default 5
+	Lockout       time.Duration // how long a locked address stays locked, default 15m
+}
+
+// Service issues and verifies codes
+type Service struct {
+	repo Repository
+	opts Options
+}
+
+// NewService returns a Service storing codes in repo
+func NewService(repo Repository, opts Options) *Service {
+	if opts.Length <= 0 {
+		opts.Length = 6
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 10 * time.Minute
+	}
+	if opts.MaxRequests <= 0 {
+		opts.MaxRequests = 5
+	}
+	if opts.RequestWindow <= 0 {
+		opts.RequestWindow = time.Hour
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Lockout <= 0 {
+		opts.Lockout = 15 * time.Minute
+	}
+	return &Service{repo: repo, opts: opts}
+}
+
+// TTL is how long issued codes are valid
+func (s *Service) TTL() time.Duration {
+	return s.opts.TTL
+}
+
+// entry is the document kept for each email address. Requests holds when
+// codes were issued inside the rate limit window.
+type entry struct {
+	Email       string      `json:"email"`
+	Hash        string      `json:"hash,omitempty"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+	Attempts    int         `json:"attempts"`
+	Requests    []time.Time `json:"requests"`
+	LockedUntil time.Time   `json:"locked_until"`
+}
+
+func docID(email string) string {
+	return "otp:" + normalize(email)
+}
+
+func normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Issue generates a new code for email, replacing any earlier one, and
+// returns it so it can be sent. It fails with ErrRateLimited if too many
+// codes were requested lately and ErrLocked while the address is locked.
+func (s *Service) Issue(email string, now time.Time) (string, error) {
+	code, err := generate(s.opts.Length)
+	if err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	err = s.update(email, true, func(e *entry) error {
+		if now.Before(e.LockedUntil) {
+			return ErrLocked
+		}
+		e.Requests = since(e.Requests, now.Add(-s.opts.RequestWindow))
+		if len(e.Requests) >= s.opts.MaxRequests {
+			return ErrRateLimited
+		}
+		e.Requests = append(e.Requests, now)
+		e.Hash = string(hash)
+		e.ExpiresAt = now.Add(s.opts.TTL)
+		e.Attempts = 0
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// Verify checks code against the one issued to email and uses it up if it
+// matches. Wrong codes count towards the lockout; the guess that reaches
+// MaxAttempts discards the code and fails with ErrLocked.
+func (s *Service) Verify(email, code string, now time.Time) error {
+	var result error
+	err := s.update(email, false, func(e *entry) error {
+		if now.Before(e.LockedUntil) {
+			return ErrLocked
+		}
+		if e.Hash == "" || !now.Before(e.ExpiresAt) {
+			return ErrInvalid
+		}
+		if bcrypt.CompareHashAndPassword([]byte(e.Hash), []byte(code)) == nil {
+			e.Hash, e.Attempts, result = "", 0, nil
+			return nil
+		}
+		e.Attempts++
+		result = ErrInvalid
+		if e.Attempts >= s.opts.MaxAttempts {
+			e.Hash, e.Attempts, e.LockedUntil = "", 0, now.Add(s.opts.Lockout)
+			result = ErrLocked
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// Cleanup deletes the entries of addresses with no live code, lockout or
+// recent request and reports how many it removed
+func (s *Service) Cleanup(now time.Time) (int, error) {
+	docs, err := s.repo.List()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, doc := range docs {
+		var e entry
+		if doc["type"] != typeCode || store.Decode(doc, &e) != nil {
+			continue
+		}
+		if (e.Hash != "" && now.Before(e.ExpiresAt)) || now.Before(e.LockedUntil) ||
+			len(since(e.Requests, now.Add(-s.opts.RequestWindow))) > 0 {
+			continue
+		}
+		id, _ := doc["_id"].(string)
+		// A conflict means the address was just used again
+		if err := s.repo.Delete(id, store.Rev(doc)); err == nil {
+			removed++
+		} else if err != store.ErrConflict && err != store.ErrNotFound {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// update applies change to the entry for email and writes it back,
+// retrying from a fresh read if another writer got there first. Without
+// create a missing entry fails with ErrInvalid. Errors returned by change
+// abort the update unchanged.
+func (s *Service) update(email string, create bool, change func(e *entry) error) error {
+	id := docID(email)
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		var e entry
+		rev := ""
+		doc, err := s.repo.Get(id)
+		switch {
+		case err == store.ErrNotFound:
+			if !create {
+				return ErrInvalid
+			}
+			e.Email = normalize(email)
+		case err != nil:
+			return err
+		default:
+			if err := store.Decode(doc, &e); err != nil {
+				return err
+			}
+			rev = store.Rev(doc)
+		}
+		if err := change(&e); err != nil {
+			return err
+		}
+		next, err := e.doc()
+		if err != nil {
+			return err
+		}
+		if rev == "" {
+			_, err = s.repo.Create(id, next)
+		} else {
+			_, err = s.repo.Update(id, next, rev)
+		}
+		if err != store.ErrConflict {
+			return err
+		}
+	}
+	return store.ErrConflict
+}
+
+func (e entry) doc() (map[string]interface{}, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	doc["type"] = typeCode
+	return doc, nil
+}
+
+// since keeps the times at or after cutoff
+func since(times []time.Time, cutoff time.Time) []time.Time {
+	kept := []time.Time{}
+	for _, t := range times {
+		if !t.Before(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// generate returns a random code of n digits
+func generate(n int) (string, error) {
+	const digits = "0123456789"
+	code := make([]byte, n)
+	for i := range code {
+		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(digits))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = digits[num.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,114 @@
+package otp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+
+func TestIssueAndVerify(t *testing.T) {
+	repo := NewMemoryRepository()
+	s := NewService(repo, Options{})
+
+	code, err := s.Issue(" Ann@Example.com", start)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if len(code) != 6 || strings.Trim(code, "0123456789") != "" {
+		t.Errorf("code = %q, want 6 digits", code)
+	}
+	doc, err := repo.Get(docID("ann@example.com"))
+	if err != nil {
+		t.Fatalf("no entry stored for the normalized address: %v", err)
+	}
+	if hash, _ := doc["hash"].(string); hash == "" || strings.Contains(hash, code) {
+		t.Errorf("stored hash = %q, want a bcrypt hash of the code", hash)
+	}
+
+	if err := s.Verify("ann@example.com", code, start.Add(time.Minute)); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+	if err := s.Verify("ann@example.com", code, start.Add(time.Minute)); err != ErrInvalid {
+		t.Errorf("reusing a code = %v, want ErrInvalid", err)
+	}
+	if err := s.Verify("nobody@example.com", code, start); err != ErrInvalid {
+		t.Errorf("Verify for an address without a code = %v, want ErrInvalid", err)
+	}
+}
+
+func TestCodeExpires(t *testing.T) {
+	s := NewService(NewMemoryRepository(), Options{TTL: 5 * time.Minute})
+	code, _ := s.Issue("ann@example.com", start)
+	if err := s.Verify("ann@example.com", code, start.Add(5*time.Minute)); err != ErrInvalid {
+		t.Errorf("Verify at expiry = %v, want ErrInvalid", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := NewService(NewMemoryRepository(), Options{MaxRequests: 2, RequestWindow: time.Hour})
+	for i := 0; i < 2; i++ {
+		if _, err := s.Issue("ann@example.com", start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("Issue %d: %v", i+1, err)
+		}
+	}
+	if _, err := s.Issue("ann@example.com", start.Add(30*time.Minute)); err != ErrRateLimited {
+		t.Errorf("third Issue within the window = %v, want ErrRateLimited", err)
+	}
+	if _, err := s.Issue("ann@example.com", start.Add(61*time.Minute)); err != nil {
+		t.Errorf("Issue once the first request left the window = %v", err)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	s := NewService(NewMemoryRepository(), Options{MaxAttempts: 3, Lockout: 15 * time.Minute})
+	code, _ := s.Issue("ann@example.com", start)
+
+	wrong := "000000"
+	if wrong == code {
+		wrong = "111111"
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Verify("ann@example.com", wrong, start); err != ErrInvalid {
+			t.Fatalf("wrong guess %d = %v, want ErrInvalid", i+1, err)
+		}
+	}
+	if err := s.Verify("ann@example.com", wrong, start); err != ErrLocked {
+		t.Fatalf("last allowed wrong guess = %v, want ErrLocked", err)
+	}
+	// The right code no longer works, and no new one can be requested
+	if err := s.Verify("ann@example.com", code, start.Add(time.Minute)); err != ErrLocked {
+		t.Errorf("Verify while locked = %v, want ErrLocked", err)
+	}
+	if _, err := s.Issue("ann@example.com", start.Add(time.Minute)); err != ErrLocked {
+		t.Errorf("Issue while locked = %v, want ErrLocked", err)
+	}
+
+	code, err := s.Issue("ann@example.com", start.Add(16*time.Minute))
+	if err != nil {
+		t.Fatalf("Issue after the lockout = %v", err)
+	}
+	if err := s.Verify("ann@example.com", code, start.Add(17*time.Minute)); err != nil {
+		t.Errorf("Verify after the lockout = %v", err)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	repo := NewMemoryRepository()
+	s := NewService(repo, Options{TTL: 10 * time.Minute, RequestWindow: time.Hour})
+	s.Issue("old@example.com", start)
+	s.Issue("new@example.com", start.Add(50*time.Minute))
+
+	// The old address's code and request have both aged out
+	n, err := s.Cleanup(start.Add(90 * time.Minute))
+	if err != nil || n != 1 {
+		t.Fatalf("Cleanup = %d, %v; want 1", n, err)
+	}
+	if _, err := repo.Get(docID("old@example.com")); err == nil {
+		t.Errorf("stale entry kept")
+	}
+	if _, err := repo.Get(docID("new@example.com")); err != nil {
+		t.Errorf("entry inside the rate limit window removed: %v", err)
+	}
+}
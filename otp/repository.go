@@ -0,0 +1,23 @@
+package otp
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding one-time login codes
+const DBName = "otp_db"
+
+// Repository is the storage backend for one-time codes
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the otp_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
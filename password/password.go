@@ -0,0 +1,243 @@
+// Package password lets users change a password they know or reset one
+// they forgot with a one-time code, and holds the strength rules every
+// new password must meet. Changing or resetting a password ends the
+// user's existing sessions.
+package password
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"data-access/account"
+	"data-access/auth"
+	"data-access/notify"
+	"data-access/otp"
+	"data-access/session"
+	"data-access/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Length limits. bcrypt ignores anything past 72 bytes.
+const (
+	MinLength = 10
+	MaxLength = 72
+)
+
+// Validate checks a new password against the strength rules: MinLength
+// to MaxLength bytes, upper and lower case letters and a digit, and not
+// the user's email address
+func Validate(password, email string) error {
+	if len(password) < MinLength {
+		return fmt.Errorf("password must be at least %d characters", MinLength)
+	}
+	if len(password) > MaxLength {
+		return fmt.Errorf("password must be at most %d bytes", MaxLength)
+	}
+	var upper, lower, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !upper || !lower || !digit {
+		return errors.New("password must mix upper and lower case letters and digits")
+	}
+	if email != "" && strings.EqualFold(password, email) {
+		return errors.New("password must not be the email address")
+	}
+	return nil
+}
+
+// Hash returns the bcrypt hash stored for a password
+func Hash(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(h), err
+}
+
+// setPassword stores a new password hash on the user's account
+func setPassword(users store.Repository, id, password string, now time.Time) error {
+	hash, err := Hash(password)
+	if err != nil {
+		return err
+	}
+	_, err = store.Modify(users, id, func(doc map[string]interface{}) error {
+		doc["password"] = hash
+		doc["password_changed_at"] = now.UTC().Format(time.RFC3339)
+		return nil
+	})
+	return err
+}
+
+// sendResetCode emails a reset code to the account with the given email,
+// if there is one
+func sendResetCode(users account.Repository, otps *otp.Service, notifier notify.Notifier, email string) error {
+	doc, err := account.FindByEmail(users, email)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	email, _ = doc["email"].(string)
+	code, err := otps.Issue(email, time.Now())
+	if err != nil {
+		return err
+	}
+	return notifier.Send(notify.OTP(email, code, int(otps.TTL().Minutes())))
+}
+
+// Forgot emails a one-time code for resetting the password of the account
+// with the given email ({"email"}). The response is the same whether or
+// not the account exists, and whether or not the code could be sent, so
+// it can't be used to probe for addresses; failures are only logged.
+func Forgot(w http.ResponseWriter, r *http.Request, users account.Repository, otps *otp.Service, notifier notify.Notifier) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := sendResetCode(users, otps, notifier, request.Email); err != nil {
+		log.Printf("Failed to send a password reset code: %v", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If the account exists, a code to reset its password has been sent",
+	})
+}
+
+// Reset sets a new password using the one-time code sent by Forgot
+// ({"email", "otp", "new_password"}) and logs the account out everywhere.
+// The sessions are ended first so none outlives the old password.
+func Reset(w http.ResponseWriter, r *http.Request, users account.Repository, otps *otp.Service, sessions *session.Manager) {
+	var request struct {
+		Email       string `json:"email"`
+		OTP         string `json:"otp"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" || request.OTP == "" {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	// Checked before the code so a weak password doesn't use it up
+	if err := Validate(request.NewPassword, request.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	switch otps.Verify(request.Email, request.OTP, now) {
+	case nil:
+	case otp.ErrInvalid:
+		http.Error(w, "invalid or expired otp", http.StatusUnauthorized)
+		return
+	case otp.ErrLocked:
+		http.Error(w, "too many failed attempts, try again later", http.StatusTooManyRequests)
+		return
+	default:
+		http.Error(w, "failed to check otp", http.StatusInternalServerError)
+		return
+	}
+
+	doc, err := account.FindByEmail(users, request.Email)
+	if err == store.ErrNotFound {
+		http.Error(w, "invalid or expired otp", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to look up account", http.StatusInternalServerError)
+		return
+	}
+	id, _ := doc["_id"].(string)
+	if _, err := sessions.RevokeAll(id, now); err != nil {
+		http.Error(w, "Failed to end existing sessions, password not reset", http.StatusInternalServerError)
+		return
+	}
+	if err := setPassword(users, id, request.NewPassword, now); err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset, please log in again"})
+}
+
+// Change replaces the caller's password ({"current_password",
+// "new_password"}). Every session of the account is ended before the
+// password is stored and the caller gets fresh tokens for a new one.
+func Change(w http.ResponseWriter, r *http.Request, users account.Repository, sessions *session.Manager) {
+	var request struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+
+	doc, err := users.Get(caller.ID)
+	if err == store.ErrNotFound {
+		http.Error(w, "account not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to look up account", http.StatusInternalServerError)
+		return
+	}
+	current, _ := doc["password"].(string)
+	if bcrypt.CompareHashAndPassword([]byte(current), []byte(request.CurrentPassword)) != nil {
+		http.Error(w, "incorrect password", http.StatusUnauthorized)
+		return
+	}
+	if err := Validate(request.NewPassword, caller.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.NewPassword == request.CurrentPassword {
+		http.Error(w, "new password must differ from the current one", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	if _, err := sessions.RevokeAll(caller.ID, now); err != nil {
+		http.Error(w, "Failed to end existing sessions, password not changed", http.StatusInternalServerError)
+		return
+	}
+	if err := setPassword(users, caller.ID, request.NewPassword, now); err != nil {
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+	caller.Session = ""
+	tokens, err := sessions.Issue(caller, now)
+	if err != nil {
+		// The change stands; only the new session is missing
+		log.Printf("Failed to start a session after a password change: %v", err)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Password changed, please log in again"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Password changed",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
@@ -0,0 +1,97 @@
+package password
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"data-access/account"
+	"data-access/notify"
+	"data-access/otp"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// outbox records sent messages, or fails every send when broken
+type outbox struct {
+	sent   []notify.Message
+	broken bool
+}
+
+func (o *outbox) Send(m notify.Message) error {
+	if o.broken {
+		return errors.New("mail server down")
+	}
+	o.sent = append(o.sent, m)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		password string
+		email    string
+		ok       bool
+	}{
+		{"Correct1horse", "ann@example.com", true},
+		{"Short1a", "", false},
+		{"Abcdefgh1", "", false},
+		{"Abcdefgh12", "", true},
+		{"Ä" + strings.Repeat("b", 69) + "1", "", true}, // 72 bytes
+		{"Ä" + strings.Repeat("b", 70) + "1", "", false},
+		{"alllowercase1", "", false},
+		{"ALLUPPERCASE1", "", false},
+		{"NoDigitsHere", "", false},
+		{"Ann1@Example.com", "ann1@example.com", false},
+		{"Ann1@Example.com", "", true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.password, tt.email); (err == nil) != tt.ok {
+			t.Errorf("Validate(%q, %q) = %v, want ok %v", tt.password, tt.email, err, tt.ok)
+		}
+	}
+}
+
+func TestForgotAnswersAlike(t *testing.T) {
+	users := account.NewMemoryRepository()
+	users.Create("t1", map[string]interface{}{"email": "ann@example.com", "password": "hash"})
+	otps := otp.NewService(otp.NewMemoryRepository(), otp.Options{MaxRequests: 2})
+
+	forgot := func(email string, notifier notify.Notifier) (int, string) {
+		w := httptest.NewRecorder()
+		body := `{"email":"` + email + `"}`
+		Forgot(w, httptest.NewRequest("POST", "/password/forgot", strings.NewReader(body)), users, otps, notifier)
+		return w.Code, w.Body.String()
+	}
+
+	mail := &outbox{}
+	wantCode, wantBody := forgot("nobody@example.com", mail)
+	if wantCode != http.StatusOK || len(mail.sent) != 0 {
+		t.Fatalf("unknown address = %d, %d messages", wantCode, len(mail.sent))
+	}
+	cases := []struct {
+		name     string
+		notifier *outbox
+		sent     int
+	}{
+		{"existing account", mail, 1},
+		{"mail failure", &outbox{broken: true}, 0},
+		{"rate limited", mail, 1},
+	}
+	for _, c := range cases {
+		code, body := forgot("Ann@example.com", c.notifier)
+		if code != wantCode || body != wantBody {
+			t.Errorf("%s = %d %q, want %d %q", c.name, code, body, wantCode, wantBody)
+		}
+		if len(c.notifier.sent) != c.sent {
+			t.Errorf("%s: %d messages sent, want %d", c.name, len(c.notifier.sent), c.sent)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package payroll
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Transfer is one salary credit in a bank file. DirectDeposit holds the
+// bank branch code and the account number separated by ":", "/" or a
+// space, e.g. "HDFC0001234:50100012345678"; a value without a separator
+// is taken as a bare account number.
+type Transfer struct {
+	EmployeeID string
+	Kind       string
+	Name       string
+	BankCode   string
+	Account    string
+	Amount     float64
+	Reference  string
+}
+
+// Limits of the bank file's bank code and account fields
+const (
+	maxBankCode = 11
+	maxAccount  = 20
+)
+
+// Transfers turns a run's payslips into bank credits. Payslips with
+// nothing to pay, without an account, or whose bank code or account would
+// not fit the bank file unchanged are returned separately, as are those
+// without a bank code when requireBank is set.
+func Transfers(run Run, requireBank bool) (transfers []Transfer, skipped []Payslip) {
+	for _, p := range run.Payslips {
+		bank, account := splitAccount(p.DirectDeposit)
+		if p.Net <= 0 || !validCode(account, maxAccount) || (bank != "" || requireBank) && !validCode(bank, maxBankCode) {
+			skipped = append(skipped, p)
+			continue
+		}
+		transfers = append(transfers, Transfer{
+			EmployeeID: p.EmployeeID,
+			Kind:       p.Kind,
+			Name:       p.Name,
+			BankCode:   strings.ToUpper(bank),
+			Account:    strings.ToUpper(account),
+			Amount:     p.Net,
+			Reference:  "SALARY " + run.Month,
+		})
+	}
+	return transfers, skipped
+}
+
+// validCode reports whether s is 1 to width letters and digits, so it
+// reaches the bank exactly as entered
+func validCode(s string, width int) bool {
+	if s == "" || len(s) > width {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func splitAccount(deposit string) (bank, account string) {
+	deposit = strings.TrimSpace(deposit)
+	if i := strings.IndexAny(deposit, ":/ "); i >= 0 {
+		return strings.TrimSpace(deposit[:i]), strings.TrimSpace(deposit[i+1:])
+	}
+	return "", deposit
+}
+
+// WriteCSV writes transfers as a spreadsheet-friendly CSV with a header row
+func WriteCSV(transfers []Transfer) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"employee_id", "kind", "name", "bank_code", "account_number", "amount", "reference"})
+	for _, t := range transfers {
+		w.Write([]string{t.EmployeeID, t.Kind, t.Name, t.BankCode, t.Account, money(t.Amount), t.Reference})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Fixed-width bank file layout. Every record is recordLength characters
+// and ends in CRLF. Amounts are in the smallest currency unit (paise or
+// cents), zero-padded on the left; text is upper case, space-padded on the
+// right.
+//
+//	Header  H | originator 30 | settlement date YYYYMMDD | records 6 | total 15
+//	Detail  D | bank code 11 | account 20 | name 30 | amount 13 | reference 18 | employee ID 15
+//	Trailer T | records 6 | total 15
+const recordLength = 120
+
+// WriteFixedWidth writes transfers in a NACH/ACH-style fixed-width file
+// for a bulk credit from the originator's account on the settlement date
+func WriteFixedWidth(transfers []Transfer, originator string, settlement time.Time) []byte {
+	var total int64
+	for _, t := range transfers {
+		total += minorUnits(t.Amount)
+	}
+
+	var buf bytes.Buffer
+	record := func(fields ...string) {
+		line := strings.Join(fields, "")
+		if len(line) < recordLength {
+			line += strings.Repeat(" ", recordLength-len(line))
+		}
+		buf.WriteString(line + "\r\n")
+	}
+	record("H", alpha(originator, 30), settlement.Format("20060102"), numeric(int64(len(transfers)), 6), numeric(total, 15))
+	for _, t := range transfers {
+		record("D", alpha(t.BankCode, 11), alpha(t.Account, 20), alpha(t.Name, 30),
+			numeric(minorUnits(t.Amount), 13), alpha(t.Reference, 18), alpha(t.EmployeeID, 15))
+	}
+	record("T", numeric(int64(len(transfers)), 6), numeric(total, 15))
+	return buf.Bytes()
+}
+
+func minorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// alpha upper-cases s, drops characters banks won't accept and pads or
+// cuts it to width
+func alpha(s string, width int) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || strings.ContainsRune(" .-/", r) {
+			b.WriteRune(r)
+		}
+	}
+	out := b.String()
+	if len(out) > width {
+		return out[:width]
+	}
+	return out + strings.Repeat(" ", width-len(out))
+}
+
+// numeric zero-pads n to width digits
+func numeric(n int64, width int) string {
+	return fmt.Sprintf("%0*d", width, n)
+}
@@ -0,0 +1,70 @@
+package payroll
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransfersSkipInvalidAccounts(t *testing.T) {
+	run := Run{Month: "2024-03", Payslips: []Payslip{
+		{EmployeeID: "ok", Net: 100, DirectDeposit: "hdfc0001234:50100012345678"},
+		{EmployeeID: "bare", Net: 100, DirectDeposit: "50100012345678"},
+		{EmployeeID: "long", Net: 100, DirectDeposit: "HDFC0001234:501000123456789012345"},
+		{EmployeeID: "dashed", Net: 100, DirectDeposit: "HDFC0001234:5010-0012-3456"},
+		{EmployeeID: "badbank", Net: 100, DirectDeposit: "HDFC-0001234:50100012345678"},
+		{EmployeeID: "none", Net: 100},
+		{EmployeeID: "unpaid", Net: 0, DirectDeposit: "HDFC0001234:1"},
+	}}
+
+	ids := func(transfers []Transfer, skipped []Payslip) (string, string) {
+		var got, left []string
+		for _, tr := range transfers {
+			got = append(got, tr.EmployeeID)
+		}
+		for _, p := range skipped {
+			left = append(left, p.EmployeeID)
+		}
+		return strings.Join(got, ","), strings.Join(left, ",")
+	}
+
+	transfers, skipped := Transfers(run, false)
+	if got, left := ids(transfers, skipped); got != "ok,bare" || left != "long,dashed,badbank,none,unpaid" {
+		t.Errorf("Transfers = [%s], skipped [%s]", got, left)
+	}
+	if transfers[0].BankCode != "HDFC0001234" || transfers[0].Account != "50100012345678" {
+		t.Errorf("transfer = %+v", transfers[0])
+	}
+
+	// The fixed-width file needs a bank code for every credit
+	transfers, skipped = Transfers(run, true)
+	if got, left := ids(transfers, skipped); got != "ok" || !strings.HasPrefix(left, "bare,") {
+		t.Errorf("Transfers requiring a bank = [%s], skipped [%s]", got, left)
+	}
+}
+
+func TestWriteFixedWidth(t *testing.T) {
+	transfers := []Transfer{
+		{EmployeeID: "t1", Name: "Tara Rao", BankCode: "HDFC0001234", Account: "50100012345678", Amount: 1234.5, Reference: "SALARY 2024-03"},
+		{EmployeeID: "s1", Name: "Sam", BankCode: "SBIN0000001", Account: "1", Amount: 0.25, Reference: "SALARY 2024-03"},
+	}
+	data := WriteFixedWidth(transfers, "Springfield School", time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC))
+	lines := strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d records, want header, two details and trailer", len(lines))
+	}
+	for i, line := range lines {
+		if len(line) != recordLength {
+			t.Errorf("record %d is %d characters, want %d", i, len(line), recordLength)
+		}
+	}
+	if want := "H" + alpha("SPRINGFIELD SCHOOL", 30) + "20240330" + "000002" + "000000000123475"; !strings.HasPrefix(lines[0], want) {
+		t.Errorf("header = %q", lines[0])
+	}
+	if want := "DHDFC000123450100012345678      TARA RAO                      0000000123450"; !strings.HasPrefix(lines[1], want) {
+		t.Errorf("detail = %q", lines[1])
+	}
+	if want := "T000002000000000123475"; !strings.HasPrefix(lines[3], want) {
+		t.Errorf("trailer = %q", lines[3])
+	}
+}
@@ -0,0 +1,153 @@
+package payroll
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"data-access/auth"
+)
+
+func payslipFilename(p Payslip) string {
+	return "payslip-" + p.Kind + "-" + p.EmployeeID + "-" + p.Month + ".pdf"
+}
+
+// GetPayslipPDF renders an employee's payslip for a month as PDF
+// (?id=..&month=YYYY-MM, with kind=staff or teacher if the ID is shared)
+func GetPayslipPDF(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	employeeID, month := query.Get("id"), query.Get("month")
+	if employeeID == "" || month == "" {
+		http.Error(w, "id and month are required", http.StatusBadRequest)
+		return
+	}
+
+	run, err := LoadRun(repo, month)
+	if err != nil {
+		http.Error(w, "Payroll run not found", http.StatusNotFound)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	slip, ok := run.Payslip(employeeID, kindOf(caller, query.Get("kind")))
+	if !ok {
+		http.Error(w, "Payslip not found", http.StatusNotFound)
+		return
+	}
+	data, err := RenderPayslip(slip, run.Status).Bytes()
+	if err != nil {
+		http.Error(w, "failed to build PDF", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", payslipFilename(slip)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// GetPayslipBatch zips every payslip of a month's run (?month=YYYY-MM)
+func GetPayslipBatch(w http.ResponseWriter, r *http.Request, repo Repository) {
+	month := r.URL.Query().Get("month")
+	run, err := LoadRun(repo, month)
+	if err != nil {
+		http.Error(w, "Payroll run not found", http.StatusNotFound)
+		return
+	}
+	if len(run.Payslips) == 0 {
+		http.Error(w, "payroll run has no payslips", http.StatusNotFound)
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, slip := range run.Payslips {
+		f, err := zw.Create(payslipFilename(slip))
+		if err == nil {
+			_, err = RenderPayslip(slip, run.Status).WriteTo(f)
+		}
+		if err != nil {
+			http.Error(w, "failed to build zip archive", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		http.Error(w, "failed to build zip archive", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "payslips-"+month+".zip"))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// GetBankFile exports the salary credits of a finalized run for upload to
+// the bank (?month=YYYY-MM&format=csv|nach). The fixed-width format also
+// takes the originator name and the settlement date (?originator=..
+// &date=YYYY-MM-DD, default today). Employees without a valid bank code and
+// account are left out and listed in the X-Skipped-Employees header.
+func GetBankFile(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	month := query.Get("month")
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "nach" {
+		http.Error(w, "format must be csv or nach", http.StatusBadRequest)
+		return
+	}
+	settlement := time.Now()
+	if s := query.Get("date"); s != "" {
+		var err error
+		if settlement, err = time.Parse(dateLayout, s); err != nil {
+			http.Error(w, "date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	originator := query.Get("originator")
+	if originator == "" {
+		originator = "SCHOOL PAYROLL"
+	}
+
+	run, err := LoadRun(repo, month)
+	if err != nil {
+		http.Error(w, "Payroll run not found", http.StatusNotFound)
+		return
+	}
+	if run.Status != StatusFinalized {
+		http.Error(w, "payroll run must be finalized before paying it out", http.StatusConflict)
+		return
+	}
+
+	// The fixed-width format routes every credit by bank code
+	transfers, skipped := Transfers(run, format == "nach")
+	var data []byte
+	var contentType, name string
+	switch format {
+	case "csv":
+		if data, err = WriteCSV(transfers); err != nil {
+			http.Error(w, "failed to build bank file", http.StatusInternalServerError)
+			return
+		}
+		contentType, name = "text/csv", "salary-"+month+".csv"
+	case "nach":
+		data = WriteFixedWidth(transfers, originator, settlement)
+		contentType, name = "text/plain", "salary-"+month+".txt"
+	}
+
+	if len(skipped) > 0 {
+		ids := make([]string, len(skipped))
+		for i, p := range skipped {
+			ids[i] = p.Kind + ":" + p.EmployeeID
+		}
+		w.Header().Set("X-Skipped-Employees", strings.Join(ids, ","))
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,280 @@
+package payroll
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/auth"
+	"data-access/staff"
+	"data-access/store"
+	"data-access/teacher"
+)
+
+// Run statuses. A draft run can be recalculated as often as needed; a
+// finalized one is locked.
+const (
+	StatusDraft     = "draft"
+	StatusFinalized = "finalized"
+)
+
+const typeRun = "payroll_run"
+
+var errFinalized = errors.New("payroll run is finalized")
+
+// Totals sums a run's payslips
+type Totals struct {
+	Employees        int     `json:"employees"`
+	Gross            float64 `json:"gross"`
+	UnpaidDeductions float64 `json:"unpaid_deductions"`
+	Withheld         float64 `json:"withheld"`
+	Net              float64 `json:"net"`
+}
+
+// Run is the payroll of one month
+type Run struct {
+	ID          string    `json:"id"`
+	Month       string    `json:"month"`
+	Status      string    `json:"status"`
+	GeneratedAt string    `json:"generated_at"`
+	GeneratedBy string    `json:"generated_by"`
+	FinalizedAt string    `json:"finalized_at,omitempty"`
+	FinalizedBy string    `json:"finalized_by,omitempty"`
+	Totals      Totals    `json:"totals"`
+	Payslips    []Payslip `json:"payslips,omitempty"`
+}
+
+func runID(month string) string {
+	return "run:" + month
+}
+
+// Payslip returns the payslip of one employee in the run. kind may be
+// empty when the ID is unambiguous.
+func (run Run) Payslip(employeeID, kind string) (Payslip, bool) {
+	for _, p := range run.Payslips {
+		if p.EmployeeID == employeeID && (kind == "" || p.Kind == kind) {
+			return p, true
+		}
+	}
+	return Payslip{}, false
+}
+
+// kindOf returns the kind of payslip a request is about. Teachers and
+// staff can only reach their own kind, since IDs are not unique across the
+// two; admins name it with kind, or leave it empty when the ID is
+// unambiguous.
+func kindOf(caller auth.Identity, kind string) string {
+	switch caller.Role {
+	case auth.Faculty:
+		return KindTeacher
+	case auth.Staff:
+		return KindStaff
+	}
+	return kind
+}
+
+func totals(slips []Payslip) Totals {
+	t := Totals{Employees: len(slips)}
+	for _, p := range slips {
+		t.Gross += p.Gross
+		t.UnpaidDeductions += p.UnpaidDeduction
+		t.Withheld += p.TotalWithheld
+		t.Net += p.Net
+	}
+	t.Gross, t.UnpaidDeductions = round2(t.Gross), round2(t.UnpaidDeductions)
+	t.Withheld, t.Net = round2(t.Withheld), round2(t.Net)
+	return t
+}
+
+// LoadRun reads the payroll run of a month ("YYYY-MM")
+func LoadRun(repo Repository, month string) (Run, error) {
+	doc, err := repo.Get(runID(month))
+	if err != nil {
+		return Run{}, err
+	}
+	var run Run
+	if doc["type"] != typeRun || store.Decode(doc, &run) != nil {
+		return Run{}, store.ErrNotFound
+	}
+	run.ID, _ = doc["_id"].(string)
+	return run, nil
+}
+
+// RunPayroll calculates the payslips of every staff member and teacher for
+// a month ({"month": "YYYY-MM"}). Running it again replaces the draft with
+// fresh figures; a finalized month can't be run again.
+func RunPayroll(w http.ResponseWriter, r *http.Request, repo Repository, staffs staff.Repository, teachers teacher.Repository) {
+	var request struct {
+		Month string `json:"month"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	m, err := parseMonth(request.Month)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slips, err := payslips(staffs, teachers, m)
+	if err != nil {
+		http.Error(w, "Failed to fetch employees", http.StatusInternalServerError)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	run := Run{
+		ID:          runID(m.name),
+		Month:       m.name,
+		Status:      StatusDraft,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		GeneratedBy: caller.Email,
+		Totals:      totals(slips),
+		Payslips:    slips,
+	}
+	doc := map[string]interface{}{
+		"type":         typeRun,
+		"month":        run.Month,
+		"status":       run.Status,
+		"generated_at": run.GeneratedAt,
+		"generated_by": run.GeneratedBy,
+		"totals":       run.Totals,
+		"payslips":     run.Payslips,
+	}
+
+	_, err = store.Modify(repo, run.ID, func(existing map[string]interface{}) error {
+		if existing["status"] == StatusFinalized {
+			return errFinalized
+		}
+		for key, value := range doc {
+			existing[key] = value
+		}
+		return nil
+	})
+	if err == store.ErrNotFound {
+		_, err = repo.Create(run.ID, doc)
+	}
+	switch {
+	case err == errFinalized:
+		http.Error(w, "payroll for "+m.name+" is finalized", http.StatusConflict)
+		return
+	case err == store.ErrConflict:
+		http.Error(w, "payroll run was modified concurrently, retry", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "failed to save payroll run", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Payroll calculated",
+		"run":     run,
+	})
+}
+
+// FinalizePayroll locks a month's payroll run ({"month": "YYYY-MM"})
+func FinalizePayroll(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var request struct {
+		Month string `json:"month"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+
+	doc, err := store.Modify(repo, runID(request.Month), func(doc map[string]interface{}) error {
+		if doc["status"] == StatusFinalized {
+			return errFinalized
+		}
+		doc["status"] = StatusFinalized
+		doc["finalized_at"] = time.Now().UTC().Format(time.RFC3339)
+		doc["finalized_by"] = caller.Email
+		return nil
+	})
+	switch {
+	case err == store.ErrNotFound:
+		http.Error(w, "Payroll run not found", http.StatusNotFound)
+		return
+	case err == errFinalized:
+		http.Error(w, "payroll for "+request.Month+" is already finalized", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "failed to finalize payroll run", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Payroll finalized",
+		"month":        request.Month,
+		"finalized_at": doc["finalized_at"],
+	})
+}
+
+// GetPayrollRuns returns the run of one month with its payslips
+// (?month=YYYY-MM), or a summary of every run without one
+func GetPayrollRuns(w http.ResponseWriter, r *http.Request, repo Repository) {
+	if month := r.URL.Query().Get("month"); month != "" {
+		run, err := LoadRun(repo, month)
+		if err != nil {
+			http.Error(w, "Payroll run not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(run)
+		return
+	}
+
+	docs, err := repo.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch payroll runs", http.StatusInternalServerError)
+		return
+	}
+	runs := []Run{}
+	for _, doc := range docs {
+		var run Run
+		if doc["type"] != typeRun || store.Decode(doc, &run) != nil {
+			continue
+		}
+		run.ID, _ = doc["_id"].(string)
+		run.Payslips = nil
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Month > runs[j].Month })
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(runs)
+}
+
+// GetPayslip returns an employee's payslip for a month
+// (?id=..&month=YYYY-MM, with kind=staff or teacher if the ID is shared)
+func GetPayslip(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	employeeID, month := query.Get("id"), query.Get("month")
+	if employeeID == "" || month == "" {
+		http.Error(w, "id and month are required", http.StatusBadRequest)
+		return
+	}
+
+	run, err := LoadRun(repo, month)
+	if err != nil {
+		http.Error(w, "Payroll run not found", http.StatusNotFound)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	slip, ok := run.Payslip(employeeID, kindOf(caller, query.Get("kind")))
+	if !ok {
+		http.Error(w, "Payslip not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  run.Status,
+		"payslip": slip,
+	})
+}
@@ -0,0 +1,236 @@
+package payroll
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"data-access/staff"
+	"data-access/store"
+	"data-access/teacher"
+)
+
+// Employee kinds
+const (
+	KindStaff   = "staff"
+	KindTeacher = "teacher"
+)
+
+// HoursPerDay converts staff time off, recorded in hours, into days
+const HoursPerDay = 8
+
+const (
+	monthLayout = "2006-01"
+	dateLayout  = "2006-01-02"
+)
+
+// inactive are the staff employment statuses that are no longer paid
+var inactive = map[string]bool{"terminated": true, "resigned": true, "retired": true, "inactive": true}
+
+// Payslip is one employee's pay for a month. Salaries are annual; a month
+// pays a twelfth of it, pro rata for employees who joined during the
+// month. Unpaid leave is deducted at the daily rate for the month's
+// working days (Monday to Saturday). Withholdings are percentages of the
+// pay left after that deduction.
+type Payslip struct {
+	EmployeeID      string             `json:"employee_id"`
+	Kind            string             `json:"kind"`
+	Name            string             `json:"name"`
+	Department      string             `json:"department"`
+	JobTitle        string             `json:"job_title,omitempty"`
+	Month           string             `json:"month"`
+	AnnualSalary    float64            `json:"annual_salary"`
+	WorkingDays     int                `json:"working_days"`
+	EmployedDays    int                `json:"employed_days"`
+	UnpaidDays      float64            `json:"unpaid_days"`
+	Gross           float64            `json:"gross"`
+	UnpaidDeduction float64            `json:"unpaid_deduction"`
+	Withholdings    map[string]float64 `json:"withholdings"`
+	TotalWithheld   float64            `json:"total_withheld"`
+	Net             float64            `json:"net"`
+	DirectDeposit   string             `json:"direct_deposit"`
+}
+
+// employee is what the payroll needs to know about a staff member or
+// teacher
+type employee struct {
+	id, kind, name, department, jobTitle string
+	salary                               float64
+	startDate                            string
+	directDeposit                        string
+	withholdings                         map[string]float64
+	unpaidDays                           float64 // in the month being paid
+}
+
+// month is the calendar month being paid
+type month struct {
+	name        string
+	first, last time.Time
+}
+
+func parseMonth(s string) (month, error) {
+	first, err := time.Parse(monthLayout, s)
+	if err != nil {
+		return month{}, errors.New("month must be formatted as YYYY-MM")
+	}
+	return month{name: s, first: first, last: first.AddDate(0, 1, -1)}, nil
+}
+
+// workingDays counts the days from one date to another, both included,
+// that are not Sundays
+func workingDays(from, to time.Time) int {
+	days := 0
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Sunday {
+			days++
+		}
+	}
+	return days
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// loadStaff reads the staff members to be paid for a month
+func loadStaff(repo staff.Repository, m month) ([]employee, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	var employees []employee
+	for _, doc := range docs {
+		var s struct {
+			FullName         string            `json:"full_name"`
+			Department       string            `json:"department"`
+			JobTitle         string            `json:"job_title"`
+			Salary           float64           `json:"salary"`
+			StartDate        string            `json:"start_date"`
+			EmploymentStatus string            `json:"employment_status"`
+			TimeOff          []staff.TimeOff   `json:"time_off"`
+			PayrollInfo      staff.PayrollInfo `json:"payroll_info"`
+		}
+		if store.Decode(doc, &s) != nil || inactive[strings.ToLower(s.EmploymentStatus)] {
+			continue
+		}
+		e := employee{
+			kind: KindStaff, name: s.FullName, department: s.Department, jobTitle: s.JobTitle,
+			salary: s.Salary, startDate: s.StartDate,
+			directDeposit: s.PayrollInfo.DirectDeposit, withholdings: s.PayrollInfo.TaxWithholdings,
+		}
+		e.id, _ = doc["_id"].(string)
+		for _, off := range s.TimeOff {
+			if strings.EqualFold(off.Type, "unpaid") && !off.Date.Before(m.first) && !off.Date.After(m.last) {
+				e.unpaidDays += float64(off.Hours) / HoursPerDay
+			}
+		}
+		employees = append(employees, e)
+	}
+	return employees, nil
+}
+
+// loadTeachers reads the teachers to be paid for a month
+func loadTeachers(repo teacher.Repository, m month) ([]employee, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	var employees []employee
+	for _, doc := range docs {
+		var t struct {
+			FullName    string              `json:"full_name"`
+			Department  string              `json:"department"`
+			Salary      float64             `json:"salary"`
+			JoiningDate string              `json:"joining_date"`
+			PayrollInfo teacher.PayrollInfo `json:"payroll_info"`
+		}
+		if store.Decode(doc, &t) != nil {
+			continue
+		}
+		leave, err := teacher.LeaveRecords(doc)
+		if err != nil {
+			continue
+		}
+		e := employee{
+			kind: KindTeacher, name: t.FullName, department: t.Department, jobTitle: "Teacher",
+			salary: t.Salary, startDate: t.JoiningDate,
+			directDeposit: t.PayrollInfo.DirectDeposit, withholdings: t.PayrollInfo.TaxWithholdings,
+		}
+		e.id, _ = doc["_id"].(string)
+		for _, l := range leave {
+			if l.Type != "unpaid" || !strings.EqualFold(l.Status, teacher.LeaveApproved) {
+				continue
+			}
+			from, to := l.StartDate.Time, l.EndDate.Time
+			if from.Before(m.first) {
+				from = m.first
+			}
+			if to.After(m.last) {
+				to = m.last
+			}
+			e.unpaidDays += float64(workingDays(from, to))
+		}
+		employees = append(employees, e)
+	}
+	return employees, nil
+}
+
+// payslip works out an employee's pay for a month. It reports false for
+// employees who had not joined by the end of the month.
+func (e employee) payslip(m month) (Payslip, bool) {
+	if e.startDate > m.last.Format(dateLayout) {
+		return Payslip{}, false
+	}
+	p := Payslip{
+		EmployeeID: e.id, Kind: e.kind, Name: e.name, Department: e.department, JobTitle: e.jobTitle,
+		Month: m.name, AnnualSalary: e.salary, DirectDeposit: e.directDeposit,
+		WorkingDays:  workingDays(m.first, m.last),
+		Withholdings: map[string]float64{},
+	}
+	p.EmployedDays = p.WorkingDays
+	if start, err := time.Parse(dateLayout, e.startDate); err == nil && start.After(m.first) {
+		p.EmployedDays = workingDays(start, m.last)
+	}
+
+	daily := e.salary / 12 / float64(p.WorkingDays)
+	p.Gross = round2(daily * float64(p.EmployedDays))
+	p.UnpaidDays = math.Min(e.unpaidDays, float64(p.EmployedDays))
+	p.UnpaidDeduction = round2(daily * p.UnpaidDays)
+
+	taxable := p.Gross - p.UnpaidDeduction
+	for name, percent := range e.withholdings {
+		amount := round2(taxable * percent / 100)
+		p.Withholdings[name] = amount
+		p.TotalWithheld += amount
+	}
+	p.TotalWithheld = round2(p.TotalWithheld)
+	p.Net = round2(taxable - p.TotalWithheld)
+	return p, true
+}
+
+// payslips works out the month's pay of every staff member and teacher
+func payslips(staffs staff.Repository, teachers teacher.Repository, m month) ([]Payslip, error) {
+	staffMembers, err := loadStaff(staffs, m)
+	if err != nil {
+		return nil, err
+	}
+	teacherList, err := loadTeachers(teachers, m)
+	if err != nil {
+		return nil, err
+	}
+	slips := []Payslip{}
+	for _, e := range append(staffMembers, teacherList...) {
+		if p, ok := e.payslip(m); ok {
+			slips = append(slips, p)
+		}
+	}
+	sort.Slice(slips, func(i, j int) bool {
+		if slips[i].Kind != slips[j].Kind {
+			return slips[i].Kind < slips[j].Kind
+		}
+		return slips[i].EmployeeID < slips[j].EmployeeID
+	})
+	return slips, nil
+}
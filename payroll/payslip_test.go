@@ -0,0 +1,93 @@
+package payroll
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"data-access/auth"
+	"data-access/staff"
+	"data-access/teacher"
+)
+
+func TestPayslipProRataWithUnpaidLeave(t *testing.T) {
+	// March 2024 has 26 working days; 312000 a year is 1000 a day
+	m, _ := parseMonth("2024-03")
+	e := employee{
+		id: "t1", kind: KindTeacher, salary: 312000, startDate: "2024-03-11",
+		withholdings: map[string]float64{"income_tax": 10},
+		unpaidDays:   2,
+	}
+	p, ok := e.payslip(m)
+	if !ok {
+		t.Fatal("payslip skipped an employee who joined during the month")
+	}
+	if p.WorkingDays != 26 || p.EmployedDays != 18 {
+		t.Errorf("working/employed days = %d/%d, want 26/18", p.WorkingDays, p.EmployedDays)
+	}
+	if p.Gross != 18000 || p.UnpaidDeduction != 2000 || p.TotalWithheld != 1600 || p.Net != 14400 {
+		t.Errorf("gross %v, unpaid %v, withheld %v, net %v; want 18000, 2000, 1600, 14400",
+			p.Gross, p.UnpaidDeduction, p.TotalWithheld, p.Net)
+	}
+
+	e.startDate = "2024-04-01"
+	if _, ok := e.payslip(m); ok {
+		t.Errorf("payslip issued to an employee who joined after the month")
+	}
+}
+
+func TestLoadTeachersCountsApprovedUnpaidLeave(t *testing.T) {
+	teachers := teacher.NewMemoryRepository()
+	teachers.Create("t1", map[string]interface{}{
+		"salary": 312000,
+		"leave_records": []interface{}{
+			// Two working days fall in March
+			map[string]interface{}{"type": "unpaid", "status": "approved", "start_date": "2024-03-29", "end_date": "2024-04-02"},
+			map[string]interface{}{"type": "unpaid", "status": "pending", "start_date": "2024-03-05", "end_date": "2024-03-05"},
+			map[string]interface{}{"type": "sick", "status": "approved", "start_date": "2024-03-06", "end_date": "2024-03-06"},
+		},
+	})
+	m, _ := parseMonth("2024-03")
+	employees, err := loadTeachers(teachers, m)
+	if err != nil || len(employees) != 1 {
+		t.Fatalf("loadTeachers = %v, %v", employees, err)
+	}
+	if employees[0].unpaidDays != 2 {
+		t.Errorf("unpaid days = %v, want 2", employees[0].unpaidDays)
+	}
+}
+
+func TestGetPayslipKeepsSelfCallersToTheirKind(t *testing.T) {
+	repo, staffs, teachers := NewMemoryRepository(), staff.NewMemoryRepository(), teacher.NewMemoryRepository()
+	staffs.Create("e1", map[string]interface{}{"full_name": "Sam", "salary": 120000, "start_date": "2020-01-01"})
+	teachers.Create("e1", map[string]interface{}{"full_name": "Tara", "salary": 240000, "joining_date": "2020-01-01"})
+
+	w := httptest.NewRecorder()
+	RunPayroll(w, httptest.NewRequest("POST", "/payroll/runs", strings.NewReader(`{"month":"2024-03"}`)), repo, staffs, teachers)
+	if w.Code != http.StatusOK {
+		t.Fatalf("RunPayroll = %d %s", w.Code, w.Body)
+	}
+
+	get := func(role auth.Role, kind string) string {
+		r := httptest.NewRequest("GET", "/payroll/payslips?id=e1&month=2024-03&kind="+kind, nil)
+		r = r.WithContext(auth.NewContext(r.Context(), auth.Identity{ID: "e1", Role: role}))
+		w := httptest.NewRecorder()
+		GetPayslip(w, r, repo)
+		var result struct {
+			Payslip Payslip `json:"payslip"`
+		}
+		json.NewDecoder(w.Body).Decode(&result)
+		return result.Payslip.Name
+	}
+	if name := get(auth.Faculty, KindStaff); name != "Tara" {
+		t.Errorf("teacher asking for kind=staff got %q's payslip, want their own", name)
+	}
+	if name := get(auth.Staff, KindTeacher); name != "Sam" {
+		t.Errorf("staff member asking for kind=teacher got %q's payslip, want their own", name)
+	}
+	if name := get(auth.Admin, KindStaff); name != "Sam" {
+		t.Errorf("admin asking for kind=staff got %q", name)
+	}
+}
@@ -0,0 +1,106 @@
+package payroll
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"data-access/pdf"
+)
+
+// Page layout in points
+const (
+	margin     = 50.0
+	lineHeight = 16.0
+	amountX    = pdf.A4Width - margin - 100
+)
+
+func money(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+// monthTitle turns "2026-10" into "October 2026"
+func monthTitle(month string) string {
+	t, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return month
+	}
+	return t.Format("January 2006")
+}
+
+// RenderPayslip lays out a payslip on one A4 page. Draft payslips are
+// marked as such.
+func RenderPayslip(p Payslip, status string) *pdf.Document {
+	doc := pdf.New()
+	page := doc.AddPage(pdf.A4Width, pdf.A4Height)
+	y := margin + 10
+
+	page.Text(margin, y, 20, true, "PAYSLIP")
+	page.Text(pdf.A4Width-margin-140, y, 12, true, monthTitle(p.Month))
+	if status != StatusFinalized {
+		y += lineHeight
+		page.Text(pdf.A4Width-margin-140, y, 10, false, "DRAFT - not final")
+	}
+	y += 10
+	page.Line(margin, y, pdf.A4Width-margin, y, 1.5)
+
+	row := func(label, value string) {
+		y += lineHeight
+		page.Text(margin, y, 10, false, label)
+		page.Text(180, y, 10, false, value)
+	}
+	y += lineHeight / 2
+	row("Name", p.Name)
+	row("Employee ID", p.EmployeeID)
+	row("Department", orDash(p.Department))
+	row("Designation", orDash(p.JobTitle))
+	row("Paid into", orDash(p.DirectDeposit))
+	row("Working days", fmt.Sprintf("%d (employed %d, unpaid leave %g)", p.WorkingDays, p.EmployedDays, p.UnpaidDays))
+
+	section := func(title string) {
+		y += lineHeight * 2
+		page.Rect(margin, y-11, pdf.A4Width-2*margin, lineHeight, 0.9)
+		page.Text(margin+2, y, 10, true, title)
+		page.Text(amountX, y, 10, true, "Amount")
+	}
+	amount := func(label string, v float64, bold bool) {
+		y += lineHeight
+		page.Text(margin+2, y, 10, bold, label)
+		page.Text(amountX, y, 10, bold, money(v))
+	}
+
+	section("Earnings")
+	amount(fmt.Sprintf("Salary (annual %s)", money(p.AnnualSalary)), p.Gross, false)
+	amount("Gross pay", p.Gross, true)
+
+	section("Deductions")
+	if p.UnpaidDeduction > 0 {
+		amount("Unpaid leave", p.UnpaidDeduction, false)
+	}
+	names := make([]string, 0, len(p.Withholdings))
+	for name := range p.Withholdings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		amount(name, p.Withholdings[name], false)
+	}
+	amount("Total deductions", p.UnpaidDeduction+p.TotalWithheld, true)
+
+	y += lineHeight
+	page.Line(margin, y, pdf.A4Width-margin, y, 0.5)
+	y += lineHeight
+	page.Text(margin+2, y, 12, true, "Net pay")
+	page.Text(amountX, y, 12, true, money(p.Net))
+
+	y += lineHeight * 4
+	page.Text(margin, y, 9, false, "This is a computer generated payslip.")
+	return doc
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,23 @@
+package payroll
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding monthly payroll runs
+const DBName = "payroll_db"
+
+// Repository is the storage backend for payroll documents
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the payroll_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
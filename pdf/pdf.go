@@ -0,0 +1,186 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+)
+
+// Page sizes in points (1/72 inch)
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Document is a minimal PDF writer supporting the standard Helvetica fonts,
+// lines, rectangles and RGB images. It is enough for cards, report cards
+// and payslips without pulling in a PDF library.
+type Document struct {
+	pages []*Page
+}
+
+// Page is a single page. Coordinates are in points from the top-left corner.
+type Page struct {
+	width, height float64
+	content       bytes.Buffer
+	images        []image.Image
+}
+
+// New returns an empty document
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage appends a page of the given size
+func (d *Document) AddPage(width, height float64) *Page {
+	p := &Page{width: width, height: height}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Text draws a single line of text with its baseline at y
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, p.height-y, escape(s))
+}
+
+// Line draws a line of the given width
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, p.height-y1, x2, p.height-y2)
+}
+
+// Rect outlines a rectangle, or fills it with the grey level (0 black,
+// 1 white) when fill is between 0 and 1
+func (p *Page) Rect(x, y, w, h, fill float64) {
+	if fill >= 0 && fill <= 1 {
+		fmt.Fprintf(&p.content, "q %.3f g %.2f %.2f %.2f %.2f re f Q\n", fill, x, p.height-y-h, w, h)
+		return
+	}
+	fmt.Fprintf(&p.content, "0.5 w %.2f %.2f %.2f %.2f re S\n", x, p.height-y-h, w, h)
+}
+
+// Image draws img scaled into the given box
+func (p *Page) Image(img image.Image, x, y, w, h float64) {
+	p.images = append(p.images, img)
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", w, h, x, p.height-y-h, len(p.images))
+}
+
+// WriteTo serialises the document
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	// Object numbers: 1 catalog, 2 page tree, 3-4 fonts, then per page
+	// the page, its content stream and its images
+	obj := func(body string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	var kids []string
+	next := 5
+	for _, p := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", next))
+		next += 2 + len(p.images)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)), nil)
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+
+	for _, p := range d.pages {
+		pageNum := len(offsets) + 1
+		var xobjects []string
+		for i := range p.images {
+			xobjects = append(xobjects, fmt.Sprintf("/Im%d %d 0 R", i+1, pageNum+2+i))
+		}
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Contents %d 0 R "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> /XObject << %s >> >> >>",
+			p.width, p.height, pageNum+1, strings.Join(xobjects, " ")), nil)
+
+		content := p.content.Bytes()
+		obj(fmt.Sprintf("<< /Length %d >>", len(content)), content)
+
+		for _, img := range p.images {
+			data, err := deflateRGB(img)
+			if err != nil {
+				return 0, err
+			}
+			b := img.Bounds()
+			obj(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB "+
+				"/BitsPerComponent 8 /Filter /FlateDecode /Length %d >>", b.Dx(), b.Dy(), len(data)), data)
+		}
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// Bytes returns the serialised document
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deflateRGB flattens an image to zlib-compressed 8-bit RGB samples
+func deflateRGB(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	b := img.Bounds()
+	row := make([]byte, 0, b.Dx()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = row[:0]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			row = append(row, byte(r>>8), byte(g>>8), byte(bl>>8))
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escape quotes a string for a PDF literal, replacing characters the
+// standard fonts can't show
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
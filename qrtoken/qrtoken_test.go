@@ -0,0 +1,65 @@
+package qrtoken
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+
+// reclaim swaps a token's claims for others while keeping its signature
+func reclaim(token, claims string) string {
+	_, sig, _ := strings.Cut(token, ".")
+	return base64.RawURLEncoding.EncodeToString([]byte(claims)) + "." + sig
+}
+
+func TestVerify(t *testing.T) {
+	s := NewSigner([]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+	token, claims, err := s.Sign("s1", "student", start)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if claims.ID != "s1" || claims.Kind != "student" || claims.ExpiresAt != start.Add(time.Hour).Unix() {
+		t.Fatalf("claims = %+v", claims)
+	}
+	encoded, sig, _ := strings.Cut(token, ".")
+	other := NewSigner([]byte("another key of thirty-two bytes!"), time.Hour)
+	forged, _, _ := other.Sign("s1", "student", start)
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		err   error
+	}{
+		{"valid", token, start.Add(time.Minute), nil},
+		{"surrounding whitespace", " " + token + "\n", start, nil},
+		{"expired", token, start.Add(time.Hour), ErrExpired},
+		{"other key", forged, start, ErrInvalid},
+		{"kind changed", reclaim(token, `{"sub":"s1","kind":"teacher","iat":0,"exp":9999999999}`), start, ErrInvalid},
+		{"holder changed", reclaim(token, `{"sub":"s2","kind":"student","iat":0,"exp":9999999999}`), start, ErrInvalid},
+		{"signature changed", encoded + "." + strings.ToUpper(sig), start, ErrInvalid},
+		{"no signature", encoded, start, ErrInvalid},
+		{"empty", "", start, ErrInvalid},
+	}
+	for _, tt := range tests {
+		got, err := s.Verify(tt.token, tt.now)
+		if err != tt.err {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && (got.ID != "s1" || got.Kind != "student") {
+			t.Errorf("%s: claims = %+v", tt.name, got)
+		}
+	}
+}
+
+func TestVerifyNeedsHolder(t *testing.T) {
+	s := NewSigner([]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+	token, _, _ := s.Sign("", "student", start)
+	if _, err := s.Verify(token, start); err != ErrInvalid {
+		t.Errorf("Verify of a token without an ID = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,211 @@
+package reportcard
+
+import (
+	"fmt"
+	"strings"
+
+	"data-access/pdf"
+)
+
+// Page layout in points
+const (
+	margin     = 50.0
+	lineHeight = 16.0
+	bottom     = pdf.A4Height - margin
+)
+
+// Score table columns
+var columns = []struct {
+	title string
+	x     float64
+}{
+	{"Subject / Exam", margin},
+	{"Date", 250},
+	{"Score", 330},
+	{"Weight", 400},
+	{"%", 455},
+	{"Grade", 505},
+}
+
+// layout writes lines top to bottom, starting a new page when one fills up
+type layout struct {
+	doc  *pdf.Document
+	page *pdf.Page
+	y    float64
+}
+
+func (l *layout) newPage() {
+	l.page = l.doc.AddPage(pdf.A4Width, pdf.A4Height)
+	l.y = margin
+}
+
+// next moves down by h, breaking the page if the line wouldn't fit
+func (l *layout) next(h float64) {
+	if l.y+h > bottom {
+		l.newPage()
+	}
+	l.y += h
+}
+
+func (l *layout) text(x, size float64, bold bool, s string) {
+	l.page.Text(x, l.y, size, bold, s)
+}
+
+func (l *layout) heading(s string) {
+	l.next(lineHeight * 2)
+	l.text(margin, 13, true, s)
+	l.page.Line(margin, l.y+4, pdf.A4Width-margin, l.y+4, 0.5)
+	l.next(4)
+}
+
+// paragraph writes s wrapped to the page width, indented by x
+func (l *layout) paragraph(x float64, s string) {
+	// Helvetica averages about half the font size per character
+	width := int((pdf.A4Width - margin - x) / 5)
+	for _, line := range wrap(s, width) {
+		l.next(lineHeight)
+		l.text(x, 10, false, line)
+	}
+}
+
+func wrap(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Render lays out a report card on A4 pages
+func Render(s Sheet) *pdf.Document {
+	l := &layout{doc: pdf.New()}
+	l.newPage()
+
+	l.next(10)
+	l.text(margin, 20, true, "REPORT CARD")
+	l.text(pdf.A4Width-margin-120, 12, true, "Term: "+s.Term)
+	l.next(10)
+	l.page.Line(margin, l.y, pdf.A4Width-margin, l.y, 1.5)
+
+	class := "Class " + s.Class
+	if s.Section != "" {
+		class += " - " + s.Section
+	}
+	l.next(lineHeight * 1.5)
+	l.text(margin, 11, true, s.FullName)
+	l.text(330, 11, false, "Student ID: "+s.StudentID)
+	l.next(lineHeight)
+	l.text(margin, 11, false, class)
+	if s.RollNumber != "" {
+		l.text(330, 11, false, "Roll No: "+s.RollNumber)
+	}
+
+	l.heading("Exam results")
+	if len(s.Subjects) == 0 {
+		l.next(lineHeight)
+		l.text(margin, 10, false, "No exam results recorded for this term.")
+	} else {
+		l.next(lineHeight)
+		l.page.Rect(margin, l.y-11, pdf.A4Width-2*margin, lineHeight, 0.9)
+		for _, c := range columns {
+			l.text(c.x+2, 10, true, c.title)
+		}
+		for _, subject := range s.Subjects {
+			l.next(lineHeight)
+			l.text(columns[0].x+2, 10, true, subject.Subject)
+			l.text(columns[4].x+2, 10, true, fmt.Sprintf("%.2f", subject.Percentage))
+			l.text(columns[5].x+2, 10, true, subject.Grade)
+			for _, e := range subject.Exams {
+				name := e.Name
+				if name == "" {
+					name = e.ExamID
+				}
+				l.next(lineHeight)
+				l.text(columns[0].x+14, 10, false, name)
+				l.text(columns[1].x+2, 10, false, e.Date)
+				l.text(columns[2].x+2, 10, false, fmt.Sprintf("%g / %g", e.Score, e.MaxMarks))
+				l.text(columns[3].x+2, 10, false, fmt.Sprintf("%g", e.Weightage))
+				l.text(columns[4].x+2, 10, false, fmt.Sprintf("%.2f", e.Percentage))
+				l.text(columns[5].x+2, 10, false, e.Grade)
+			}
+		}
+		l.next(6)
+		l.page.Line(margin, l.y, pdf.A4Width-margin, l.y, 0.5)
+		l.next(lineHeight)
+		l.text(columns[0].x+2, 11, true, "Overall")
+		l.text(columns[4].x+2, 11, true, fmt.Sprintf("%.2f", s.Percentage))
+		l.text(columns[5].x+2, 11, true, s.Grade)
+	}
+
+	l.heading("Attendance")
+	period := "All recorded days"
+	if s.From != "" || s.To != "" {
+		period = fmt.Sprintf("%s to %s", orDash(s.From), orDash(s.To))
+	}
+	a := s.Attendance
+	l.next(lineHeight)
+	l.text(margin, 10, false, period)
+	l.next(lineHeight)
+	l.text(margin, 10, false, fmt.Sprintf("Present: %d   Late: %d   Absent: %d   School days: %d", a.Present, a.Late, a.Absent, a.Total))
+	l.next(lineHeight)
+	l.text(margin, 10, true, fmt.Sprintf("Attendance: %.2f%%", a.Percentage))
+
+	l.heading("Behavioral remarks")
+	if len(s.Behavior) == 0 {
+		l.next(lineHeight)
+		l.text(margin, 10, false, "None.")
+	}
+	for _, inc := range s.Behavior {
+		remark := inc.Date + ": " + inc.Description
+		var actions []string
+		for _, a := range inc.Actions {
+			actions = append(actions, a.Action)
+		}
+		if len(actions) > 0 {
+			remark += " (action: " + strings.Join(actions, "; ") + ")"
+		}
+		l.paragraph(margin, remark)
+	}
+
+	l.heading("Teacher comments")
+	if len(s.Comments) == 0 {
+		l.next(lineHeight)
+		l.text(margin, 10, false, "None.")
+	}
+	for _, c := range s.Comments {
+		byline := c.Date
+		if c.Author != "" {
+			byline = c.Author + ", " + byline
+		}
+		l.next(lineHeight)
+		l.text(margin, 10, true, byline)
+		l.paragraph(margin+10, c.Comment)
+	}
+
+	l.next(lineHeight * 4)
+	l.page.Line(margin, l.y, margin+150, l.y, 0.5)
+	l.page.Line(pdf.A4Width-margin-150, l.y, pdf.A4Width-margin, l.y, 0.5)
+	l.next(lineHeight)
+	l.text(margin, 9, false, "Class teacher")
+	l.text(pdf.A4Width-margin-150, 9, false, "Principal")
+
+	return l.doc
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
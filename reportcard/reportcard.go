@@ -0,0 +1,301 @@
+package reportcard
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/auth"
+	"data-access/exam"
+	"data-access/incident"
+	"data-access/store"
+	"data-access/student"
+)
+
+const dateLayout = "2006-01-02"
+
+// Comment is a teacher's remark on a student's report card for a term
+type Comment struct {
+	Term    string `json:"term"`
+	Author  string `json:"author"`
+	Comment string `json:"comment"`
+	Date    string `json:"date"`
+}
+
+// Sheet is everything printed on a report card
+type Sheet struct {
+	exam.ReportCard
+	RollNumber string
+	From, To   string
+	Attendance student.AttendanceSummary
+	Behavior   []incident.Incident
+	Comments   []Comment
+}
+
+// isSubject reports whether the student's own conduct is what the incident
+// is about
+func isSubject(inc incident.Incident, studentID string) bool {
+	for _, p := range inc.Subjects() {
+		if p.PersonID == studentID {
+			return true
+		}
+	}
+	return false
+}
+
+// conduct lists the incidents of term with any of studentIDs as subject
+// in the optional from..to date range
+func conduct(incidents incident.Repository, studentIDs map[string]bool, term, from, to string) ([]incident.Incident, error) {
+	return incident.List(incidents, func(inc incident.Incident) bool {
+		if inc.Term != term || (from != "" && inc.Date < from) || (to != "" && inc.Date > to) {
+			return false
+		}
+		for id := range studentIDs {
+			if isSubject(inc, id) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// build gathers a student's report card for term, whose days run from..to.
+// behavior holds incidents from conduct and the student's are picked out
+// of it.
+func build(exams exam.Repository, doc map[string]interface{}, behavior []incident.Incident, term, from, to string) (Sheet, error) {
+	card, err := exam.BuildReportCard(exams, doc, term)
+	if err != nil {
+		return Sheet{}, err
+	}
+	var info struct {
+		RollNumber        string                     `json:"roll_number"`
+		AttendanceRecords []student.AttendanceRecord `json:"attendance_records"`
+		ReportComments    []Comment                  `json:"report_comments"`
+	}
+	if err := store.Decode(doc, &info); err != nil {
+		return Sheet{}, err
+	}
+
+	inRange := func(t time.Time) bool {
+		day := t.Format(dateLayout)
+		return (from == "" || day >= from) && (to == "" || day <= to)
+	}
+	sheet := Sheet{ReportCard: card, RollNumber: info.RollNumber, From: from, To: to}
+	var attendance []student.AttendanceRecord
+	for _, rec := range info.AttendanceRecords {
+		if inRange(rec.Date.Time) {
+			attendance = append(attendance, rec)
+		}
+	}
+	sheet.Attendance = student.Summarize(attendance)
+	for _, inc := range behavior {
+		if isSubject(inc, card.StudentID) {
+			sheet.Behavior = append(sheet.Behavior, inc)
+		}
+	}
+	sort.SliceStable(sheet.Behavior, func(i, j int) bool { return sheet.Behavior[i].Date < sheet.Behavior[j].Date })
+	for _, c := range info.ReportComments {
+		if c.Term == term {
+			sheet.Comments = append(sheet.Comments, c)
+		}
+	}
+	return sheet, nil
+}
+
+// parseRange reads the term and its first and last day, from and to,
+// shared by the report card endpoints. Terms have no dates of their own,
+// so without them attendance would cover every term.
+func parseRange(r *http.Request) (term, from, to string, err error) {
+	query := r.URL.Query()
+	term, from, to = query.Get("term"), query.Get("from"), query.Get("to")
+	if term == "" {
+		return "", "", "", fmt.Errorf("term missing")
+	}
+	if from == "" || to == "" {
+		return "", "", "", fmt.Errorf("from and to missing, give the term's first and last day")
+	}
+	for _, s := range []string{from, to} {
+		if _, err := time.Parse(dateLayout, s); err != nil {
+			return "", "", "", fmt.Errorf("from and to must be formatted as YYYY-MM-DD")
+		}
+	}
+	if from > to {
+		return "", "", "", fmt.Errorf("from must not be after to")
+	}
+	return term, from, to, nil
+}
+
+func filename(studentID, term string) string {
+	return "reportcard-" + studentID + "-" + term + ".pdf"
+}
+
+// GetReportCard renders one student's report card for a term as PDF
+// (?id=..&term=..&from=..&to=..)
+func GetReportCard(w http.ResponseWriter, r *http.Request, exams exam.Repository, students student.Repository, incidents incident.Repository) {
+	term, from, to, err := parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	studentID := r.URL.Query().Get("id")
+	if studentID == "" {
+		http.Error(w, "Student ID missing", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := students.Get(studentID)
+	if err != nil {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+	behavior, err := conduct(incidents, map[string]bool{studentID: true}, term, from, to)
+	if err != nil {
+		http.Error(w, "Failed to fetch incidents", http.StatusInternalServerError)
+		return
+	}
+	sheet, err := build(exams, doc, behavior, term, from, to)
+	if err != nil {
+		http.Error(w, "failed to build report card", http.StatusInternalServerError)
+		return
+	}
+	data, err := Render(sheet).Bytes()
+	if err != nil {
+		http.Error(w, "failed to build PDF", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename(studentID, term)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// GetBatch zips the report cards of a whole class, or one section of it
+// (?class=..&section=..&term=..&from=..&to=..)
+func GetBatch(w http.ResponseWriter, r *http.Request, exams exam.Repository, students student.Repository, incidents incident.Repository) {
+	term, from, to, err := parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	class, section := r.URL.Query().Get("class"), r.URL.Query().Get("section")
+	if class == "" {
+		http.Error(w, "class missing", http.StatusBadRequest)
+		return
+	}
+
+	docs, err := students.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch students", http.StatusInternalServerError)
+		return
+	}
+
+	var members []map[string]interface{}
+	ids := make(map[string]bool)
+	for _, doc := range docs {
+		docClass, _ := doc["class"].(string)
+		docSection, _ := doc["section"].(string)
+		if docClass == class && (section == "" || docSection == section) {
+			members = append(members, doc)
+			id, _ := doc["_id"].(string)
+			ids[id] = true
+		}
+	}
+	if len(members) == 0 {
+		http.Error(w, "no students in that class", http.StatusNotFound)
+		return
+	}
+	behavior, err := conduct(incidents, ids, term, from, to)
+	if err != nil {
+		http.Error(w, "Failed to fetch incidents", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, doc := range members {
+		sheet, err := build(exams, doc, behavior, term, from, to)
+		if err != nil {
+			http.Error(w, "failed to build report card", http.StatusInternalServerError)
+			return
+		}
+		f, err := zw.Create(filename(sheet.StudentID, term))
+		if err == nil {
+			_, err = Render(sheet).WriteTo(f)
+		}
+		if err != nil {
+			http.Error(w, "failed to build zip archive", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		http.Error(w, "failed to build zip archive", http.StatusInternalServerError)
+		return
+	}
+
+	name := "reportcards-" + class
+	if section != "" {
+		name += "-" + section
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"-"+term+".zip"))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// AddComment records the calling teacher's comment on a student's report
+// card for a term, replacing their earlier comment for that term
+func AddComment(w http.ResponseWriter, r *http.Request, students student.Repository) {
+	var request struct {
+		StudentID string `json:"student_id"`
+		Term      string `json:"term"`
+		Comment   string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if request.StudentID == "" || request.Term == "" || request.Comment == "" {
+		http.Error(w, "student_id, term and comment are required", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+
+	comment := Comment{
+		Term:    request.Term,
+		Author:  caller.Email,
+		Comment: request.Comment,
+		Date:    time.Now().Format(dateLayout),
+	}
+	_, err := store.Modify(students, request.StudentID, func(doc map[string]interface{}) error {
+		var info struct {
+			ReportComments []Comment `json:"report_comments"`
+		}
+		if err := store.Decode(doc, &info); err != nil {
+			return err
+		}
+		comments := info.ReportComments[:0]
+		for _, c := range info.ReportComments {
+			if c.Term != comment.Term || c.Author != comment.Author {
+				comments = append(comments, c)
+			}
+		}
+		doc["report_comments"] = append(comments, comment)
+		return nil
+	})
+	if err == store.ErrNotFound {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Comment saved successfully"})
+}
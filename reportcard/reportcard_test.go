@@ -0,0 +1,54 @@
+package reportcard
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"data-access/incident"
+)
+
+func TestConductKeepsToTerm(t *testing.T) {
+	repo := incident.NewMemoryRepository()
+	add := func(id, term, date, subject string) {
+		repo.Create(id, map[string]interface{}{
+			"type": "incident",
+			"term": term,
+			"date": date,
+			"involved": []interface{}{
+				map[string]interface{}{"person_id": subject, "kind": "student", "role": incident.RoleSubject},
+			},
+		})
+	}
+	add("i1", "term1", "2024-02-01", "s1")
+	add("i2", "term2", "2024-07-01", "s1")
+	add("i3", "term1", "2024-02-02", "s2")
+	add("i4", "term1", "2024-05-01", "s1")
+
+	got, err := conduct(repo, map[string]bool{"s1": true}, "term1", "2024-01-01", "2024-03-31")
+	if err != nil {
+		t.Fatalf("conduct: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "i1" {
+		t.Errorf("conduct = %+v, want only i1", got)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{"term=term1&from=2024-01-01&to=2024-03-31", true},
+		{"term=term1", false},
+		{"term=term1&from=2024-01-01", false},
+		{"from=2024-01-01&to=2024-03-31", false},
+		{"term=term1&from=2024-01-01&to=31-03-2024", false},
+		{"term=term1&from=2024-04-01&to=2024-03-31", false},
+	}
+	for _, tt := range tests {
+		_, _, _, err := parseRange(httptest.NewRequest("GET", "/students/report-card?"+tt.query, nil))
+		if (err == nil) != tt.ok {
+			t.Errorf("parseRange(%s) = %v, want ok %v", tt.query, err, tt.ok)
+		}
+	}
+}
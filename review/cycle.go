@@ -0,0 +1,596 @@
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"data-access/auth"
+	"data-access/domain"
+	"data-access/staff"
+	"data-access/store"
+	"data-access/teacher"
+)
+
+// Employee kinds
+const (
+	KindTeacher = "teacher"
+	KindStaff   = "staff"
+)
+
+// Cycle statuses. Reviews can only be submitted while a cycle is open.
+const (
+	CycleOpen   = "open"
+	CycleClosed = "closed"
+)
+
+// Review statuses. A review is completed once the manager has submitted
+// theirs; the self-assessment is expected first but not required.
+const (
+	StatusPending       = "pending"
+	StatusSelfSubmitted = "self_submitted"
+	StatusCompleted     = "completed"
+)
+
+const (
+	typeCycle  = "review_cycle"
+	typeReview = "review"
+)
+
+var (
+	errCycleClosed = errors.New("review cycle is closed")
+	errCompleted   = errors.New("review is already completed")
+)
+
+// inactive are the staff employment statuses that are no longer reviewed
+var inactive = map[string]bool{"terminated": true, "resigned": true, "retired": true, "inactive": true}
+
+// Cycle is one round of reviews, e.g. the 2026 annual review. Its
+// questions are copied from the questionnaire when it opens.
+type Cycle struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	QuestionnaireID string     `json:"questionnaire_id"`
+	Questions       []Question `json:"questions,omitempty"`
+	Kinds           []string   `json:"kinds"`
+	PeriodStart     string     `json:"period_start"`
+	PeriodEnd       string     `json:"period_end"`
+	DueDate         string     `json:"due_date"`
+	Status          string     `json:"status"`
+	OpenedBy        string     `json:"opened_by,omitempty"`
+	OpenedAt        string     `json:"opened_at,omitempty"`
+	ClosedAt        string     `json:"closed_at,omitempty"`
+}
+
+// Assessment is a self-assessment or a manager's review
+type Assessment struct {
+	Answers     []Answer `json:"answers"`
+	Comments    string   `json:"comments,omitempty"`
+	Rating      float64  `json:"rating"`
+	By          string   `json:"by"`
+	SubmittedAt string   `json:"submitted_at"`
+}
+
+// Review is one employee's review in a cycle. Rating is the manager's
+// weighted rating.
+type Review struct {
+	ID         string      `json:"id"`
+	CycleID    string      `json:"cycle_id"`
+	EmployeeID string      `json:"employee_id"`
+	Kind       string      `json:"kind"`
+	Name       string      `json:"name"`
+	Department string      `json:"department"`
+	DueDate    string      `json:"due_date"`
+	Status     string      `json:"status"`
+	Self       *Assessment `json:"self,omitempty"`
+	Manager    *Assessment `json:"manager,omitempty"`
+	Rating     float64     `json:"rating,omitempty"`
+}
+
+// Summary aggregates the reviews of a cycle. Ratings are averaged over
+// completed reviews; Distribution counts them by rating rounded to the
+// nearest whole number.
+type Summary struct {
+	Employees     int                `json:"employees"`
+	Pending       int                `json:"pending"`
+	SelfSubmitted int                `json:"self_submitted"`
+	Completed     int                `json:"completed"`
+	AverageRating float64            `json:"average_rating"`
+	Distribution  map[string]int     `json:"distribution"`
+	Departments   map[string]float64 `json:"departments"`
+}
+
+func cycleID(id string) string {
+	return "cycle:" + id
+}
+
+func reviewID(cycle, kind, employeeID string) string {
+	return "review:" + cycle + ":" + kind + ":" + employeeID
+}
+
+// employee is someone who can be reviewed
+type employee struct {
+	id, kind, name, department string
+	startDate                  string
+}
+
+// loadEmployees reads the active teachers and staff of the given kinds
+func loadEmployees(teachers teacher.Repository, staffs staff.Repository, kinds []string) ([]employee, error) {
+	var employees []employee
+	for _, kind := range kinds {
+		var docs []map[string]interface{}
+		var err error
+		switch kind {
+		case KindTeacher:
+			docs, err = teachers.List()
+		case KindStaff:
+			docs, err = staffs.List()
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			var e struct {
+				FullName         string `json:"full_name"`
+				Department       string `json:"department"`
+				JoiningDate      string `json:"joining_date"`
+				StartDate        string `json:"start_date"`
+				EmploymentStatus string `json:"employment_status"`
+			}
+			if store.Decode(doc, &e) != nil || inactive[strings.ToLower(e.EmploymentStatus)] {
+				continue
+			}
+			id, _ := doc["_id"].(string)
+			start := e.StartDate
+			if kind == KindTeacher {
+				start = e.JoiningDate
+			}
+			employees = append(employees, employee{id: id, kind: kind, name: e.FullName, department: e.Department, startDate: start})
+		}
+	}
+	return employees, nil
+}
+
+// LoadCycle reads a review cycle by its ID
+func LoadCycle(repo Repository, id string) (Cycle, error) {
+	doc, err := repo.Get(cycleID(id))
+	if err != nil {
+		return Cycle{}, err
+	}
+	var c Cycle
+	if doc["type"] != typeCycle || store.Decode(doc, &c) != nil {
+		return Cycle{}, store.ErrNotFound
+	}
+	return c, nil
+}
+
+// Reviews returns the reviews matching keep, ordered by employee name
+func Reviews(repo Repository, keep func(Review) bool) ([]Review, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	reviews := []Review{}
+	for _, doc := range docs {
+		var rv Review
+		if doc["type"] != typeReview || store.Decode(doc, &rv) != nil {
+			continue
+		}
+		rv.ID, _ = doc["_id"].(string)
+		if keep(rv) {
+			reviews = append(reviews, rv)
+		}
+	}
+	sort.SliceStable(reviews, func(i, j int) bool { return reviews[i].Name < reviews[j].Name })
+	return reviews, nil
+}
+
+func summarize(reviews []Review) Summary {
+	s := Summary{
+		Employees:    len(reviews),
+		Distribution: map[string]int{},
+		Departments:  map[string]float64{},
+	}
+	var total float64
+	deptTotal, deptCount := map[string]float64{}, map[string]int{}
+	for _, rv := range reviews {
+		switch rv.Status {
+		case StatusPending:
+			s.Pending++
+		case StatusSelfSubmitted:
+			s.SelfSubmitted++
+		case StatusCompleted:
+			s.Completed++
+			total += rv.Rating
+			s.Distribution[strconv.Itoa(int(rv.Rating+0.5))]++
+			deptTotal[rv.Department] += rv.Rating
+			deptCount[rv.Department]++
+		}
+	}
+	if s.Completed > 0 {
+		s.AverageRating = round2(total / float64(s.Completed))
+	}
+	for dept, sum := range deptTotal {
+		s.Departments[dept] = round2(sum / float64(deptCount[dept]))
+	}
+	return s
+}
+
+// OpenCycle starts a review cycle and creates a pending review for every
+// active employee of its kinds who joined by the end of the period
+// ({"id", "name", "questionnaire_id", "period_start", "period_end",
+// "due_date", "kinds": ["teacher", "staff"]}; kinds defaults to both)
+func OpenCycle(w http.ResponseWriter, r *http.Request, repo Repository, teachers teacher.Repository, staffs staff.Repository) {
+	var c Cycle
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if c.ID == "" || c.Name == "" {
+		http.Error(w, "id and name are required", http.StatusBadRequest)
+		return
+	}
+	for _, d := range []string{c.PeriodStart, c.PeriodEnd, c.DueDate} {
+		if _, err := time.Parse(domain.DateLayout, d); err != nil {
+			http.Error(w, "period_start, period_end and due_date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if c.PeriodEnd < c.PeriodStart || c.DueDate < c.PeriodStart {
+		http.Error(w, "period_end and due_date can't be before period_start", http.StatusBadRequest)
+		return
+	}
+	if len(c.Kinds) == 0 {
+		c.Kinds = []string{KindTeacher, KindStaff}
+	}
+	for _, kind := range c.Kinds {
+		if kind != KindTeacher && kind != KindStaff {
+			http.Error(w, "kinds must be teacher or staff", http.StatusBadRequest)
+			return
+		}
+	}
+	q, err := LoadQuestionnaire(repo, c.QuestionnaireID)
+	if err != nil {
+		http.Error(w, "Questionnaire not found", http.StatusNotFound)
+		return
+	}
+	employees, err := loadEmployees(teachers, staffs, c.Kinds)
+	if err != nil {
+		http.Error(w, "Failed to fetch employees", http.StatusInternalServerError)
+		return
+	}
+
+	caller, _ := auth.FromContext(r.Context())
+	c.Questions = q.Questions
+	c.Status = CycleOpen
+	c.OpenedBy = caller.Email
+	c.OpenedAt = time.Now().UTC().Format(time.RFC3339)
+	_, err = repo.Create(cycleID(c.ID), map[string]interface{}{
+		"type":             typeCycle,
+		"id":               c.ID,
+		"name":             c.Name,
+		"questionnaire_id": c.QuestionnaireID,
+		"questions":        c.Questions,
+		"kinds":            c.Kinds,
+		"period_start":     c.PeriodStart,
+		"period_end":       c.PeriodEnd,
+		"due_date":         c.DueDate,
+		"status":           c.Status,
+		"opened_by":        c.OpenedBy,
+		"opened_at":        c.OpenedAt,
+	})
+	if err == store.ErrConflict {
+		http.Error(w, "Review cycle already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to open review cycle", http.StatusInternalServerError)
+		return
+	}
+
+	created := 0
+	for _, e := range employees {
+		if e.startDate > c.PeriodEnd {
+			continue
+		}
+		_, err := repo.Create(reviewID(c.ID, e.kind, e.id), map[string]interface{}{
+			"type":        typeReview,
+			"cycle_id":    c.ID,
+			"employee_id": e.id,
+			"kind":        e.kind,
+			"name":        e.name,
+			"department":  e.department,
+			"due_date":    c.DueDate,
+			"status":      StatusPending,
+		})
+		if err != nil && err != store.ErrConflict {
+			http.Error(w, "failed to create reviews", http.StatusInternalServerError)
+			return
+		}
+		created++
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Review cycle opened",
+		"cycle":   c,
+		"reviews": created,
+	})
+}
+
+// CloseCycle closes a review cycle to further submissions
+// ({"cycle_id": ".."})
+func CloseCycle(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var request struct {
+		CycleID string `json:"cycle_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := store.Modify(repo, cycleID(request.CycleID), func(doc map[string]interface{}) error {
+		if doc["type"] != typeCycle {
+			return store.ErrNotFound
+		}
+		if doc["status"] == CycleClosed {
+			return errCycleClosed
+		}
+		doc["status"] = CycleClosed
+		doc["closed_at"] = time.Now().UTC().Format(time.RFC3339)
+		return nil
+	})
+	switch {
+	case err == store.ErrNotFound:
+		http.Error(w, "Review cycle not found", http.StatusNotFound)
+		return
+	case err == errCycleClosed:
+		http.Error(w, "review cycle is already closed", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "failed to close review cycle", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "Review cycle closed",
+		"cycle_id":  request.CycleID,
+		"closed_at": doc["closed_at"],
+	})
+}
+
+// GetCycles returns one cycle with its reviews and summary (?id=..), or
+// every cycle with its summary, newest first
+func GetCycles(w http.ResponseWriter, r *http.Request, repo Repository) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		c, err := LoadCycle(repo, id)
+		if err != nil {
+			http.Error(w, "Review cycle not found", http.StatusNotFound)
+			return
+		}
+		reviews, err := Reviews(repo, func(rv Review) bool { return rv.CycleID == id })
+		if err != nil {
+			http.Error(w, "Failed to fetch reviews", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"cycle":   c,
+			"summary": summarize(reviews),
+			"reviews": reviews,
+		})
+		return
+	}
+
+	docs, err := repo.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch review cycles", http.StatusInternalServerError)
+		return
+	}
+	byCycle := make(map[string][]Review)
+	var cycles []Cycle
+	for _, doc := range docs {
+		switch doc["type"] {
+		case typeCycle:
+			var c Cycle
+			if store.Decode(doc, &c) == nil {
+				cycles = append(cycles, c)
+			}
+		case typeReview:
+			var rv Review
+			if store.Decode(doc, &rv) == nil {
+				byCycle[rv.CycleID] = append(byCycle[rv.CycleID], rv)
+			}
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i].PeriodEnd > cycles[j].PeriodEnd })
+	type cycleSummary struct {
+		Cycle
+		Summary Summary `json:"summary"`
+	}
+	out := []cycleSummary{}
+	for _, c := range cycles {
+		c.Questions = nil
+		out = append(out, cycleSummary{c, summarize(byCycle[c.ID])})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(out)
+}
+
+// kindOf returns the employee kind a request is about. Teachers and staff
+// can only reach their own kind of record; admins name it with kind, or
+// leave it empty when the ID is unambiguous.
+func kindOf(caller auth.Identity, kind string) string {
+	switch caller.Role {
+	case auth.Faculty:
+		return KindTeacher
+	case auth.Staff:
+		return KindStaff
+	}
+	return kind
+}
+
+// findReview loads an employee's review in a cycle, trying both kinds
+// when kind is empty
+func findReview(repo Repository, cycle, kind, employeeID string) (map[string]interface{}, error) {
+	kinds := []string{kind}
+	if kind == "" {
+		kinds = []string{KindTeacher, KindStaff}
+	}
+	for _, k := range kinds {
+		doc, err := repo.Get(reviewID(cycle, k, employeeID))
+		if err != store.ErrNotFound {
+			return doc, err
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+// submission is the body of a self-assessment or manager review
+type submission struct {
+	CycleID    string   `json:"cycle_id"`
+	EmployeeID string   `json:"employee_id"`
+	Kind       string   `json:"kind"`
+	Answers    []Answer `json:"answers"`
+	Comments   string   `json:"comments"`
+}
+
+// submit records an assessment on an employee's review in an open cycle.
+// manager says whether it is the manager's review or the self-assessment.
+func submit(w http.ResponseWriter, caller auth.Identity, repo Repository, s submission, manager bool) {
+	c, err := LoadCycle(repo, s.CycleID)
+	if err != nil {
+		http.Error(w, "Review cycle not found", http.StatusNotFound)
+		return
+	}
+	if c.Status != CycleOpen {
+		http.Error(w, "review cycle is closed", http.StatusConflict)
+		return
+	}
+	rating, err := score(c.Questions, s.Answers)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing, err := findReview(repo, c.ID, s.Kind, s.EmployeeID)
+	if err != nil {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+	a := Assessment{
+		Answers:     s.Answers,
+		Comments:    s.Comments,
+		Rating:      rating,
+		By:          caller.Email,
+		SubmittedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	id, _ := existing["_id"].(string)
+	doc, err := store.Modify(repo, id, func(doc map[string]interface{}) error {
+		if manager {
+			doc["manager"] = a
+			doc["rating"] = rating
+			doc["status"] = StatusCompleted
+			return nil
+		}
+		if doc["status"] == StatusCompleted {
+			return errCompleted
+		}
+		doc["self"] = a
+		doc["status"] = StatusSelfSubmitted
+		return nil
+	})
+	switch {
+	case err == errCompleted:
+		http.Error(w, "the manager has already completed this review", http.StatusConflict)
+		return
+	case err == store.ErrConflict:
+		http.Error(w, "review was modified concurrently, retry", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "failed to save review", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Review submitted",
+		"status":  doc["status"],
+		"rating":  rating,
+	})
+}
+
+// SubmitSelfAssessment records the caller's own answers for a cycle
+// (?id=<employee ID>, {"cycle_id", "answers": [{"question_id", "rating",
+// "comment"}], "comments"}). It can be revised until the manager completes
+// the review.
+func SubmitSelfAssessment(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var s submission
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	s.EmployeeID = r.URL.Query().Get("id")
+	s.Kind = kindOf(caller, s.Kind)
+	submit(w, caller, repo, s, false)
+}
+
+// SubmitManagerReview records the manager's answers for an employee and
+// completes their review ({"cycle_id", "employee_id", "kind", "answers",
+// "comments"}). Resubmitting replaces it while the cycle is open.
+func SubmitManagerReview(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var s submission
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if s.EmployeeID == "" {
+		http.Error(w, "employee_id is required", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	submit(w, caller, repo, s, true)
+}
+
+// GetReview returns an employee's review in a cycle together with the
+// cycle's questions (?id=..&cycle_id=.., with kind=teacher or staff if the
+// ID is shared)
+func GetReview(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	employeeID, cycle := query.Get("id"), query.Get("cycle_id")
+	if employeeID == "" || cycle == "" {
+		http.Error(w, "id and cycle_id are required", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+
+	c, err := LoadCycle(repo, cycle)
+	if err != nil {
+		http.Error(w, "Review cycle not found", http.StatusNotFound)
+		return
+	}
+	doc, err := findReview(repo, cycle, kindOf(caller, query.Get("kind")), employeeID)
+	if err != nil {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+	var rv Review
+	if err := store.Decode(doc, &rv); err != nil {
+		http.Error(w, "failed to read review", http.StatusInternalServerError)
+		return
+	}
+	rv.ID, _ = doc["_id"].(string)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cycle":  c,
+		"review": rv,
+	})
+}
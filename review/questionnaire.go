@@ -0,0 +1,207 @@
+// Package review runs performance review cycles for teachers and staff.
+// Each cycle asks every employee in it to rate themselves against a
+// questionnaire before their manager reviews them on the same questions.
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"data-access/auth"
+	"data-access/store"
+)
+
+// Ratings run from MinRating to MaxRating
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+const typeQuestionnaire = "questionnaire"
+
+// Question is one thing an employee is rated on. Weight sets how much it
+// counts towards the overall rating; it defaults to 1.
+type Question struct {
+	ID     string  `json:"id"`
+	Text   string  `json:"text"`
+	Weight float64 `json:"weight"`
+}
+
+// Questionnaire is a reusable set of review questions
+type Questionnaire struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Questions []Question `json:"questions"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt string     `json:"updated_at,omitempty"`
+}
+
+// Answer rates the employee on one question
+type Answer struct {
+	QuestionID string `json:"question_id"`
+	Rating     int    `json:"rating"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+func questionnaireID(id string) string {
+	return "questionnaire:" + id
+}
+
+// validate checks every question has an ID, text and a positive weight
+func (q *Questionnaire) validate() error {
+	if q.ID == "" || q.Title == "" {
+		return fmt.Errorf("id and title are required")
+	}
+	if len(q.Questions) == 0 {
+		return fmt.Errorf("a questionnaire needs at least one question")
+	}
+	seen := make(map[string]bool)
+	for i := range q.Questions {
+		question := &q.Questions[i]
+		if question.ID == "" || question.Text == "" {
+			return fmt.Errorf("question %d: id and text are required", i+1)
+		}
+		if seen[question.ID] {
+			return fmt.Errorf("question %q is defined twice", question.ID)
+		}
+		seen[question.ID] = true
+		if question.Weight == 0 {
+			question.Weight = 1
+		}
+		if question.Weight < 0 {
+			return fmt.Errorf("question %q: weight must be positive", question.ID)
+		}
+	}
+	return nil
+}
+
+// score checks the answers cover every question exactly once with a rating
+// in range, and returns their weighted mean
+func score(questions []Question, answers []Answer) (float64, error) {
+	byID := make(map[string]Answer, len(answers))
+	for _, a := range answers {
+		if _, dup := byID[a.QuestionID]; dup {
+			return 0, fmt.Errorf("question %q is answered twice", a.QuestionID)
+		}
+		byID[a.QuestionID] = a
+	}
+	var total, weights float64
+	for _, question := range questions {
+		a, ok := byID[question.ID]
+		if !ok {
+			return 0, fmt.Errorf("question %q is not answered", question.ID)
+		}
+		if a.Rating < MinRating || a.Rating > MaxRating {
+			return 0, fmt.Errorf("question %q: rating must be from %d to %d", question.ID, MinRating, MaxRating)
+		}
+		total += float64(a.Rating) * question.Weight
+		weights += question.Weight
+		delete(byID, question.ID)
+	}
+	for id := range byID {
+		return 0, fmt.Errorf("unknown question %q", id)
+	}
+	return round2(total / weights), nil
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// LoadQuestionnaire reads a questionnaire by its ID
+func LoadQuestionnaire(repo Repository, id string) (Questionnaire, error) {
+	doc, err := repo.Get(questionnaireID(id))
+	if err != nil {
+		return Questionnaire{}, err
+	}
+	var q Questionnaire
+	if doc["type"] != typeQuestionnaire || store.Decode(doc, &q) != nil {
+		return Questionnaire{}, store.ErrNotFound
+	}
+	return q, nil
+}
+
+// SetQuestionnaire creates or replaces a questionnaire. Cycles already
+// opened with it keep the questions they started with.
+func SetQuestionnaire(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var q Questionnaire
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := q.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	caller, _ := auth.FromContext(r.Context())
+	q.UpdatedBy = caller.Email
+	q.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	doc := map[string]interface{}{
+		"type":       typeQuestionnaire,
+		"id":         q.ID,
+		"title":      q.Title,
+		"questions":  q.Questions,
+		"updated_by": q.UpdatedBy,
+		"updated_at": q.UpdatedAt,
+	}
+	id := questionnaireID(q.ID)
+	existing, err := repo.Get(id)
+	switch err {
+	case nil:
+		_, err = repo.Update(id, doc, store.Rev(existing))
+	case store.ErrNotFound:
+		_, err = repo.Create(id, doc)
+	}
+	if err == store.ErrConflict {
+		http.Error(w, "questionnaire was modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save questionnaire", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Questionnaire saved successfully",
+		"questionnaire": q,
+	})
+}
+
+// GetQuestionnaires returns one questionnaire (?id=..) or all of them by
+// title
+func GetQuestionnaires(w http.ResponseWriter, r *http.Request, repo Repository) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		q, err := LoadQuestionnaire(repo, id)
+		if err != nil {
+			http.Error(w, "Questionnaire not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(q)
+		return
+	}
+
+	docs, err := repo.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch questionnaires", http.StatusInternalServerError)
+		return
+	}
+	questionnaires := []Questionnaire{}
+	for _, doc := range docs {
+		var q Questionnaire
+		if doc["type"] != typeQuestionnaire || store.Decode(doc, &q) != nil {
+			continue
+		}
+		questionnaires = append(questionnaires, q)
+	}
+	sort.Slice(questionnaires, func(i, j int) bool { return questionnaires[i].Title < questionnaires[j].Title })
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(questionnaires)
+}
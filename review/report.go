@@ -0,0 +1,218 @@
+package review
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"data-access/auth"
+	"data-access/domain"
+	"data-access/staff"
+	"data-access/store"
+	"data-access/teacher"
+)
+
+// Reasons an employee is overdue for review
+const (
+	ReasonPastDue       = "past_due" // their review in an open cycle is late
+	ReasonNotRecent     = "not_reviewed_recently"
+	ReasonNeverReviewed = "never_reviewed"
+)
+
+const defaultOverdueMonths = 12
+
+// HistoryEntry is one cycle of an employee's review history
+type HistoryEntry struct {
+	CycleID     string  `json:"cycle_id"`
+	CycleName   string  `json:"cycle_name"`
+	PeriodStart string  `json:"period_start"`
+	PeriodEnd   string  `json:"period_end"`
+	Status      string  `json:"status"`
+	SelfRating  float64 `json:"self_rating,omitempty"`
+	Rating      float64 `json:"rating,omitempty"`
+	Reviewer    string  `json:"reviewer,omitempty"`
+	Comments    string  `json:"comments,omitempty"`
+	Date        string  `json:"date,omitempty"` // when the manager completed it
+}
+
+// Overdue is an employee who needs a review
+type Overdue struct {
+	EmployeeID   string `json:"employee_id"`
+	Kind         string `json:"kind"`
+	Name         string `json:"name"`
+	Department   string `json:"department"`
+	Reason       string `json:"reason"`
+	CycleID      string `json:"cycle_id,omitempty"`
+	DueDate      string `json:"due_date,omitempty"`
+	LastReviewed string `json:"last_reviewed,omitempty"`
+}
+
+// cycles reads every review cycle by ID
+func cycles(repo Repository) (map[string]Cycle, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]Cycle)
+	for _, doc := range docs {
+		var c Cycle
+		if doc["type"] == typeCycle && store.Decode(doc, &c) == nil {
+			byID[c.ID] = c
+		}
+	}
+	return byID, nil
+}
+
+// completedOn is the date the manager completed a review
+func completedOn(rv Review) string {
+	if rv.Status != StatusCompleted || rv.Manager == nil || len(rv.Manager.SubmittedAt) < len(domain.DateLayout) {
+		return ""
+	}
+	return rv.Manager.SubmittedAt[:len(domain.DateLayout)]
+}
+
+// GetHistory returns an employee's reviews across cycles, newest first,
+// with the average of their completed ratings (?id=.., with kind=teacher
+// or staff if the ID is shared)
+func GetHistory(w http.ResponseWriter, r *http.Request, repo Repository) {
+	employeeID := r.URL.Query().Get("id")
+	if employeeID == "" {
+		http.Error(w, "Employee ID missing", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	kind := kindOf(caller, r.URL.Query().Get("kind"))
+
+	byID, err := cycles(repo)
+	if err != nil {
+		http.Error(w, "Failed to fetch review cycles", http.StatusInternalServerError)
+		return
+	}
+	reviews, err := Reviews(repo, func(rv Review) bool {
+		return rv.EmployeeID == employeeID && (kind == "" || rv.Kind == kind)
+	})
+	if err != nil {
+		http.Error(w, "Failed to fetch reviews", http.StatusInternalServerError)
+		return
+	}
+
+	history := []HistoryEntry{}
+	var total float64
+	completed := 0
+	for _, rv := range reviews {
+		c := byID[rv.CycleID]
+		entry := HistoryEntry{
+			CycleID: rv.CycleID, CycleName: c.Name,
+			PeriodStart: c.PeriodStart, PeriodEnd: c.PeriodEnd,
+			Status: rv.Status, Rating: rv.Rating, Date: completedOn(rv),
+		}
+		if rv.Self != nil {
+			entry.SelfRating = rv.Self.Rating
+		}
+		if rv.Manager != nil {
+			entry.Reviewer, entry.Comments = rv.Manager.By, rv.Manager.Comments
+		}
+		if rv.Status == StatusCompleted {
+			total += rv.Rating
+			completed++
+		}
+		history = append(history, entry)
+	}
+	sort.SliceStable(history, func(i, j int) bool { return history[i].PeriodEnd > history[j].PeriodEnd })
+	average := 0.0
+	if completed > 0 {
+		average = round2(total / float64(completed))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"employee_id":    employeeID,
+		"average_rating": average,
+		"reviews":        history,
+	})
+}
+
+// GetOverdue lists active employees who need a review: those whose review
+// in an open cycle is past its due date, and those not reviewed in the
+// last ?months=.. (default 12) who aren't in an open cycle. Employees who
+// joined within that time are not yet due.
+func GetOverdue(w http.ResponseWriter, r *http.Request, repo Repository, teachers teacher.Repository, staffs staff.Repository) {
+	months := defaultOverdueMonths
+	if s := r.URL.Query().Get("months"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			http.Error(w, "months must be a positive number", http.StatusBadRequest)
+			return
+		}
+		months = n
+	}
+	today := time.Now().Format(domain.DateLayout)
+	cutoff := time.Now().AddDate(0, -months, 0).Format(domain.DateLayout)
+
+	employees, err := loadEmployees(teachers, staffs, []string{KindTeacher, KindStaff})
+	if err != nil {
+		http.Error(w, "Failed to fetch employees", http.StatusInternalServerError)
+		return
+	}
+	byID, err := cycles(repo)
+	if err != nil {
+		http.Error(w, "Failed to fetch review cycles", http.StatusInternalServerError)
+		return
+	}
+	reviews, err := Reviews(repo, func(Review) bool { return true })
+	if err != nil {
+		http.Error(w, "Failed to fetch reviews", http.StatusInternalServerError)
+		return
+	}
+
+	active := make(map[string]bool, len(employees)) // kind and employee ID
+	for _, e := range employees {
+		active[e.kind+":"+e.id] = true
+	}
+	lastReviewed := make(map[string]string)
+	inProgress := make(map[string]bool)
+	overdue := []Overdue{}
+	for _, rv := range reviews {
+		key := rv.Kind + ":" + rv.EmployeeID
+		if date := completedOn(rv); date > lastReviewed[key] {
+			lastReviewed[key] = date
+		}
+		if !active[key] || rv.Status == StatusCompleted || byID[rv.CycleID].Status != CycleOpen {
+			continue
+		}
+		inProgress[key] = true
+		if rv.DueDate < today {
+			overdue = append(overdue, Overdue{
+				EmployeeID: rv.EmployeeID, Kind: rv.Kind, Name: rv.Name, Department: rv.Department,
+				Reason: ReasonPastDue, CycleID: rv.CycleID, DueDate: rv.DueDate,
+			})
+		}
+	}
+	for _, e := range employees {
+		key := e.kind + ":" + e.id
+		if inProgress[key] || e.startDate > cutoff {
+			continue
+		}
+		last := lastReviewed[key]
+		if last >= cutoff {
+			continue
+		}
+		reason := ReasonNotRecent
+		if last == "" {
+			reason = ReasonNeverReviewed
+		}
+		overdue = append(overdue, Overdue{
+			EmployeeID: e.id, Kind: e.kind, Name: e.name, Department: e.department,
+			Reason: reason, LastReviewed: last,
+		})
+	}
+	sort.SliceStable(overdue, func(i, j int) bool { return overdue[i].Name < overdue[j].Name })
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"months":  months,
+		"overdue": overdue,
+	})
+}
@@ -0,0 +1,24 @@
+package review
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding review questionnaires, cycles and
+// the reviews themselves
+const DBName = "review_db"
+
+// Repository is the storage backend for review documents
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the review_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
@@ -0,0 +1,107 @@
+package review
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"data-access/domain"
+	"data-access/staff"
+	"data-access/teacher"
+)
+
+func TestScore(t *testing.T) {
+	questions := []Question{{ID: "q1", Weight: 1}, {ID: "q2", Weight: 3}}
+	tests := []struct {
+		name    string
+		answers []Answer
+		want    float64
+		ok      bool
+	}{
+		{"weighted mean", []Answer{{QuestionID: "q1", Rating: 5}, {QuestionID: "q2", Rating: 3}}, 3.5, true},
+		{"rounded", []Answer{{QuestionID: "q1", Rating: 1}, {QuestionID: "q2", Rating: 2}}, 1.75, true},
+		{"missing answer", []Answer{{QuestionID: "q1", Rating: 5}}, 0, false},
+		{"answered twice", []Answer{{QuestionID: "q1", Rating: 5}, {QuestionID: "q1", Rating: 4}, {QuestionID: "q2", Rating: 3}}, 0, false},
+		{"rating too high", []Answer{{QuestionID: "q1", Rating: 6}, {QuestionID: "q2", Rating: 3}}, 0, false},
+		{"rating too low", []Answer{{QuestionID: "q1", Rating: 0}, {QuestionID: "q2", Rating: 3}}, 0, false},
+		{"unknown question", []Answer{{QuestionID: "q1", Rating: 5}, {QuestionID: "q2", Rating: 3}, {QuestionID: "q9", Rating: 3}}, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := score(questions, tt.answers)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("%s: score = %v, %v; want %v, ok %v", tt.name, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	s := summarize([]Review{
+		{Status: StatusPending, Department: "Maths"},
+		{Status: StatusSelfSubmitted, Department: "Maths"},
+		{Status: StatusCompleted, Department: "Maths", Rating: 4.5},
+		{Status: StatusCompleted, Department: "Maths", Rating: 3.4},
+		{Status: StatusCompleted, Department: "Science", Rating: 2},
+	})
+	if s.Employees != 5 || s.Pending != 1 || s.SelfSubmitted != 1 || s.Completed != 3 {
+		t.Errorf("counts = %+v", s)
+	}
+	if s.AverageRating != 3.3 {
+		t.Errorf("AverageRating = %v, want 3.3", s.AverageRating)
+	}
+	// 4.5 rounds up to 5, 3.4 down to 3
+	if len(s.Distribution) != 3 || s.Distribution["5"] != 1 || s.Distribution["3"] != 1 || s.Distribution["2"] != 1 {
+		t.Errorf("Distribution = %v", s.Distribution)
+	}
+	if s.Departments["Maths"] != 3.95 || s.Departments["Science"] != 2 {
+		t.Errorf("Departments = %v", s.Departments)
+	}
+	if empty := summarize(nil); empty.AverageRating != 0 || empty.Employees != 0 {
+		t.Errorf("summarize(nil) = %+v", empty)
+	}
+}
+
+func TestGetOverdue(t *testing.T) {
+	day := func(months int) string { return time.Now().AddDate(0, months, 0).Format(domain.DateLayout) }
+	teachers, staffs, repo := teacher.NewMemoryRepository(), staff.NewMemoryRepository(), NewMemoryRepository()
+	for id, joined := range map[string]string{"late": day(-36), "stale": day(-36), "never": day(-36), "new": day(-1), "recent": day(-36)} {
+		teachers.Create(id, map[string]interface{}{"full_name": id, "joining_date": joined})
+	}
+	staffs.Create("gone", map[string]interface{}{"full_name": "gone", "start_date": day(-36), "employment_status": "Resigned"})
+
+	repo.Create(cycleID("open"), map[string]interface{}{"type": typeCycle, "id": "open", "status": CycleOpen})
+	repo.Create(cycleID("old"), map[string]interface{}{"type": typeCycle, "id": "old", "status": CycleClosed})
+	review := func(cycle, employee, status, due, completed string) {
+		doc := map[string]interface{}{
+			"type": typeReview, "cycle_id": cycle, "employee_id": employee, "kind": KindTeacher,
+			"name": employee, "status": status, "due_date": due,
+		}
+		if completed != "" {
+			doc["manager"] = map[string]interface{}{"submitted_at": completed + "T10:00:00Z"}
+		}
+		repo.Create(reviewID(cycle, KindTeacher, employee), doc)
+	}
+	review("open", "late", StatusPending, day(-1), "")
+	review("old", "stale", StatusCompleted, day(-24), day(-24))
+	review("old", "recent", StatusCompleted, day(-2), day(-2))
+
+	w := httptest.NewRecorder()
+	GetOverdue(w, httptest.NewRequest("GET", "/reviews/overdue", nil), repo, teachers, staffs)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetOverdue = %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Overdue []Overdue `json:"overdue"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	want := map[string]string{"late": ReasonPastDue, "never": ReasonNeverReviewed, "stale": ReasonNotRecent}
+	if len(resp.Overdue) != len(want) {
+		t.Errorf("overdue = %+v, want %v", resp.Overdue, want)
+	}
+	for _, o := range resp.Overdue {
+		if want[o.EmployeeID] != o.Reason {
+			t.Errorf("%s overdue for %q, want %q", o.EmployeeID, o.Reason, want[o.EmployeeID])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"data-access/auth"
+)
+
+// Refresh swaps a refresh token ({"refresh_token"}) for new tokens
+func Refresh(w http.ResponseWriter, r *http.Request, m *Manager) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RefreshToken == "" {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	tokens, err := m.Rotate(request.RefreshToken, time.Now())
+	switch err {
+	case nil:
+	case ErrInvalid, ErrRevoked:
+		http.Error(w, "invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	default:
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout ends the caller's current session
+func Logout(w http.ResponseWriter, r *http.Request, m *Manager) {
+	caller, _ := auth.FromContext(r.Context())
+	if err := m.Revoke(caller.Session, time.Now()); err != nil && err != ErrInvalid {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+}
+
+// LogoutAll ends every session of the caller, or with ?id=.. of another
+// user if the caller is an admin
+func LogoutAll(w http.ResponseWriter, r *http.Request, m *Manager) {
+	caller, _ := auth.FromContext(r.Context())
+	userID := caller.ID
+	if id := r.URL.Query().Get("id"); id != "" && id != caller.ID {
+		if caller.Role != auth.Admin {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		userID = id
+	}
+	n, err := m.RevokeAll(userID, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Logged out of all sessions",
+		"sessions": n,
+	})
+}
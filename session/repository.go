@@ -0,0 +1,23 @@
+package session
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding login sessions and their refresh tokens
+const DBName = "session_db"
+
+// Repository is the storage backend for sessions
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the session_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
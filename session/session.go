@@ -0,0 +1,343 @@
+// Package session issues the tokens users sign in with. Every login opens
+// a session stored in CouchDB and gets a short-lived JWT access token tied
+// to it, plus a refresh token that is swapped for a new pair when the
+// access token runs out. Refresh tokens rotate on every use, and using an
+// old one again revokes the session since it must have been copied.
+// Access tokens are checked against their session, so a revoked session
+// stops working at once rather than when its tokens expire.
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"data-access/auth"
+	"data-access/store"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Errors returned when a token can't be used
+var (
+	ErrInvalid = errors.New("invalid or expired token")
+	ErrRevoked = errors.New("session has been revoked")
+)
+
+const typeSession = "session"
+
+// Options tune a Manager. Zero values take the defaults.
+type Options struct {
+	AccessTTL  time.Duration // lifetime of access tokens, default 15m
+	RefreshTTL time.Duration // how long a session lasts without a refresh, default 30 days
+}
+
+// Manager opens, refreshes and revokes sessions
+type Manager struct {
+	repo   Repository
+	secret []byte
+	opts   Options
+}
+
+// NewManager returns a Manager storing sessions in repo and signing access
+// tokens with secret
+func NewManager(repo Repository, secret []byte, opts Options) *Manager {
+	if opts.AccessTTL <= 0 {
+		opts.AccessTTL = 15 * time.Minute
+	}
+	if opts.RefreshTTL <= 0 {
+		opts.RefreshTTL = 30 * 24 * time.Hour
+	}
+	return &Manager{repo: repo, secret: secret, opts: opts}
+}
+
+// Tokens are handed out on login and refresh
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // seconds until the access token expires
+}
+
+// record is the stored session. Only hashes of refresh tokens are kept;
+// PreviousHash is the token rotated out last, to spot it being replayed.
+type record struct {
+	UserID       string     `json:"user_id"`
+	Email        string     `json:"email"`
+	Role         string     `json:"role"`
+	Students     []string   `json:"students,omitempty"`
+	RefreshHash  string     `json:"refresh_hash"`
+	PreviousHash string     `json:"previous_hash,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RefreshedAt  time.Time  `json:"refreshed_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (rec record) identity(sid string) auth.Identity {
+	role, _ := auth.ParseRole(rec.Role)
+	return auth.Identity{ID: rec.UserID, Email: rec.Email, Role: role, Students: rec.Students, Session: sid}
+}
+
+// encode writes the record's fields into doc, keeping its _id and _rev
+func (rec record) encode(doc map[string]interface{}) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	delete(doc, "revoked_at")
+	for k, v := range fields {
+		doc[k] = v
+	}
+	doc["type"] = typeSession
+	return nil
+}
+
+func docID(sid string) string {
+	return "session:" + sid
+}
+
+// Issue opens a session for user and returns its first tokens
+func (m *Manager) Issue(user auth.Identity, now time.Time) (Tokens, error) {
+	sid, err := randomHex(16)
+	if err != nil {
+		return Tokens{}, err
+	}
+	refresh, err := newRefreshToken(sid)
+	if err != nil {
+		return Tokens{}, err
+	}
+	rec := record{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Role:        string(user.Role),
+		Students:    user.Students,
+		RefreshHash: hash(refresh),
+		CreatedAt:   now,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(m.opts.RefreshTTL),
+	}
+	doc := map[string]interface{}{}
+	if err := rec.encode(doc); err != nil {
+		return Tokens{}, err
+	}
+	if _, err := m.repo.Create(docID(sid), doc); err != nil {
+		return Tokens{}, err
+	}
+	return m.tokens(rec.identity(sid), refresh, now)
+}
+
+// Rotate exchanges a refresh token for a new access and refresh token.
+// Replaying a refresh token that was already rotated revokes the session.
+func (m *Manager) Rotate(refresh string, now time.Time) (Tokens, error) {
+	sid, _, ok := strings.Cut(refresh, ".")
+	if !ok {
+		return Tokens{}, ErrInvalid
+	}
+	next, err := newRefreshToken(sid)
+	if err != nil {
+		return Tokens{}, err
+	}
+	var rec record
+	var result error
+	_, err = store.Modify(m.repo, docID(sid), func(doc map[string]interface{}) error {
+		rec = record{}
+		if err := store.Decode(doc, &rec); err != nil {
+			return err
+		}
+		if rec.RevokedAt != nil {
+			return ErrRevoked
+		}
+		if !now.Before(rec.ExpiresAt) {
+			return ErrInvalid
+		}
+		h := hash(refresh)
+		switch {
+		case equal(h, rec.RefreshHash):
+			rec.PreviousHash, rec.RefreshHash = rec.RefreshHash, hash(next)
+			rec.RefreshedAt, rec.ExpiresAt = now, now.Add(m.opts.RefreshTTL)
+			result = nil
+		case equal(h, rec.PreviousHash):
+			rec.RevokedAt = &now
+			result = ErrRevoked
+		default:
+			return ErrInvalid
+		}
+		return rec.encode(doc)
+	})
+	if err == store.ErrNotFound {
+		return Tokens{}, ErrInvalid
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+	if result != nil {
+		return Tokens{}, result
+	}
+	return m.tokens(rec.identity(sid), next, now)
+}
+
+// Verify checks an access token's signature and expiry and that its
+// session hasn't been revoked, and returns the identity it carries
+func (m *Manager) Verify(token string, now time.Time) (auth.Identity, error) {
+	// Expiry is checked against now below rather than the clock
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	parsed, err := parser.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return m.secret, nil
+	})
+	if err != nil {
+		return auth.Identity{}, ErrInvalid
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid || !claims.VerifyExpiresAt(now.Unix(), true) {
+		return auth.Identity{}, ErrInvalid
+	}
+	user := auth.FromClaims(claims)
+	// Tokens from before sessions existed can't be revoked, so they're
+	// refused and their holders sign in again
+	if user.Session == "" {
+		return auth.Identity{}, ErrInvalid
+	}
+	doc, err := m.repo.Get(docID(user.Session))
+	if err == store.ErrNotFound {
+		return auth.Identity{}, ErrRevoked
+	}
+	if err != nil {
+		return auth.Identity{}, err
+	}
+	var rec record
+	if err := store.Decode(doc, &rec); err != nil {
+		return auth.Identity{}, err
+	}
+	if rec.RevokedAt != nil {
+		return auth.Identity{}, ErrRevoked
+	}
+	return user, nil
+}
+
+// Revoke ends one session
+func (m *Manager) Revoke(sid string, now time.Time) error {
+	_, err := store.Modify(m.repo, docID(sid), func(doc map[string]interface{}) error {
+		var rec record
+		if err := store.Decode(doc, &rec); err != nil {
+			return err
+		}
+		if rec.RevokedAt == nil {
+			rec.RevokedAt = &now
+		}
+		return rec.encode(doc)
+	})
+	if err == store.ErrNotFound {
+		return ErrInvalid
+	}
+	return err
+}
+
+// RevokeAll ends every open session of a user and reports how many there
+// were
+func (m *Manager) RevokeAll(userID string, now time.Time) (int, error) {
+	docs, err := m.repo.List()
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, doc := range docs {
+		var rec record
+		if doc["type"] != typeSession || store.Decode(doc, &rec) != nil {
+			continue
+		}
+		if rec.UserID != userID || rec.RevokedAt != nil || !now.Before(rec.ExpiresAt) {
+			continue
+		}
+		id, _ := doc["_id"].(string)
+		if err := m.Revoke(strings.TrimPrefix(id, "session:"), now); err != nil && err != ErrInvalid {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// Cleanup deletes revoked and expired sessions and reports how many it
+// removed. Their access tokens stay refused as the session is gone.
+func (m *Manager) Cleanup(now time.Time) (int, error) {
+	docs, err := m.repo.List()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, doc := range docs {
+		var rec record
+		if doc["type"] != typeSession || store.Decode(doc, &rec) != nil {
+			continue
+		}
+		if rec.RevokedAt == nil && now.Before(rec.ExpiresAt) {
+			continue
+		}
+		id, _ := doc["_id"].(string)
+		if err := m.repo.Delete(id, store.Rev(doc)); err == nil {
+			removed++
+		} else if err != store.ErrConflict && err != store.ErrNotFound {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// tokens signs an access token for user and pairs it with refresh
+func (m *Manager) tokens(user auth.Identity, refresh string, now time.Time) (Tokens, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return Tokens{}, err
+	}
+	claims := jwt.MapClaims(user.Claims())
+	claims["jti"] = jti
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(m.opts.AccessTTL).Unix()
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{AccessToken: access, RefreshToken: refresh, ExpiresIn: int64(m.opts.AccessTTL.Seconds())}, nil
+}
+
+// newRefreshToken returns "<session id>.<random secret>"
+func newRefreshToken(sid string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return sid + "." + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hash is how refresh tokens are stored. They are long and random, so a
+// plain SHA-256 is enough.
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func equal(a, b string) bool {
+	return b != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
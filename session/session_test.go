@@ -0,0 +1,160 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"data-access/auth"
+)
+
+var (
+	start  = time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	secret = []byte("0123456789abcdef0123456789abcdef")
+	ann    = auth.Identity{ID: "t1", Email: "ann@example.com", Role: auth.Faculty}
+)
+
+func newManager() *Manager {
+	return NewManager(NewMemoryRepository(), secret, Options{AccessTTL: 15 * time.Minute, RefreshTTL: 24 * time.Hour})
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	m := newManager()
+	tokens, err := m.Issue(ann, start)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if tokens.ExpiresIn != 900 {
+		t.Errorf("ExpiresIn = %d, want 900", tokens.ExpiresIn)
+	}
+
+	user, err := m.Verify(tokens.AccessToken, start.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if user.ID != ann.ID || user.Role != auth.Faculty || user.Session == "" {
+		t.Errorf("identity = %+v", user)
+	}
+
+	if _, err := m.Verify(tokens.AccessToken, start.Add(15*time.Minute+time.Second)); err != ErrInvalid {
+		t.Errorf("Verify after AccessTTL = %v, want ErrInvalid", err)
+	}
+	other := NewManager(NewMemoryRepository(), []byte("another secret of at least 32 bytes"), Options{})
+	if _, err := other.Verify(tokens.AccessToken, start); err != ErrInvalid {
+		t.Errorf("Verify with the wrong secret = %v, want ErrInvalid", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	m := newManager()
+	first, _ := m.Issue(ann, start)
+
+	second, err := m.Rotate(first.RefreshToken, start.Add(20*time.Minute))
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Errorf("Rotate returned the same tokens")
+	}
+	if _, err := m.Verify(second.AccessToken, start.Add(21*time.Minute)); err != nil {
+		t.Errorf("Verify of the new access token = %v", err)
+	}
+
+	// Each refresh extends the session by RefreshTTL from then
+	third, err := m.Rotate(second.RefreshToken, start.Add(20*time.Hour))
+	if err != nil {
+		t.Fatalf("Rotate within RefreshTTL of the last refresh = %v", err)
+	}
+	fourth, err := m.Rotate(third.RefreshToken, start.Add(30*time.Hour))
+	if err != nil {
+		t.Fatalf("Rotate past the first RefreshTTL = %v", err)
+	}
+	if _, err := m.Rotate(fourth.RefreshToken, start.Add(55*time.Hour)); err != ErrInvalid {
+		t.Errorf("Rotate after the session expired = %v, want ErrInvalid", err)
+	}
+
+	if _, err := m.Rotate("garbage", start); err != ErrInvalid {
+		t.Errorf("Rotate of a malformed token = %v, want ErrInvalid", err)
+	}
+	if _, err := m.Rotate("unknown.token", start); err != ErrInvalid {
+		t.Errorf("Rotate of an unknown session = %v, want ErrInvalid", err)
+	}
+}
+
+func TestReplayRevokesSession(t *testing.T) {
+	m := newManager()
+	first, _ := m.Issue(ann, start)
+	second, err := m.Rotate(first.RefreshToken, start.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	// Someone else presents the token that was already swapped
+	if _, err := m.Rotate(first.RefreshToken, start.Add(2*time.Minute)); err != ErrRevoked {
+		t.Fatalf("replayed refresh token = %v, want ErrRevoked", err)
+	}
+	if _, err := m.Verify(second.AccessToken, start.Add(3*time.Minute)); err != ErrRevoked {
+		t.Errorf("Verify after replay = %v, want ErrRevoked", err)
+	}
+	if _, err := m.Rotate(second.RefreshToken, start.Add(3*time.Minute)); err != ErrRevoked {
+		t.Errorf("Rotate of the current token after replay = %v, want ErrRevoked", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	m := newManager()
+	phone, _ := m.Issue(ann, start)
+	laptop, _ := m.Issue(ann, start)
+	ben, _ := m.Issue(auth.Identity{ID: "t2", Role: auth.Faculty}, start)
+
+	user, _ := m.Verify(phone.AccessToken, start)
+	if err := m.Revoke(user.Session, start.Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := m.Verify(phone.AccessToken, start.Add(time.Minute)); err != ErrRevoked {
+		t.Errorf("Verify of a revoked session = %v, want ErrRevoked", err)
+	}
+	if _, err := m.Verify(laptop.AccessToken, start.Add(time.Minute)); err != nil {
+		t.Errorf("Revoke ended another session: %v", err)
+	}
+	if err := m.Revoke("missing", start); err != ErrInvalid {
+		t.Errorf("Revoke of an unknown session = %v, want ErrInvalid", err)
+	}
+
+	// Only the laptop session is still open
+	n, err := m.RevokeAll(ann.ID, start.Add(2*time.Minute))
+	if err != nil || n != 1 {
+		t.Fatalf("RevokeAll = %d, %v; want 1", n, err)
+	}
+	if _, err := m.Verify(laptop.AccessToken, start.Add(2*time.Minute)); err != ErrRevoked {
+		t.Errorf("Verify after RevokeAll = %v, want ErrRevoked", err)
+	}
+	if _, err := m.Verify(ben.AccessToken, start.Add(2*time.Minute)); err != nil {
+		t.Errorf("RevokeAll ended another user's session: %v", err)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	repo := NewMemoryRepository()
+	m := NewManager(repo, secret, Options{RefreshTTL: 24 * time.Hour})
+	revoked, _ := m.Issue(ann, start)
+	m.Issue(ann, start)
+	open, _ := m.Issue(ann, start.Add(12*time.Hour))
+
+	user, _ := m.Verify(revoked.AccessToken, start)
+	m.Revoke(user.Session, start.Add(time.Hour))
+
+	// The revoked session and the one left unrefreshed for a day go
+	n, err := m.Cleanup(start.Add(25 * time.Hour))
+	if err != nil || n != 2 {
+		t.Fatalf("Cleanup = %d, %v; want 2", n, err)
+	}
+	if docs, _ := repo.List(); len(docs) != 1 {
+		t.Errorf("%d sessions left, want 1", len(docs))
+	}
+	if _, err := m.Rotate(open.RefreshToken, start.Add(25*time.Hour)); err != nil {
+		t.Errorf("Rotate of the open session after Cleanup = %v", err)
+	}
+	if _, err := m.Verify(revoked.AccessToken, start.Add(time.Minute)); err != ErrRevoked {
+		t.Errorf("Verify of a removed session = %v, want ErrRevoked", err)
+	}
+}
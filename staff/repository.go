@@ -0,0 +1,39 @@
+package staff
+
+import (
+	"log"
+
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding staff documents
+const DBName = "staff_db"
+
+// Kind identifies staff documents in QR tokens and check-ins
+const Kind = "staff"
+
+// Repository is the storage backend for staff documents
+type Repository = store.Repository
+
+// Index lists the fields GetAllStaff can filter and sort by
+var Index = store.Index{
+	Filters: []string{"department", "employment_status", "gender"},
+	Sorts:   []string{"full_name", "department", "job_title", "start_date"},
+}
+
+// NewCouchRepository returns a Repository backed by the staff_db database,
+// creating the views GetAllStaff pages through
+func NewCouchRepository(client *couchdb.Client) Repository {
+	db := store.NewCouchDB(client, DBName)
+	if err := db.EnsureIndex(Index); err != nil {
+		log.Printf("Failed to create %s query views: %v", DBName, err)
+	}
+	return db
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
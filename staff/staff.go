@@ -3,54 +3,41 @@ package staff
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
 
-	"github.com/fjl/go-couchdb"
+	"data-access/domain"
+	"data-access/qrtoken"
+	"data-access/store"
+
 	"github.com/skip2/go-qrcode"
 )
 
-type CustomTime struct {
-	time.Time
-}
+// CustomTime is a "YYYY-MM-DD" date
+type CustomTime = domain.Date
 
-const ctLayout = "2006-01-02"
-
-func (ct *CustomTime) UnmarshalJSON(b []byte) (err error) {
-	s := string(b)
-	if s == "null" {
-		ct.Time = time.Time{}
-		return
-	}
-	ct.Time, err = time.Parse(`"`+ctLayout+`"`, s)
-	return
-}
+const ctLayout = domain.DateLayout
 
 type SchoolStaff struct {
-	ID                      string      `json:"id"`
-	FullName                string      `json:"full_name"`
-	DateOfBirth             CustomTime  `json:"date_of_birth"`
-	Gender                  string      `json:"gender"`
-	ContactNumber           string      `json:"contactNumber"`
-	EmailAddress            string      `json:"emailAddress"`
-	EmergencyContact        string      `json:"emergencyContact"`
-	JobTitle                string      `json:"jobTitle"`
+	domain.Person
+	JobTitle                string      `json:"job_title"`
 	Department              string      `json:"department"`
-	StartDate               CustomTime  `json:"startDate"`
+	StartDate               CustomTime  `json:"start_date"`
 	Salary                  float64     `json:"salary"`
 	Benefits                []string    `json:"benefits"`
-	EducationLevel          string      `json:"educationLevel"`
+	EducationLevel          string      `json:"education_level"`
 	Certifications          []string    `json:"certifications"`
 	Experience              int         `json:"experience"`
-	ProfessionalDevelopment []string    `json:"professionalDevelopment"`
-	CEUs                    int         `json:"CEUs"`
-	EmployeeID              string      `json:"employeeID"`
-	EmploymentStatus        string      `json:"employmentStatus"`
-	WorkHours               string      `json:"workHours"`
-	TimeOff                 []TimeOff   `json:"timeOff"`
-	PayrollInfo             PayrollInfo `json:"payrollInfo"`
+	ProfessionalDevelopment []string    `json:"professional_development"`
+	CEUs                    int         `json:"ceus"`
+	EmployeeID              string      `json:"employee_id"`
+	EmploymentStatus        string      `json:"employment_status"`
+	WorkHours               string      `json:"work_hours"`
+	TimeOff                 []TimeOff   `json:"time_off"`
+	PayrollInfo             PayrollInfo `json:"payroll_info"`
 }
 
 type TimeOff struct {
@@ -59,12 +46,32 @@ type TimeOff struct {
 	Date  CustomTime `json:"date"`
 }
 
-type PayrollInfo struct {
-	DirectDeposit   string             `json:"directDeposit"`
-	TaxWithholdings map[string]float64 `json:"taxWithholdings"`
+// PayrollInfo is the account a staff member is paid into and the
+// percentages of pay withheld, by name
+type PayrollInfo = domain.PayrollInfo
+
+// Document returns the fields stored for a staff member, ID excluded
+func (s SchoolStaff) Document() map[string]interface{} {
+	return s.Person.Document(map[string]interface{}{
+		"job_title":                s.JobTitle,
+		"department":               s.Department,
+		"start_date":               s.StartDate.Format(ctLayout), // Format date for CouchDB
+		"salary":                   s.Salary,
+		"benefits":                 s.Benefits,
+		"education_level":          s.EducationLevel,
+		"certifications":           s.Certifications,
+		"experience":               s.Experience,
+		"professional_development": s.ProfessionalDevelopment,
+		"ceus":                     s.CEUs,
+		"employee_id":              s.EmployeeID,
+		"employment_status":        s.EmploymentStatus,
+		"work_hours":               s.WorkHours,
+		"time_off":                 s.TimeOff,
+		"payroll_info":             s.PayrollInfo,
+	})
 }
 
-func CreateStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func CreateStaff(w http.ResponseWriter, r *http.Request, repo Repository) {
 	var staff SchoolStaff
 
 	// Debug: Log the incoming request body
@@ -78,9 +85,6 @@ func CreateStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client)
 		return
 	}
 
-	// Debug: Log the raw request body
-	log.Printf("Raw request body: %s", string(body))
-
 	// Decode the request body into the staff struct
 	if err := json.Unmarshal(body, &staff); err != nil {
 		log.Printf("Error decoding request body: %v", err)
@@ -88,42 +92,13 @@ func CreateStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client)
 		return
 	}
 
-	// Debug: Log the decoded staff struct
-	log.Printf("Decoded staff: %+v", staff)
+	doc := staff.Document()
 
-	var existingDoc map[string]interface{}
-	err = client.DB("staff_db").Get(staff.ID, &existingDoc, couchdb.Options{})
-	if err == nil {
+	_, err = repo.Create(staff.ID, doc)
+	if err == store.ErrConflict {
 		http.Error(w, "Staff ID already exists", http.StatusConflict)
 		return
 	}
-
-	doc := map[string]interface{}{
-		"_id":                      staff.ID,
-		"full_name":                staff.FullName,
-		"date_of_birth":            staff.DateOfBirth.Format(ctLayout), // Format date for CouchDB
-		"gender":                   staff.Gender,
-		"contact_number":           staff.ContactNumber,
-		"email_address":            staff.EmailAddress,
-		"emergency_contact":        staff.EmergencyContact,
-		"job_title":                staff.JobTitle,
-		"department":               staff.Department,
-		"start_date":               staff.StartDate.Format(ctLayout), // Format date for CouchDB
-		"salary":                   staff.Salary,
-		"benefits":                 staff.Benefits,
-		"education_level":          staff.EducationLevel,
-		"certifications":           staff.Certifications,
-		"experience":               staff.Experience,
-		"professional_development": staff.ProfessionalDevelopment,
-		"CEUs":                     staff.CEUs,
-		"employee_id":              staff.EmployeeID,
-		"employment_status":        staff.EmploymentStatus,
-		"work_hours":               staff.WorkHours,
-		"time_off":                 staff.TimeOff,
-		"payroll_info":             staff.PayrollInfo,
-	}
-
-	_, err = client.DB("staff_db").Put(staff.ID, doc, "")
 	if err != nil {
 		http.Error(w, "failed to create staff", http.StatusInternalServerError)
 		return
@@ -134,15 +109,14 @@ func CreateStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client)
 }
 
 // Retrieve a staff member by ID
-func GetStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func GetStaff(w http.ResponseWriter, r *http.Request, repo Repository) {
 	staffID := r.URL.Query().Get("id")
 	if staffID == "" {
 		http.Error(w, "Staff ID missing", http.StatusBadRequest)
 		return
 	}
 
-	var staff map[string]interface{}
-	err := client.DB("staff_db").Get(staffID, &staff, couchdb.Options{})
+	staff, err := repo.Get(staffID)
 	if err != nil {
 		http.Error(w, "Staff member not found", http.StatusNotFound)
 		return
@@ -152,45 +126,53 @@ func GetStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
 	json.NewEncoder(w).Encode(staff)
 }
 
-func GetAllStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
-	// Define a struct for the result of AllDocs
-	type AllDocsResult struct {
-		Rows []struct {
-			ID  string          `json:"id"`
-			Doc json.RawMessage `json:"doc"`
-		} `json:"rows"`
+// GetAllStaff returns one page of staff members, filtered by any of
+// department, employment_status and gender and ordered by sort (full_name,
+// department, job_title or start_date, "-" prefix for descending, default
+// ID). limit sets the page size; when there are more results the
+// X-Next-Cursor header holds the cursor for the next page.
+func GetAllStaff(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query, err := store.ParseQuery(r.URL.Query(), Index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	var result AllDocsResult
-
-	// Fetch all documents (students) from the "student_db"
-	err := client.DB("staff_db").AllDocs(&result, couchdb.Options{
-		"include_docs": true, // Include full documents, not just IDs
-	})
+	// Fetch one page of documents from the repository
+	page, err := repo.Find(query)
+	if err == store.ErrBadQuery {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, store.ErrUnavailable) {
+		log.Printf("Failed to query %s: %v", DBName, err)
+		http.Error(w, "listing is temporarily unavailable, retry", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch staff members", http.StatusInternalServerError)
 		return
 	}
 
 	// Prepare a slice to store the staff details
-	var staffs []map[string]interface{}
+	staffs := make([]map[string]interface{}, 0, len(page.Docs))
 
 	// Iterate over the rows and append the staff details to the slice
-	for _, row := range result.Rows {
-		var staff map[string]interface{}
-		if err := json.Unmarshal(row.Doc, &staff); err == nil {
-			// Exclude the _rev field if necessary
-			delete(staff, "_rev")
-			staffs = append(staffs, staff)
-		}
+	for _, staff := range page.Docs {
+		// Exclude the _rev field if necessary
+		delete(staff, "_rev")
+		staffs = append(staffs, staff)
 	}
 
 	// Respond with the list of staff members
+	if page.Next != "" {
+		w.Header().Set("X-Next-Cursor", page.Next)
+	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(staffs)
 }
 
-func UpdateStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func UpdateStaff(w http.ResponseWriter, r *http.Request, repo Repository) {
 	var staff SchoolStaff
 
 	if err := json.NewDecoder(r.Body).Decode(&staff); err != nil {
@@ -198,40 +180,27 @@ func UpdateStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client)
 		return
 	}
 
-	var existingDoc map[string]interface{}
-	err := client.DB("staff_db").Get(staff.ID, &existingDoc, couchdb.Options{})
+	existingDoc, err := repo.Get(staff.ID)
 	if err != nil {
 		http.Error(w, "Staff member not found", http.StatusNotFound)
 		return
 	}
 
-	doc := map[string]interface{}{
-		"_id":                      staff.ID,
-		"_rev":                     existingDoc["_rev"],
-		"full_name":                staff.FullName,
-		"date_of_birth":            staff.DateOfBirth.Format(ctLayout), // Format date for CouchDB
-		"gender":                   staff.Gender,
-		"contact_number":           staff.ContactNumber,
-		"email_address":            staff.EmailAddress,
-		"emergency_contact":        staff.EmergencyContact,
-		"job_title":                staff.JobTitle,
-		"department":               staff.Department,
-		"start_date":               staff.StartDate.Format(ctLayout), // Format date for CouchDB
-		"salary":                   staff.Salary,
-		"benefits":                 staff.Benefits,
-		"education_level":          staff.EducationLevel,
-		"certifications":           staff.Certifications,
-		"experience":               staff.Experience,
-		"professional_development": staff.ProfessionalDevelopment,
-		"CEUs":                     staff.CEUs,
-		"employee_id":              staff.EmployeeID,
-		"employment_status":        staff.EmploymentStatus,
-		"work_hours":               staff.WorkHours,
-		"time_off":                 staff.TimeOff,
-		"payroll_info":             staff.PayrollInfo,
+	doc := staff.Document()
+
+	// Fields this endpoint doesn't manage, such as attendance from gate
+	// check-ins and support staff details, are carried over
+	for key, value := range existingDoc {
+		if _, ok := doc[key]; !ok && key != "_id" && key != "_rev" {
+			doc[key] = value
+		}
 	}
 
-	_, err = client.DB("staff_db").Put(staff.ID, doc, existingDoc["_rev"].(string))
+	_, err = repo.Update(staff.ID, doc, store.Rev(existingDoc))
+	if err == store.ErrConflict {
+		http.Error(w, "staff member was modified concurrently, retry", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to update staff member", http.StatusInternalServerError)
 		return
@@ -241,21 +210,20 @@ func UpdateStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Staff member updated successfully"})
 }
 
-func DeleteStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func DeleteStaff(w http.ResponseWriter, r *http.Request, repo Repository) {
 	staffID := r.URL.Query().Get("id")
 	if staffID == "" {
 		http.Error(w, "Staff ID missing", http.StatusBadRequest)
 		return
 	}
 
-	var existingDoc map[string]interface{}
-	err := client.DB("staff_db").Get(staffID, &existingDoc, couchdb.Options{})
+	existingDoc, err := repo.Get(staffID)
 	if err != nil {
 		http.Error(w, "Staff member not found", http.StatusNotFound)
 		return
 	}
 
-	_, err = client.DB("staff_db").Delete(staffID, existingDoc["_rev"].(string))
+	err = repo.Delete(staffID, store.Rev(existingDoc))
 	if err != nil {
 		http.Error(w, "failed to delete staff member", http.StatusInternalServerError)
 		return
@@ -265,7 +233,7 @@ func DeleteStaff(w http.ResponseWriter, r *http.Request, client *couchdb.Client)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Staff member deleted successfully"})
 }
 
-func GenerateAndSaveStaffQRCode(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func GenerateAndSaveStaffQRCode(w http.ResponseWriter, r *http.Request, repo Repository, signer *qrtoken.Signer) {
 	staffID := r.URL.Query().Get("id")
 	if staffID == "" {
 		http.Error(w, "Staff ID missing", http.StatusBadRequest)
@@ -273,31 +241,22 @@ func GenerateAndSaveStaffQRCode(w http.ResponseWriter, r *http.Request, client *
 	}
 
 	// Fetch staff data from CouchDB
-	var staff map[string]interface{}
-	err := client.DB("staff_db").Get(staffID, &staff, couchdb.Options{})
+	staff, err := repo.Get(staffID)
 	if err != nil {
 		http.Error(w, "Staff member not found", http.StatusNotFound)
 		return
 	}
 
-	// Create a map to store the data for the QR code
-	staffDataForQR := map[string]interface{}{
-		"id":             staff["_id"],
-		"full_name":      staff["full_name"],
-		"contact_number": staff["contact_number"],
-		"email_address":  staff["email_address"],
-		"job_title":      staff["jobtitle"],
-	}
-
-	// Convert the QR code data to JSON
-	qrData, err := json.Marshal(staffDataForQR)
+	// The QR code carries only a signed token identifying the holder, so it
+	// can't be forged and doesn't leak contact details
+	token, claims, err := signer.Sign(staffID, Kind, time.Now())
 	if err != nil {
-		http.Error(w, "Failed to marshal staff data for QR code", http.StatusInternalServerError)
+		http.Error(w, "Failed to sign QR code token", http.StatusInternalServerError)
 		return
 	}
 
 	// Generate the QR code in memory
-	qrCode, err := qrcode.Encode(string(qrData), qrcode.Medium, 256)
+	qrCode, err := qrcode.Encode(token, qrcode.Medium, 256)
 	if err != nil {
 		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
 		return
@@ -308,9 +267,10 @@ func GenerateAndSaveStaffQRCode(w http.ResponseWriter, r *http.Request, client *
 
 	// Add the Base64 QR code to the staff document
 	staff["qr_code"] = qrCodeBase64
+	staff["qr_expires_at"] = time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC3339)
 
 	// Update the staff document in the database
-	_, err = client.DB("staff_db").Put(staffID, staff, staff["_rev"].(string))
+	_, err = repo.Update(staffID, staff, store.Rev(staff))
 	if err != nil {
 		http.Error(w, "Failed to save QR code in the database", http.StatusInternalServerError)
 		return
@@ -318,7 +278,8 @@ func GenerateAndSaveStaffQRCode(w http.ResponseWriter, r *http.Request, client *
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "QR code generated and saved successfully",
-		"qr_code": qrCodeBase64,
+		"message":       "QR code generated and saved successfully",
+		"qr_code":       qrCodeBase64,
+		"qr_expires_at": staff["qr_expires_at"].(string),
 	})
 }
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// queryDesignDoc holds the views generated by EnsureIndex
+const queryDesignDoc = "_design/query"
+
+// CouchDB is a Repository backed by a single CouchDB database
+type CouchDB struct {
+	db *couchdb.DB
+
+	mu      sync.Mutex
+	index   *Index // set by EnsureIndex, nil if Find is not supported
+	indexed bool   // the query views for index exist
+}
+
+// NewCouchDB returns a Repository for the named database
+func NewCouchDB(client *couchdb.Client, name string) *CouchDB {
+	return &CouchDB{db: client.DB(name)}
+}
+
+// DB exposes the underlying database for queries the Repository
+// interface does not cover
+func (c *CouchDB) DB() *couchdb.DB {
+	return c.db
+}
+
+func (c *CouchDB) Get(id string) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := c.db.Get(id, &doc, couchdb.Options{}); err != nil {
+		return nil, translate(err)
+	}
+	return doc, nil
+}
+
+func (c *CouchDB) List() ([]map[string]interface{}, error) {
+	var result struct {
+		Rows []struct {
+			ID  string          `json:"id"`
+			Doc json.RawMessage `json:"doc"`
+		} `json:"rows"`
+	}
+	err := c.db.AllDocs(&result, couchdb.Options{
+		"include_docs": true, // Include full documents, not just IDs
+	})
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	docs := make([]map[string]interface{}, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		if strings.HasPrefix(row.ID, "_design/") {
+			continue
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(row.Doc, &doc); err == nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// EnsureIndex creates or updates the views Find uses for idx. There is
+// one view per sort field; each document is emitted once unfiltered as
+// ["", null, sortValue] and once per filter field as [field, value,
+// sortValue], so a query on one filter is a key range scan. If creating
+// the views fails, Find tries again on its next call.
+func (c *CouchDB) EnsureIndex(idx Index) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.index = &idx
+	c.indexed = false
+	if err := c.createViews(idx); err != nil {
+		return err
+	}
+	c.indexed = true
+	return nil
+}
+
+// currentIndex returns the index Find should use, creating its views
+// first if EnsureIndex could not
+func (c *CouchDB) currentIndex() (Index, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.index == nil {
+		return Index{}, ErrBadQuery
+	}
+	if !c.indexed {
+		if err := c.createViews(*c.index); err != nil {
+			return Index{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		c.indexed = true
+	}
+	return *c.index, nil
+}
+
+func (c *CouchDB) createViews(idx Index) error {
+	filters, _ := json.Marshal(idx.Filters)
+	views := map[string]interface{}{}
+	for _, field := range append([]string{"_id"}, idx.Sorts...) {
+		views["by_"+field] = map[string]interface{}{"map": fmt.Sprintf(`function (doc) {
+  var key = doc[%q] === undefined ? null : doc[%q];
+  var filters = %s;
+  emit(["", null, key], null);
+  for (var i = 0; i < filters.length; i++) {
+    if (typeof doc[filters[i]] === "string") emit([filters[i], doc[filters[i]], key], null);
+  }
+}`, field, field, filters)}
+	}
+
+	var existing struct {
+		Rev   string                 `json:"_rev"`
+		Views map[string]interface{} `json:"views"`
+	}
+	err := c.db.Get(queryDesignDoc, &existing, couchdb.Options{})
+	if err != nil && !couchdb.NotFound(err) {
+		return err
+	}
+	if err != nil || !reflect.DeepEqual(normalize(existing.Views), normalize(views)) {
+		doc := map[string]interface{}{"language": "javascript", "views": views}
+		if _, err := c.db.Put(queryDesignDoc, doc, existing.Rev); err != nil {
+			return translate(err)
+		}
+	}
+	return nil
+}
+
+// normalize round-trips v through JSON so stored and generated views compare equal
+func normalize(v interface{}) interface{} {
+	data, _ := json.Marshal(v)
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// Find pages through the view for q's sort field. The first filter in
+// index order narrows the key range; any others are checked on the
+// returned documents.
+func (c *CouchDB) Find(q Query) (Page, error) {
+	index, err := c.currentIndex()
+	if err != nil {
+		return Page{}, err
+	}
+	field := q.sortField()
+	if !index.canSort(field) {
+		return Page{}, ErrBadQuery
+	}
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
+
+	prefix := []interface{}{"", nil}
+	for _, f := range index.Filters {
+		if v, ok := q.Filters[f]; ok {
+			prefix = []interface{}{f, v}
+			break
+		}
+	}
+	// [f, v] sorts before every [f, v, key] and [f, v, {}] after them
+	low, high := prefix, append(append([]interface{}{}, prefix...), map[string]interface{}{})
+	batch := q.Limit + 1
+	if batch < 20 {
+		batch = 20
+	}
+
+	var page Page
+	var last cursor
+	for {
+		opts := couchdb.Options{"include_docs": true, "limit": batch, "startkey": low, "endkey": high}
+		if q.Descending {
+			opts["descending"] = true
+			opts["startkey"], opts["endkey"] = high, low
+		}
+		if after != nil {
+			opts["startkey"] = append(append([]interface{}{}, prefix...), after.Key)
+			opts["startkey_docid"] = after.ID
+		}
+
+		var result struct {
+			Rows []struct {
+				ID  string                 `json:"id"`
+				Key []interface{}          `json:"key"`
+				Doc map[string]interface{} `json:"doc"`
+			} `json:"rows"`
+		}
+		if err := c.db.View(queryDesignDoc, "by_"+field, &result, opts); err != nil {
+			return Page{}, translate(err)
+		}
+
+		for _, row := range result.Rows {
+			if after != nil && row.ID == after.ID && len(row.Key) == 3 && collate(row.Key[2], after.Key) == 0 {
+				continue // the previous page's last row
+			}
+			if len(row.Key) != 3 || row.Doc == nil || !q.matches(row.Doc) {
+				continue
+			}
+			if len(page.Docs) == q.Limit {
+				page.Next = last.encode()
+				return page, nil
+			}
+			page.Docs = append(page.Docs, row.Doc)
+			last = cursor{row.Key[2], row.ID}
+		}
+		if len(result.Rows) < batch {
+			return page, nil
+		}
+		lastRow := result.Rows[len(result.Rows)-1]
+		after = &cursor{lastRow.Key[2], lastRow.ID}
+	}
+}
+
+func (c *CouchDB) Create(id string, doc map[string]interface{}) (string, error) {
+	doc["_id"] = id
+	delete(doc, "_rev")
+	rev, err := c.db.Put(id, doc, "")
+	return rev, translate(err)
+}
+
+func (c *CouchDB) Update(id string, doc map[string]interface{}, rev string) (string, error) {
+	doc["_id"] = id
+	doc["_rev"] = rev
+	newRev, err := c.db.Put(id, doc, rev)
+	return newRev, translate(err)
+}
+
+func (c *CouchDB) Delete(id, rev string) error {
+	_, err := c.db.Delete(id, rev)
+	return translate(err)
+}
+
+// translate maps CouchDB status errors onto the store errors
+func translate(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case couchdb.NotFound(err):
+		return ErrNotFound
+	case couchdb.Conflict(err):
+		return ErrConflict
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Memory is an in-process Repository, used for tests and local runs
+// without a CouchDB server. Documents are copied on the way in and out so
+// callers can't mutate stored state, and revisions follow CouchDB's
+// "<generation>-<hash>" shape.
+type Memory struct {
+	mu   sync.RWMutex
+	docs map[string]map[string]interface{}
+}
+
+// NewMemory returns an empty in-memory Repository
+func NewMemory() *Memory {
+	return &Memory{docs: make(map[string]map[string]interface{})}
+}
+
+func (m *Memory) Get(id string) (map[string]interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	doc, ok := m.docs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(doc)
+}
+
+func (m *Memory) List() ([]map[string]interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Match CouchDB's _all_docs ordering
+	ids := make([]string, 0, len(m.docs))
+	for id := range m.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	docs := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		doc, err := clone(m.docs[id])
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func (m *Memory) Find(q Query) (Page, error) {
+	docs, err := m.List()
+	if err != nil {
+		return Page{}, err
+	}
+	return findInMemory(docs, q)
+}
+
+func (m *Memory) Create(id string, doc map[string]interface{}) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.docs[id]; ok {
+		return "", ErrConflict
+	}
+	return m.put(id, doc, 1)
+}
+
+func (m *Memory) Update(id string, doc map[string]interface{}, rev string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.docs[id]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if Rev(existing) != rev {
+		return "", ErrConflict
+	}
+	return m.put(id, doc, generation(rev)+1)
+}
+
+func (m *Memory) Delete(id, rev string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.docs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if Rev(existing) != rev {
+		return ErrConflict
+	}
+	delete(m.docs, id)
+	return nil
+}
+
+// put stores a copy of doc under the given revision generation.
+// The caller must hold the write lock.
+func (m *Memory) put(id string, doc map[string]interface{}, gen int) (string, error) {
+	stored, err := clone(doc)
+	if err != nil {
+		return "", err
+	}
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	rev := fmt.Sprintf("%d-%s", gen, hex.EncodeToString(suffix))
+	stored["_id"] = id
+	stored["_rev"] = rev
+	m.docs[id] = stored
+	return rev, nil
+}
+
+// generation parses the numeric prefix of a revision
+func generation(rev string) int {
+	n, _ := strconv.Atoi(strings.SplitN(rev, "-", 2)[0])
+	return n
+}
+
+// clone deep-copies a document through JSON, the same way it would
+// travel to and from CouchDB
+func clone(doc map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMemoryCreateGet(t *testing.T) {
+	m := NewMemory()
+	rev, err := m.Create("a", map[string]interface{}{"name": "Ann"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	doc, err := m.Get("a")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if doc["_id"] != "a" || Rev(doc) != rev || doc["name"] != "Ann" {
+		t.Errorf("Get = %v, want _id a, _rev %s, name Ann", doc, rev)
+	}
+
+	// Returned documents are copies
+	doc["name"] = "Changed"
+	if again, _ := m.Get("a"); again["name"] != "Ann" {
+		t.Errorf("mutating a fetched document changed the stored one")
+	}
+
+	if _, err := m.Create("a", map[string]interface{}{}); err != ErrConflict {
+		t.Errorf("Create of an existing ID = %v, want ErrConflict", err)
+	}
+}
+
+func TestMemoryNotFound(t *testing.T) {
+	m := NewMemory()
+	if _, err := m.Get("missing"); err != ErrNotFound {
+		t.Errorf("Get = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Update("missing", map[string]interface{}{}, "1-x"); err != ErrNotFound {
+		t.Errorf("Update = %v, want ErrNotFound", err)
+	}
+	if err := m.Delete("missing", "1-x"); err != ErrNotFound {
+		t.Errorf("Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryRevisionConflicts(t *testing.T) {
+	m := NewMemory()
+	rev1, _ := m.Create("a", map[string]interface{}{"n": 1})
+	rev2, err := m.Update("a", map[string]interface{}{"n": 2}, rev1)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if generation(rev2) != 2 {
+		t.Errorf("revision after one update = %s, want generation 2", rev2)
+	}
+
+	// A writer still holding the first revision loses
+	if _, err := m.Update("a", map[string]interface{}{"n": 3}, rev1); err != ErrConflict {
+		t.Errorf("Update with a stale revision = %v, want ErrConflict", err)
+	}
+	if err := m.Delete("a", rev1); err != ErrConflict {
+		t.Errorf("Delete with a stale revision = %v, want ErrConflict", err)
+	}
+	if doc, _ := m.Get("a"); doc["n"] != float64(2) {
+		t.Errorf("n = %v after rejected writes, want 2", doc["n"])
+	}
+
+	if err := m.Delete("a", rev2); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := m.Get("a"); err != ErrNotFound {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryListOrder(t *testing.T) {
+	m := NewMemory()
+	for _, id := range []string{"c", "a", "b"} {
+		m.Create(id, map[string]interface{}{})
+	}
+	docs, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var ids []string
+	for _, doc := range docs {
+		ids = append(ids, doc["_id"].(string))
+	}
+	if len(ids) != 3 || ids[0] != "a" || ids[1] != "b" || ids[2] != "c" {
+		t.Errorf("List order = %v, want [a b c]", ids)
+	}
+}
+
+// racing bumps a document behind the caller's back the first times it is
+// read, as another writer would
+type racing struct {
+	*Memory
+	races int
+}
+
+func (r *racing) Get(id string) (map[string]interface{}, error) {
+	doc, err := r.Memory.Get(id)
+	if err == nil && r.races > 0 {
+		r.races--
+		stored, _ := r.Memory.Get(id)
+		stored["bumped"] = true
+		r.Memory.Update(id, stored, Rev(stored))
+	}
+	return doc, err
+}
+
+func TestModifyRetriesAfterConflict(t *testing.T) {
+	repo := &racing{Memory: NewMemory(), races: 2}
+	repo.Memory.Create("a", map[string]interface{}{"count": 0})
+
+	calls := 0
+	doc, err := Modify(repo, "a", func(doc map[string]interface{}) error {
+		calls++
+		doc["count"] = doc["count"].(float64) + 1
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Modify: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("change called %d times, want 3", calls)
+	}
+	stored, _ := repo.Memory.Get("a")
+	if stored["count"] != float64(1) || stored["bumped"] != true || Rev(stored) != Rev(doc) {
+		t.Errorf("stored = %v, want count 1 on top of the other writer's change", stored)
+	}
+}
+
+func TestModifyGivesUp(t *testing.T) {
+	repo := &racing{Memory: NewMemory(), races: maxModifyRetries}
+	repo.Memory.Create("a", map[string]interface{}{})
+	_, err := Modify(repo, "a", func(doc map[string]interface{}) error { return nil })
+	if err != ErrConflict {
+		t.Errorf("Modify = %v, want ErrConflict", err)
+	}
+}
+
+func TestModifyAbortsOnChangeError(t *testing.T) {
+	m := NewMemory()
+	rev, _ := m.Create("a", map[string]interface{}{"n": 1})
+	stop := errors.New("stop")
+	_, err := Modify(m, "a", func(doc map[string]interface{}) error {
+		doc["n"] = 2
+		return stop
+	})
+	if err != stop {
+		t.Errorf("Modify = %v, want the change's error", err)
+	}
+	if doc, _ := m.Get("a"); Rev(doc) != rev || doc["n"] != float64(1) {
+		t.Errorf("document changed despite the error: %v", doc)
+	}
+	if _, err := Modify(m, "missing", func(map[string]interface{}) error { return nil }); err != ErrNotFound {
+		t.Errorf("Modify of a missing document = %v, want ErrNotFound", err)
+	}
+}
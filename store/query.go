@@ -0,0 +1,236 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Paging limits for Find
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// ErrBadQuery is returned for filters, sort keys or cursors a repository
+// can't serve
+var ErrBadQuery = errors.New("unsupported query")
+
+// ErrUnavailable is returned by Find when the repository's index can't be
+// reached or built, e.g. while the database is down
+var ErrUnavailable = errors.New("query index unavailable")
+
+// Index declares the fields a repository can be filtered and sorted by.
+// Filters match string fields exactly; documents can always be sorted by
+// _id.
+type Index struct {
+	Filters []string
+	Sorts   []string
+}
+
+func (idx Index) canSort(field string) bool {
+	return field == "_id" || contains(idx.Sorts, field)
+}
+
+// Query selects one page of documents
+type Query struct {
+	// Filters maps field names to the value they must equal
+	Filters    map[string]string
+	Sort       string // field to order by, "" for _id
+	Descending bool
+	Limit      int
+	// Cursor is the Next value of the previous page, "" for the first
+	Cursor string
+}
+
+func (q Query) sortField() string {
+	if q.Sort == "" {
+		return "_id"
+	}
+	return q.Sort
+}
+
+func (q Query) matches(doc map[string]interface{}) bool {
+	for field, want := range q.Filters {
+		if got, _ := doc[field].(string); got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Page is one page of results. Next is empty on the last page.
+type Page struct {
+	Docs []map[string]interface{}
+	Next string
+}
+
+// cursor marks the last document of a page by its sort key and ID
+type cursor struct {
+	Key interface{} `json:"k"`
+	ID  string      `json:"id"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrBadQuery
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrBadQuery
+	}
+	return &c, nil
+}
+
+// ParseQuery reads limit, cursor, sort and the index's filter fields from
+// URL parameters. sort=-field sorts descending.
+func ParseQuery(params url.Values, idx Index) (Query, error) {
+	q := Query{Filters: map[string]string{}, Limit: DefaultLimit, Cursor: params.Get("cursor")}
+	if s := params.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return Query{}, errors.New("limit must be a positive number")
+		}
+		if n > MaxLimit {
+			n = MaxLimit
+		}
+		q.Limit = n
+	}
+	if s := params.Get("sort"); s != "" {
+		q.Descending = strings.HasPrefix(s, "-")
+		q.Sort = strings.TrimPrefix(s, "-")
+		if !idx.canSort(q.Sort) {
+			return Query{}, fmt.Errorf("cannot sort by %s", q.Sort)
+		}
+	}
+	for _, field := range idx.Filters {
+		if v := params.Get(field); v != "" {
+			q.Filters[field] = v
+		}
+	}
+	return q, nil
+}
+
+// findInMemory pages through docs the way the CouchDB views do
+func findInMemory(docs []map[string]interface{}, q Query) (Page, error) {
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	field := q.sortField()
+
+	type row struct {
+		cursor
+		doc map[string]interface{}
+	}
+	var rows []row
+	for _, doc := range docs {
+		if !q.matches(doc) {
+			continue
+		}
+		id, _ := doc["_id"].(string)
+		rows = append(rows, row{cursor{doc[field], id}, doc})
+	}
+	less := func(a, b cursor) bool {
+		if c := collate(a.Key, b.Key); c != 0 {
+			return c < 0 != q.Descending
+		}
+		return a.ID != b.ID && a.ID < b.ID != q.Descending
+	}
+	sort.Slice(rows, func(i, j int) bool { return less(rows[i].cursor, rows[j].cursor) })
+
+	var page Page
+	for _, r := range rows {
+		if after != nil && !less(*after, r.cursor) {
+			continue
+		}
+		if len(page.Docs) == q.Limit {
+			last, _ := page.Docs[len(page.Docs)-1]["_id"].(string)
+			page.Next = cursor{page.Docs[len(page.Docs)-1][field], last}.encode()
+			break
+		}
+		page.Docs = append(page.Docs, r.doc)
+	}
+	return page, nil
+}
+
+// collate approximates CouchDB view collation: null < booleans < numbers
+// < strings < arrays < objects, strings case-insensitively first
+func collate(a, b interface{}) int {
+	rank := func(v interface{}) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64, int:
+			return 2
+		case string:
+			return 3
+		case []interface{}:
+			return 4
+		}
+		return 5
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch x := a.(type) {
+	case bool:
+		y := b.(bool)
+		if x == y {
+			return 0
+		} else if !x {
+			return -1
+		}
+		return 1
+	case float64, int:
+		fx, fy := toFloat(x), toFloat(b)
+		if fx < fy {
+			return -1
+		} else if fx > fy {
+			return 1
+		}
+		return 0
+	case string:
+		y := b.(string)
+		if c := strings.Compare(strings.ToLower(x), strings.ToLower(y)); c != 0 {
+			return c
+		}
+		return strings.Compare(x, y)
+	}
+	return 0
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	}
+	return 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
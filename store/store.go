@@ -0,0 +1,78 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Errors returned by every Repository implementation
+var (
+	ErrNotFound = errors.New("document not found")
+	ErrConflict = errors.New("document revision conflict")
+)
+
+// Repository is the storage backend for one kind of document (students,
+// teachers, staff, ...). Documents are plain JSON objects keyed by "_id";
+// the current revision is carried in "_rev" and must be passed back to
+// Update and Delete.
+type Repository interface {
+	// Get returns the document with the given ID, or ErrNotFound.
+	Get(id string) (map[string]interface{}, error)
+	// List returns every document in the repository.
+	List() ([]map[string]interface{}, error)
+	// Find returns one page of the documents matching q, ErrBadQuery
+	// if the repository has no index for it, or ErrUnavailable if the
+	// index can't be built right now.
+	Find(q Query) (Page, error)
+	// Create stores a new document and returns its revision. It fails
+	// with ErrConflict if the ID is already taken.
+	Create(id string, doc map[string]interface{}) (string, error)
+	// Update replaces the document at rev and returns the new revision.
+	// It fails with ErrConflict if rev is not the current revision.
+	Update(id string, doc map[string]interface{}, rev string) (string, error)
+	// Delete removes the document at rev.
+	Delete(id, rev string) error
+}
+
+// Rev returns the revision stored in a document, or "" if it has none
+func Rev(doc map[string]interface{}) string {
+	rev, _ := doc["_rev"].(string)
+	return rev
+}
+
+// Decode converts a raw document into v by round-tripping it through JSON
+func Decode(doc map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// maxModifyRetries bounds how often Modify retries after losing a race
+// with another writer
+const maxModifyRetries = 3
+
+// Modify reads a document, applies change to it and writes it back,
+// retrying from a fresh read if another writer bumped the revision in
+// between. Errors returned by change abort the update unchanged.
+func Modify(repo Repository, id string, change func(doc map[string]interface{}) error) (map[string]interface{}, error) {
+	for attempt := 0; attempt < maxModifyRetries; attempt++ {
+		doc, err := repo.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := change(doc); err != nil {
+			return nil, err
+		}
+		rev, err := repo.Update(id, doc, Rev(doc))
+		if err == nil {
+			doc["_rev"] = rev
+			return doc, nil
+		}
+		if err != ErrConflict {
+			return nil, err
+		}
+	}
+	return nil, ErrConflict
+}
@@ -0,0 +1,331 @@
+package student
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"data-access/notify"
+	"data-access/store"
+)
+
+// Attendance statuses accepted by the marking endpoints
+const (
+	StatusPresent = "present"
+	StatusAbsent  = "absent"
+	StatusLate    = "late"
+)
+
+var errNoAttendanceEntry = errors.New("no attendance entry for that date")
+
+func validStatus(status string) bool {
+	switch status {
+	case StatusPresent, StatusAbsent, StatusLate:
+		return true
+	}
+	return false
+}
+
+// AttendanceSummary counts a student's attendance over a period
+type AttendanceSummary struct {
+	Total      int     `json:"total"`
+	Present    int     `json:"present"`
+	Absent     int     `json:"absent"`
+	Late       int     `json:"late"`
+	Percentage float64 `json:"percentage"`
+}
+
+// Summarize counts attendance per school day; late arrivals count as
+// attended. A day with several records (e.g. a marked entry plus gate
+// check-ins) counts once, as attended if any record says so.
+func Summarize(records []AttendanceRecord) AttendanceSummary {
+	days := make(map[string]string)
+	for _, rec := range records {
+		status := strings.ToLower(rec.Status)
+		if !validStatus(status) {
+			continue
+		}
+		day := rec.Date.Format(ctLayout)
+		if prev, ok := days[day]; !ok || prev == StatusAbsent || (prev == StatusLate && status == StatusPresent) {
+			days[day] = status
+		}
+	}
+
+	var s AttendanceSummary
+	for _, status := range days {
+		switch status {
+		case StatusPresent:
+			s.Present++
+		case StatusAbsent:
+			s.Absent++
+		case StatusLate:
+			s.Late++
+		}
+		s.Total++
+	}
+	if s.Total > 0 {
+		pct := float64(s.Present+s.Late) / float64(s.Total) * 100
+		s.Percentage = math.Round(pct*100) / 100
+	}
+	return s
+}
+
+// attendanceRecords decodes the attendance list stored on a student document
+func attendanceRecords(doc map[string]interface{}) ([]AttendanceRecord, error) {
+	var student struct {
+		AttendanceRecords []AttendanceRecord `json:"attendance_records"`
+	}
+	if err := store.Decode(doc, &student); err != nil {
+		return nil, err
+	}
+	return student.AttendanceRecords, nil
+}
+
+// setAttendance records the status for a date, overriding every existing
+// entry for that day
+func setAttendance(records []AttendanceRecord, date time.Time, status string) []AttendanceRecord {
+	day := date.Format(ctLayout)
+	found := false
+	for i := range records {
+		if records[i].Date.Format(ctLayout) == day {
+			records[i].Status = status
+			found = true
+		}
+	}
+	if found {
+		return records
+	}
+	records = append(records, AttendanceRecord{Date: CustomTime{Time: date}, Status: status})
+	sort.Slice(records, func(i, j int) bool { return records[i].Date.Before(records[j].Date.Time) })
+	return records
+}
+
+// updateAttendance applies change to a student's attendance records and
+// saves the document
+func updateAttendance(repo Repository, studentID string, change func([]AttendanceRecord) ([]AttendanceRecord, error)) error {
+	_, err := store.Modify(repo, studentID, func(doc map[string]interface{}) error {
+		records, err := attendanceRecords(doc)
+		if err != nil {
+			return err
+		}
+		if records, err = change(records); err != nil {
+			return err
+		}
+		doc["attendance_records"] = records
+		return nil
+	})
+	return err
+}
+
+// MarkClassAttendance records attendance for a whole class (and optionally
+// section) on one date. Students not listed in entries get default_status;
+// if that is empty they are left unmarked. With notify_absent the parents
+// of students marked absent are sent an absence alert.
+func MarkClassAttendance(w http.ResponseWriter, r *http.Request, repo Repository, notifier notify.Notifier, parents notify.Recipients) {
+	var request struct {
+		Class         string `json:"class"`
+		Section       string `json:"section"`
+		Date          string `json:"date"`
+		DefaultStatus string `json:"default_status"`
+		NotifyAbsent  bool   `json:"notify_absent"`
+		Entries       []struct {
+			StudentID string `json:"student_id"`
+			Status    string `json:"status"`
+		} `json:"entries"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if request.Class == "" {
+		http.Error(w, "class missing", http.StatusBadRequest)
+		return
+	}
+	date, err := time.Parse(ctLayout, request.Date)
+	if err != nil {
+		http.Error(w, "date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	request.DefaultStatus = strings.ToLower(request.DefaultStatus)
+	if request.DefaultStatus != "" && !validStatus(request.DefaultStatus) {
+		http.Error(w, "invalid default_status", http.StatusBadRequest)
+		return
+	}
+
+	statuses := make(map[string]string, len(request.Entries))
+	for _, entry := range request.Entries {
+		status := strings.ToLower(entry.Status)
+		if !validStatus(status) {
+			http.Error(w, "invalid status for student "+entry.StudentID, http.StatusBadRequest)
+			return
+		}
+		statuses[entry.StudentID] = status
+	}
+
+	docs, err := repo.List()
+	if err != nil {
+		http.Error(w, "Failed to fetch students", http.StatusInternalServerError)
+		return
+	}
+
+	// Work out who gets marked before writing anything, so a typo in one
+	// student ID doesn't leave the class half-marked
+	marks := make(map[string]string)
+	names := make(map[string]string)
+	for _, doc := range docs {
+		id, _ := doc["_id"].(string)
+		class, _ := doc["class"].(string)
+		section, _ := doc["section"].(string)
+		if class != request.Class || (request.Section != "" && section != request.Section) {
+			continue
+		}
+		names[id], _ = doc["full_name"].(string)
+		if status, ok := statuses[id]; ok {
+			marks[id] = status
+		} else if request.DefaultStatus != "" {
+			marks[id] = request.DefaultStatus
+		}
+	}
+	for id := range statuses {
+		if _, ok := marks[id]; !ok {
+			http.Error(w, "student "+id+" is not in this class", http.StatusBadRequest)
+			return
+		}
+	}
+
+	failed := []string{}
+	alertsQueued := 0
+	for id, status := range marks {
+		err := updateAttendance(repo, id, func(records []AttendanceRecord) ([]AttendanceRecord, error) {
+			return setAttendance(records, date, status), nil
+		})
+		if err != nil {
+			failed = append(failed, id)
+			continue
+		}
+		if !request.NotifyAbsent || status != StatusAbsent {
+			continue
+		}
+		// A missed alert mustn't undo the attendance, so failures are
+		// only reflected in the count
+		to, err := parents(id)
+		if err != nil || len(to) == 0 {
+			continue
+		}
+		err = notifier.Send(notify.AbsenceAlert(to, notify.AbsenceAlertData{
+			StudentName: names[id], Class: request.Class, Section: request.Section, Date: request.Date,
+		}))
+		if err == nil {
+			alertsQueued++
+		}
+	}
+	sort.Strings(failed)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Attendance marked",
+		"marked":        len(marks) - len(failed),
+		"failed":        failed,
+		"alerts_queued": alertsQueued,
+	})
+}
+
+// CorrectAttendance changes the status of an existing attendance entry
+func CorrectAttendance(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var request struct {
+		StudentID string `json:"student_id"`
+		Date      string `json:"date"`
+		Status    string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	date, err := time.Parse(ctLayout, request.Date)
+	if err != nil {
+		http.Error(w, "date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	status := strings.ToLower(request.Status)
+	if !validStatus(status) {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+
+	err = updateAttendance(repo, request.StudentID, func(records []AttendanceRecord) ([]AttendanceRecord, error) {
+		for _, rec := range records {
+			if rec.Date.Format(ctLayout) == request.Date {
+				return setAttendance(records, date, status), nil
+			}
+		}
+		return nil, errNoAttendanceEntry
+	})
+	if err == store.ErrNotFound {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+	if err == errNoAttendanceEntry {
+		http.Error(w, "attendance entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to correct attendance", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Attendance corrected successfully"})
+}
+
+// GetAttendance returns a student's attendance between the optional from
+// and to dates (inclusive) along with the attendance percentage
+func GetAttendance(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	studentID := query.Get("id")
+	if studentID == "" {
+		http.Error(w, "Student ID missing", http.StatusBadRequest)
+		return
+	}
+
+	// Dates are compared as YYYY-MM-DD strings, which sort chronologically
+	from, to := query.Get("from"), query.Get("to")
+	for _, s := range []string{from, to} {
+		if _, err := time.Parse(ctLayout, s); s != "" && err != nil {
+			http.Error(w, "from and to must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	doc, err := repo.Get(studentID)
+	if err != nil {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+	records, err := attendanceRecords(doc)
+	if err != nil {
+		http.Error(w, "failed to read attendance records", http.StatusInternalServerError)
+		return
+	}
+
+	inRange := []AttendanceRecord{}
+	for _, rec := range records {
+		day := rec.Date.Format(ctLayout)
+		if (from != "" && day < from) || (to != "" && day > to) {
+			continue
+		}
+		inRange = append(inRange, rec)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"student_id": studentID,
+		"from":       from,
+		"to":         to,
+		"records":    inRange,
+		"summary":    Summarize(inRange),
+	})
+}
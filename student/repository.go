@@ -0,0 +1,39 @@
+package student
+
+import (
+	"log"
+
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding student documents
+const DBName = "student_db"
+
+// Kind identifies student documents in QR tokens and check-ins
+const Kind = "student"
+
+// Repository is the storage backend for student documents
+type Repository = store.Repository
+
+// Index lists the fields GetAllStudents can filter and sort by
+var Index = store.Index{
+	Filters: []string{"class", "section", "gender", "email_address"},
+	Sorts:   []string{"full_name", "roll_number", "admission_date"},
+}
+
+// NewCouchRepository returns a Repository backed by the student_db database,
+// creating the views GetAllStudents pages through
+func NewCouchRepository(client *couchdb.Client) Repository {
+	db := store.NewCouchDB(client, DBName)
+	if err := db.EnsureIndex(Index); err != nil {
+		log.Printf("Failed to create %s query views: %v", DBName, err)
+	}
+	return db
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
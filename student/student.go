@@ -3,41 +3,27 @@ package student
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
 
-	"github.com/fjl/go-couchdb"
+	"data-access/domain"
+	"data-access/qrtoken"
+	"data-access/store"
+
 	"github.com/skip2/go-qrcode"
 )
 
-type CustomTime struct {
-	time.Time
-}
-
-const ctLayout = "2006-01-02"
+// CustomTime is a "YYYY-MM-DD" date
+type CustomTime = domain.Date
 
-func (ct *CustomTime) UnmarshalJSON(b []byte) (err error) {
-	s := string(b)
-	if s == "null" {
-		ct.Time = time.Time{}
-		return
-	}
-	ct.Time, err = time.Parse(`"`+ctLayout+`"`, s)
-	return
-}
+const ctLayout = domain.DateLayout
 
 // Student struct
 type Student struct {
-	ID                        string             `json:"id"`
-	FullName                  string             `json:"full_name"`
-	DateOfBirth               CustomTime         `json:"date_of_birth"`
-	Gender                    string             `json:"gender"`
-	Address                   string             `json:"address"`
-	ContactNumber             string             `json:"contact_number"`
-	EmailAddress              string             `json:"email_address"`
-	EmergencyContact          string             `json:"emergency_contact"`
+	domain.Person
 	Class                     string             `json:"class"`
 	Section                   string             `json:"section"`
 	RollNumber                string             `json:"roll_number"`
@@ -45,8 +31,6 @@ type Student struct {
 	AttendanceRecords         []AttendanceRecord `json:"attendance_records"`
 	ExamScores                []ExamScore        `json:"exam_scores"`
 	ExtracurricularActivities []string           `json:"extracurricular_activities"`
-	BehavioralRecords         []BehavioralRecord `json:"behavioral_records"`
-	HealthRecords             []HealthRecord     `json:"health_records"`
 	AdmissionDate             CustomTime         `json:"admission_date"`
 	PreviousSchool            string             `json:"previous_school"`
 	FeePaymentRecords         []FeePaymentRecord `json:"fee_payment_records"`
@@ -57,28 +41,30 @@ type Student struct {
 type AttendanceRecord struct {
 	Date   CustomTime `json:"date"`
 	Status string     `json:"status"`
+	// CheckedInAt is set when the record came from a QR check-in
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
 }
 
-// ExamScore struct
+// ExamScore struct. Scores entered through the gradebook carry the exam
+// they belong to; older free-form entries only have subject, score and grade.
 type ExamScore struct {
-	Subject string  `json:"subject"`
-	Score   float64 `json:"score"`
-	Grade   string  `json:"grade"`
+	ExamID   string  `json:"exam_id,omitempty"`
+	Term     string  `json:"term,omitempty"`
+	Subject  string  `json:"subject"`
+	Score    float64 `json:"score"`
+	MaxMarks float64 `json:"max_marks,omitempty"`
+	Grade    string  `json:"grade"`
 }
 
-// BehavioralRecord struct
+// BehavioralRecord is a remark once kept in a student document's
+// behavioral_records. Incidents are now recorded by the incident package;
+// the type remains for migrating old documents.
 type BehavioralRecord struct {
 	Date        CustomTime `json:"date"`
 	Incident    string     `json:"incident"`
 	ActionTaken string     `json:"action_taken"`
 }
 
-// HealthRecord struct
-type HealthRecord struct {
-	Condition string `json:"condition"`
-	Notes     string `json:"notes"`
-}
-
 // FeePaymentRecord struct
 type FeePaymentRecord struct {
 	Date   CustomTime `json:"date"`
@@ -93,9 +79,21 @@ type Scholarship struct {
 	DateAwarded CustomTime `json:"date_awarded"`
 }
 
+// ConfidentialFields are never returned by the student endpoints. Health
+// records are kept in the health package's own store; the field only
+// lingers on documents that have not been migrated yet.
+var ConfidentialFields = []string{"health_records"}
+
+// Redact removes the confidential fields from a student document
+func Redact(doc map[string]interface{}) {
+	for _, field := range ConfidentialFields {
+		delete(doc, field)
+	}
+}
+
 // CRUD operations for students
 
-func CreateStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func CreateStudent(w http.ResponseWriter, r *http.Request, repo Repository) {
 	var student Student
 
 	// Debug: Log the incoming request body
@@ -109,9 +107,6 @@ func CreateStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		return
 	}
 
-	// Debug: Log the raw request body
-	log.Printf("Raw request body: %s", string(body))
-
 	// Decode the request body into the student struct
 	if err := json.Unmarshal(body, &student); err != nil {
 		log.Printf("Error decoding request body: %v", err)
@@ -119,25 +114,7 @@ func CreateStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		return
 	}
 
-	// Debug: Log the decoded student struct
-	log.Printf("Decoded student: %+v", student)
-
-	var existingDoc map[string]interface{}
-	err = client.DB("student_db").Get(student.ID, &existingDoc, couchdb.Options{})
-	if err == nil {
-		http.Error(w, "Student ID already exists", http.StatusConflict)
-		return
-	}
-
-	doc := map[string]interface{}{
-		"_id":                        student.ID,
-		"full_name":                  student.FullName,
-		"date_of_birth":              student.DateOfBirth.Format(ctLayout), // Format date for CouchDB
-		"gender":                     student.Gender,
-		"address":                    student.Address,
-		"contact_number":             student.ContactNumber,
-		"email_address":              student.EmailAddress,
-		"emergency_contact":          student.EmergencyContact,
+	doc := student.Person.Document(map[string]interface{}{
 		"class":                      student.Class,
 		"section":                    student.Section,
 		"roll_number":                student.RollNumber,
@@ -145,15 +122,17 @@ func CreateStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		"attendance_records":         student.AttendanceRecords,
 		"exam_scores":                student.ExamScores,
 		"extracurricular_activities": student.ExtracurricularActivities,
-		"behavioral_records":         student.BehavioralRecords,
-		"health_records":             student.HealthRecords,
 		"admission_date":             student.AdmissionDate.Format(ctLayout), // Format date for CouchDB
 		"previous_school":            student.PreviousSchool,
 		"fee_payment_records":        student.FeePaymentRecords,
 		"scholarships":               student.Scholarships,
-	}
+	})
 
-	_, err = client.DB("student_db").Put(student.ID, doc, "")
+	_, err = repo.Create(student.ID, doc)
+	if err == store.ErrConflict {
+		http.Error(w, "Student ID already exists", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to create student", http.StatusInternalServerError)
 		return
@@ -163,7 +142,7 @@ func CreateStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 	json.NewEncoder(w).Encode(map[string]string{"message": "Student created successfully"})
 }
 
-func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, repo Repository, signer *qrtoken.Signer) {
 	studentID := r.URL.Query().Get("id")
 	if studentID == "" {
 		http.Error(w, "Student ID missing", http.StatusBadRequest)
@@ -171,31 +150,22 @@ func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, client *couch
 	}
 
 	// Fetch student data from CouchDB
-	var student map[string]interface{}
-	err := client.DB("student_db").Get(studentID, &student, couchdb.Options{})
+	student, err := repo.Get(studentID)
 	if err != nil {
 		http.Error(w, "Student not found", http.StatusNotFound)
 		return
 	}
 
-	// Create a map to store the data for the QR code
-	studentDataForQR := map[string]interface{}{
-		"id":             student["_id"],
-		"full_name":      student["full_name"],
-		"contact_number": student["contact_number"],
-		"email_address":  student["email_address"],
-		"class":          student["class"],
-	}
-
-	// Convert the QR code data to JSON
-	qrData, err := json.Marshal(studentDataForQR)
+	// The QR code carries only a signed token identifying the holder, so it
+	// can't be forged and doesn't leak contact details
+	token, claims, err := signer.Sign(studentID, Kind, time.Now())
 	if err != nil {
-		http.Error(w, "Failed to marshal student data for QR code", http.StatusInternalServerError)
+		http.Error(w, "Failed to sign QR code token", http.StatusInternalServerError)
 		return
 	}
 
 	// Generate the QR code in memory
-	qrCode, err := qrcode.Encode(string(qrData), qrcode.Medium, 256)
+	qrCode, err := qrcode.Encode(token, qrcode.Medium, 256)
 	if err != nil {
 		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
 		return
@@ -206,9 +176,10 @@ func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, client *couch
 
 	// Add the Base64 QR code to the student document
 	student["qr_code"] = qrCodeBase64
+	student["qr_expires_at"] = time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC3339)
 
 	// Update the student document in the database
-	_, err = client.DB("student_db").Put(studentID, student, student["_rev"].(string))
+	_, err = repo.Update(studentID, student, store.Rev(student))
 	if err != nil {
 		http.Error(w, "Failed to save QR code in the database", http.StatusInternalServerError)
 		return
@@ -216,70 +187,79 @@ func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, client *couch
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "QR code generated and saved successfully",
-		"qr_code": qrCodeBase64,
+		"message":       "QR code generated and saved successfully",
+		"qr_code":       qrCodeBase64,
+		"qr_expires_at": student["qr_expires_at"].(string),
 	})
 }
 
 // Retrieve a student by ID
-func GetStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func GetStudent(w http.ResponseWriter, r *http.Request, repo Repository) {
 	studentID := r.URL.Query().Get("id")
 	if studentID == "" {
 		http.Error(w, "Student ID missing", http.StatusBadRequest)
 		return
 	}
 
-	// var student Student
-	var student map[string]interface{}
-	err := client.DB("student_db").Get(studentID, &student, couchdb.Options{})
+	student, err := repo.Get(studentID)
 	if err != nil {
 		http.Error(w, "Student not found", http.StatusNotFound)
 		return
 	}
 
+	Redact(student)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(student)
 }
 
-func GetAllStudents(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
-	// Define a struct for the result of AllDocs
-	type AllDocsResult struct {
-		Rows []struct {
-			ID  string          `json:"id"`
-			Doc json.RawMessage `json:"doc"`
-		} `json:"rows"`
+// GetAllStudents returns one page of students, filtered by any of class,
+// section and gender and ordered by sort (full_name, roll_number or
+// admission_date, "-" prefix for descending, default ID). limit sets the
+// page size; when there are more results the X-Next-Cursor header holds
+// the cursor for the next page.
+func GetAllStudents(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query, err := store.ParseQuery(r.URL.Query(), Index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	var result AllDocsResult
-
-	// Fetch all documents (students) from the "student_db"
-	err := client.DB("student_db").AllDocs(&result, couchdb.Options{
-		"include_docs": true, // Include full documents, not just IDs
-	})
+	// Fetch one page of documents from the repository
+	page, err := repo.Find(query)
+	if err == store.ErrBadQuery {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, store.ErrUnavailable) {
+		log.Printf("Failed to query %s: %v", DBName, err)
+		http.Error(w, "listing is temporarily unavailable, retry", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch students", http.StatusInternalServerError)
 		return
 	}
 
 	// Prepare a slice to store the student details
-	var students []map[string]interface{}
+	students := make([]map[string]interface{}, 0, len(page.Docs))
 
 	// Iterate over the rows and append the student details to the slice
-	for _, row := range result.Rows {
-		var student map[string]interface{}
-		if err := json.Unmarshal(row.Doc, &student); err == nil {
-			// Exclude the _rev field if necessary
-			delete(student, "_rev")
-			students = append(students, student)
-		}
+	for _, student := range page.Docs {
+		// Exclude the _rev field if necessary
+		delete(student, "_rev")
+		Redact(student)
+		students = append(students, student)
 	}
 
 	// Respond with the list of students
+	if page.Next != "" {
+		w.Header().Set("X-Next-Cursor", page.Next)
+	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(students)
 }
 
-func UpdateStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func UpdateStudent(w http.ResponseWriter, r *http.Request, repo Repository) {
 	var student Student
 
 	if err := json.NewDecoder(r.Body).Decode(&student); err != nil {
@@ -287,23 +267,13 @@ func UpdateStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		return
 	}
 
-	var existingDoc map[string]interface{}
-	err := client.DB("student_db").Get(student.ID, &existingDoc, couchdb.Options{})
+	existingDoc, err := repo.Get(student.ID)
 	if err != nil {
 		http.Error(w, "Student not found", http.StatusNotFound)
 		return
 	}
 
-	doc := map[string]interface{}{
-		"_id":                        student.ID,
-		"_rev":                       existingDoc["_rev"],
-		"full_name":                  student.FullName,
-		"date_of_birth":              student.DateOfBirth.Format("2006-01-02"), // Format date for CouchDB
-		"gender":                     student.Gender,
-		"address":                    student.Address,
-		"contact_number":             student.ContactNumber,
-		"email_address":              student.EmailAddress,
-		"emergency_contact":          student.EmergencyContact,
+	doc := student.Person.Document(map[string]interface{}{
 		"class":                      student.Class,
 		"section":                    student.Section,
 		"roll_number":                student.RollNumber,
@@ -311,15 +281,22 @@ func UpdateStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		"attendance_records":         student.AttendanceRecords,
 		"exam_scores":                student.ExamScores,
 		"extracurricular_activities": student.ExtracurricularActivities,
-		"behavioral_records":         student.BehavioralRecords,
-		"health_records":             student.HealthRecords,
-		"admission_date":             student.AdmissionDate.Format("2006-01-02"), // Format date for CouchDB
+		"admission_date":             student.AdmissionDate.Format(ctLayout), // Format date for CouchDB
 		"previous_school":            student.PreviousSchool,
 		"fee_payment_records":        student.FeePaymentRecords,
 		"scholarships":               student.Scholarships,
+	})
+
+	// Report card comments are written by their own endpoint
+	if comments, ok := existingDoc["report_comments"]; ok {
+		doc["report_comments"] = comments
 	}
 
-	_, err = client.DB("student_db").Put(student.ID, doc, existingDoc["_rev"].(string))
+	_, err = repo.Update(student.ID, doc, store.Rev(existingDoc))
+	if err == store.ErrConflict {
+		http.Error(w, "student was modified concurrently, retry", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to update student", http.StatusInternalServerError)
 		return
@@ -330,21 +307,20 @@ func UpdateStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 }
 
 // Delete student by ID
-func DeleteStudent(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func DeleteStudent(w http.ResponseWriter, r *http.Request, repo Repository) {
 	studentID := r.URL.Query().Get("id")
 	if studentID == "" {
 		http.Error(w, "Student ID missing", http.StatusBadRequest)
 		return
 	}
 
-	var existingDoc map[string]interface{}
-	err := client.DB("student_db").Get(studentID, &existingDoc, couchdb.Options{})
+	existingDoc, err := repo.Get(studentID)
 	if err != nil {
 		http.Error(w, "Student not found", http.StatusNotFound)
 		return
 	}
 
-	_, err = client.DB("student_db").Delete(studentID, existingDoc["_rev"].(string))
+	err = repo.Delete(studentID, store.Rev(existingDoc))
 	if err != nil {
 		http.Error(w, "failed to delete student", http.StatusInternalServerError)
 		return
@@ -0,0 +1,131 @@
+package student
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"data-access/store"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func call(handler func(http.ResponseWriter, *http.Request, Repository), repo Repository, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, target, strings.NewReader(body)), repo)
+	return w
+}
+
+func TestCreateAndGetStudent(t *testing.T) {
+	repo := NewMemoryRepository()
+	body := `{"id":"s1","full_name":"Ann Lee","class":"5","section":"A","admission_date":"2024-06-01"}`
+
+	if w := call(CreateStudent, repo, "POST", "/students", body); w.Code != http.StatusOK {
+		t.Fatalf("CreateStudent = %d %s", w.Code, w.Body)
+	}
+	if w := call(CreateStudent, repo, "POST", "/students", body); w.Code != http.StatusConflict {
+		t.Errorf("creating the same ID again = %d, want 409", w.Code)
+	}
+
+	w := call(GetStudent, repo, "GET", "/students/get?id=s1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetStudent = %d %s", w.Code, w.Body)
+	}
+	var got map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&got)
+	if got["full_name"] != "Ann Lee" || got["class"] != "5" || got["admission_date"] != "2024-06-01" {
+		t.Errorf("GetStudent = %v", got)
+	}
+
+	if w := call(GetStudent, repo, "GET", "/students/get?id=nobody", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GetStudent of a missing ID = %d, want 404", w.Code)
+	}
+	if w := call(GetStudent, repo, "GET", "/students/get", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("GetStudent without an ID = %d, want 400", w.Code)
+	}
+}
+
+func TestGetStudentRedactsHealthRecords(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.Create("s1", map[string]interface{}{"full_name": "Ann", "health_records": []interface{}{"asthma"}})
+
+	w := call(GetStudent, repo, "GET", "/students/get?id=s1", "")
+	if strings.Contains(w.Body.String(), "health_records") {
+		t.Errorf("GetStudent leaked health records: %s", w.Body)
+	}
+}
+
+func TestCreateStudentDoesNotLogBody(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(io.Discard)
+
+	body := `{"id":"s1","full_name":"Ann Lee","health_records":[{"condition":"Asthma"}]}`
+	call(CreateStudent, NewMemoryRepository(), "POST", "/students", body)
+	call(CreateStudent, NewMemoryRepository(), "POST", "/students", `{"id":"s2","health_records":"Asthma"`)
+	if strings.Contains(logged.String(), "Asthma") || strings.Contains(logged.String(), "Ann Lee") {
+		t.Errorf("request details logged: %s", logged.String())
+	}
+}
+
+func TestUpdateStudentKeepsReportComments(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.Create("s1", map[string]interface{}{"full_name": "Ann", "report_comments": map[string]interface{}{"T1": "Good"}})
+
+	w := call(UpdateStudent, repo, "PUT", "/students/update", `{"id":"s1","full_name":"Ann Lee","admission_date":"2024-06-01"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("UpdateStudent = %d %s", w.Code, w.Body)
+	}
+	doc, _ := repo.Get("s1")
+	if doc["full_name"] != "Ann Lee" || doc["report_comments"] == nil {
+		t.Errorf("after update = %v", doc)
+	}
+
+	w = call(UpdateStudent, repo, "PUT", "/students/update", `{"id":"nobody"}`)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("UpdateStudent of a missing ID = %d, want 404", w.Code)
+	}
+}
+
+func TestDeleteStudent(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.Create("s1", map[string]interface{}{"full_name": "Ann"})
+
+	if w := call(DeleteStudent, repo, "DELETE", "/students/delete?id=s1", ""); w.Code != http.StatusOK {
+		t.Fatalf("DeleteStudent = %d %s", w.Code, w.Body)
+	}
+	if _, err := repo.Get("s1"); err == nil {
+		t.Errorf("student still stored after delete")
+	}
+	if w := call(DeleteStudent, repo, "DELETE", "/students/delete?id=s1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("deleting again = %d, want 404", w.Code)
+	}
+}
+
+// downRepository fails every query the way CouchDB does while its views
+// can't be built
+type downRepository struct{ Repository }
+
+func (downRepository) Find(store.Query) (store.Page, error) {
+	return store.Page{}, fmt.Errorf("%w: connection refused", store.ErrUnavailable)
+}
+
+func TestGetAllStudentsStatus(t *testing.T) {
+	repo := NewMemoryRepository()
+	if w := call(GetAllStudents, repo, "GET", "/students?cursor=bogus", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("bad cursor = %d, want 400", w.Code)
+	}
+	if w := call(GetAllStudents, downRepository{repo}, "GET", "/students", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unavailable index = %d, want 503", w.Code)
+	}
+}
@@ -0,0 +1,24 @@
+package support
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding support staff shift rosters. The
+// staff members themselves live in staff_db.
+const DBName = "roster_db"
+
+// Repository is the storage backend for roster documents
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the roster_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
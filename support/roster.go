@@ -0,0 +1,257 @@
+package support
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"data-access/auth"
+	"data-access/domain"
+	"data-access/staff"
+	"data-access/store"
+)
+
+// Shift is one block of work in a roster, e.g. breakfast 05:30-09:00.
+// Shifts end on the day they start.
+type Shift struct {
+	Name  string `json:"name"`
+	Start string `json:"start"` // "HH:MM"
+	End   string `json:"end"`
+}
+
+// Assignment puts a staff member on a shift on one date
+type Assignment struct {
+	Date    string `json:"date"`
+	Shift   string `json:"shift"`
+	StaffID string `json:"staff_id"`
+	Area    string `json:"area,omitempty"`
+}
+
+// Roster is a category's shift plan for the week starting WeekStart, a
+// Monday
+type Roster struct {
+	ID          string       `json:"id"`
+	Category    string       `json:"category"`
+	WeekStart   string       `json:"week_start"`
+	Shifts      []Shift      `json:"shifts"`
+	Assignments []Assignment `json:"assignments"`
+	UpdatedBy   string       `json:"updated_by,omitempty"`
+	UpdatedAt   string       `json:"updated_at,omitempty"`
+}
+
+// Conflict is an assignment that can't be worked
+type Conflict struct {
+	Date    string `json:"date"`
+	StaffID string `json:"staff_id"`
+	Reason  string `json:"reason"`
+}
+
+func rosterID(category, weekStart string) string {
+	return "roster:" + category + ":" + weekStart
+}
+
+// weekStart returns the Monday of the week containing the date s
+func weekStart(s string) (time.Time, error) {
+	d, err := time.Parse(domain.DateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("dates must be formatted as YYYY-MM-DD")
+	}
+	offset := (int(d.Weekday()) + 6) % 7
+	return d.AddDate(0, 0, -offset), nil
+}
+
+// validate checks a roster's shifts and assignments are well formed and
+// returns the shifts by name
+func (ro *Roster) validate() (map[string]Shift, error) {
+	monday, err := weekStart(ro.WeekStart)
+	if err != nil {
+		return nil, err
+	}
+	if ro.WeekStart != monday.Format(domain.DateLayout) {
+		return nil, fmt.Errorf("week_start must be a Monday")
+	}
+	sunday := monday.AddDate(0, 0, 6).Format(domain.DateLayout)
+
+	shifts := make(map[string]Shift)
+	for _, s := range ro.Shifts {
+		start, err1 := time.Parse("15:04", s.Start)
+		end, err2 := time.Parse("15:04", s.End)
+		if s.Name == "" || err1 != nil || err2 != nil || !end.After(start) {
+			return nil, fmt.Errorf("every shift needs a name and a start before its end, as HH:MM")
+		}
+		if _, dup := shifts[s.Name]; dup {
+			return nil, fmt.Errorf("shift %q is defined twice", s.Name)
+		}
+		shifts[s.Name] = s
+	}
+	for i, a := range ro.Assignments {
+		if a.Date < ro.WeekStart || a.Date > sunday {
+			return nil, fmt.Errorf("assignment %d: date is outside the week", i+1)
+		}
+		if _, ok := shifts[a.Shift]; !ok {
+			return nil, fmt.Errorf("assignment %d: unknown shift %q", i+1, a.Shift)
+		}
+		if a.StaffID == "" {
+			return nil, fmt.Errorf("assignment %d: staff_id missing", i+1)
+		}
+	}
+	sort.SliceStable(ro.Assignments, func(i, j int) bool {
+		a, b := ro.Assignments[i], ro.Assignments[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return shifts[a.Shift].Start < shifts[b.Shift].Start
+	})
+	return shifts, nil
+}
+
+// conflicts finds assignments of people who aren't active members of the
+// category, are off that day or are booked on overlapping shifts
+func (ro *Roster) conflicts(shifts map[string]Shift, members map[string]map[string]interface{}) []Conflict {
+	conflicts := []Conflict{}
+	booked := make(map[string][]Shift) // staff ID and date
+	for _, a := range ro.Assignments {
+		doc, ok := members[a.StaffID]
+		if !ok {
+			conflicts = append(conflicts, Conflict{a.Date, a.StaffID, "not a member of this team"})
+			continue
+		}
+		if status, _ := doc["employment_status"].(string); strings.EqualFold(status, "terminated") || strings.EqualFold(status, "resigned") {
+			conflicts = append(conflicts, Conflict{a.Date, a.StaffID, "no longer employed"})
+			continue
+		}
+		var s struct {
+			TimeOff []staff.TimeOff `json:"time_off"`
+		}
+		store.Decode(doc, &s)
+		for _, off := range s.TimeOff {
+			if off.Date.Format(domain.DateLayout) == a.Date {
+				conflicts = append(conflicts, Conflict{a.Date, a.StaffID, "has time off (" + off.Type + ")"})
+				break
+			}
+		}
+		shift := shifts[a.Shift]
+		key := a.StaffID + " " + a.Date
+		for _, other := range booked[key] {
+			if shift.Start < other.End && other.Start < shift.End {
+				conflicts = append(conflicts, Conflict{a.Date, a.StaffID, fmt.Sprintf("shifts %s and %s overlap", other.Name, shift.Name)})
+			}
+		}
+		booked[key] = append(booked[key], shift)
+	}
+	return conflicts
+}
+
+// SetRoster creates or replaces category c's roster for a week. Every
+// assignment must be to an active member of the team who is not off that
+// day and not already on an overlapping shift.
+func SetRoster(w http.ResponseWriter, r *http.Request, rosters Repository, staffs staff.Repository, c Category) {
+	var ro Roster
+	if err := json.NewDecoder(r.Body).Decode(&ro); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	shifts, err := ro.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	docs, err := c.Members(staffs)
+	if err != nil {
+		http.Error(w, "Failed to fetch staff members", http.StatusInternalServerError)
+		return
+	}
+	members := make(map[string]map[string]interface{}, len(docs))
+	for _, doc := range docs {
+		id, _ := doc["_id"].(string)
+		members[id] = doc
+	}
+	if conflicts := ro.conflicts(shifts, members); len(conflicts) > 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":     "roster has conflicts",
+			"conflicts": conflicts,
+		})
+		return
+	}
+
+	caller, _ := auth.FromContext(r.Context())
+	ro.ID = rosterID(c.Name, ro.WeekStart)
+	ro.Category = c.Name
+	ro.UpdatedBy = caller.Email
+	ro.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	doc := map[string]interface{}{
+		"type":        "roster",
+		"category":    ro.Category,
+		"week_start":  ro.WeekStart,
+		"shifts":      ro.Shifts,
+		"assignments": ro.Assignments,
+		"updated_by":  ro.UpdatedBy,
+		"updated_at":  ro.UpdatedAt,
+	}
+	existing, err := rosters.Get(ro.ID)
+	switch err {
+	case nil:
+		_, err = rosters.Update(ro.ID, doc, store.Rev(existing))
+	case store.ErrNotFound:
+		_, err = rosters.Create(ro.ID, doc)
+	}
+	if err == store.ErrConflict {
+		http.Error(w, "roster was modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save roster", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Roster saved successfully",
+		"roster":  ro,
+	})
+}
+
+// GetRoster returns category c's roster for the week containing ?week=..
+// (default this week). With ?id=.. only that staff member's shifts are
+// listed.
+func GetRoster(w http.ResponseWriter, r *http.Request, rosters Repository, c Category) {
+	week := r.URL.Query().Get("week")
+	if week == "" {
+		week = time.Now().Format(domain.DateLayout)
+	}
+	monday, err := weekStart(week)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := rosters.Get(rosterID(c.Name, monday.Format(domain.DateLayout)))
+	if err != nil {
+		http.Error(w, "Roster not found", http.StatusNotFound)
+		return
+	}
+	var ro Roster
+	if err := store.Decode(doc, &ro); err != nil {
+		http.Error(w, "failed to read roster", http.StatusInternalServerError)
+		return
+	}
+	ro.ID, _ = doc["_id"].(string)
+
+	if staffID := r.URL.Query().Get("id"); staffID != "" {
+		mine := []Assignment{}
+		for _, a := range ro.Assignments {
+			if a.StaffID == staffID {
+				mine = append(mine, a)
+			}
+		}
+		ro.Assignments = mine
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ro)
+}
@@ -0,0 +1,294 @@
+// Package support manages the school's support staff, cooks and cleaners,
+// through typed endpoints, and their weekly shift rosters. Support staff
+// are ordinary staff_db documents with a category, so check-ins, ID cards
+// and payroll treat them like any other staff member.
+package support
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"data-access/auth"
+	"data-access/domain"
+	"data-access/staff"
+	"data-access/store"
+)
+
+// Category is a group of support staff with its own endpoints and roster
+type Category struct {
+	Name       string // stored in the document's "category" field
+	Label      string // used in messages, e.g. "Cook"
+	Department string // given to new members who don't name one
+	new        func() Member
+}
+
+// Support staff categories
+var (
+	Cooks = Category{
+		Name: "cook", Label: "Cook", Department: "Kitchen",
+		new: func() Member { return &CookStaff{} },
+	}
+	Cleaners = Category{
+		Name: "cleaning", Label: "Cleaner", Department: "Housekeeping",
+		new: func() Member { return &CleaningStaff{} },
+	}
+)
+
+// EmploymentRecord is a job held before joining the school
+type EmploymentRecord struct {
+	Employer  string           `json:"employer"`
+	JobTitle  string           `json:"job_title"`
+	StartDate staff.CustomTime `json:"start_date"`
+	EndDate   staff.CustomTime `json:"end_date"`
+}
+
+// SupportStaff is what cooks and cleaners have on top of the general staff
+// record
+type SupportStaff struct {
+	staff.SchoolStaff
+	Qualifications     []string           `json:"qualifications"`
+	PreviousEmployment []EmploymentRecord `json:"previous_employment"`
+}
+
+// CookStaff is a member of the kitchen team
+type CookStaff struct {
+	SupportStaff
+	Station string `json:"station"` // e.g. "bakery", "grill"
+	// FoodSafetyExpiry is when the cook's food handling certificate lapses
+	FoodSafetyExpiry staff.CustomTime `json:"food_safety_expiry"`
+}
+
+// CleaningStaff is a member of the housekeeping team
+type CleaningStaff struct {
+	SupportStaff
+	Areas []string `json:"areas"` // the blocks or rooms they look after
+}
+
+// Member is a support staff record of any category
+type Member interface {
+	support() *SupportStaff
+	// fields returns the category's own document fields
+	fields() map[string]interface{}
+}
+
+func (s *SupportStaff) support() *SupportStaff { return s }
+
+func (c *CookStaff) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"station":            c.Station,
+		"food_safety_expiry": c.FoodSafetyExpiry.Format(domain.DateLayout),
+	}
+}
+
+func (c *CleaningStaff) fields() map[string]interface{} {
+	return map[string]interface{}{"areas": c.Areas}
+}
+
+// payFields are the parts of a staff record only admins may see or change.
+// Other staff can manage their team's records without them; on update the
+// stored values are kept.
+var payFields = []string{"salary", "payroll_info", "time_off"}
+
+// seesPay reports whether the caller may read and write payFields
+func seesPay(r *http.Request) bool {
+	caller, _ := auth.FromContext(r.Context())
+	return caller.Role == auth.Admin
+}
+
+func redactPay(doc map[string]interface{}) {
+	for _, field := range payFields {
+		delete(doc, field)
+	}
+}
+
+// document builds the stored document of a member of category c, leaving
+// out payFields unless withPay is set
+func (c Category) document(m Member, withPay bool) map[string]interface{} {
+	s := m.support()
+	if s.Department == "" {
+		s.Department = c.Department
+	}
+	if s.JobTitle == "" {
+		s.JobTitle = c.Label
+	}
+	doc := s.Document()
+	doc["category"] = c.Name
+	doc["qualifications"] = s.Qualifications
+	doc["previous_employment"] = s.PreviousEmployment
+	for key, value := range m.fields() {
+		doc[key] = value
+	}
+	if !withPay {
+		redactPay(doc)
+	}
+	return doc
+}
+
+// load fetches a staff document, reporting ErrNotFound unless it belongs to
+// the category
+func (c Category) load(repo staff.Repository, id string) (map[string]interface{}, error) {
+	doc, err := repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if doc["category"] != c.Name {
+		return nil, store.ErrNotFound
+	}
+	return doc, nil
+}
+
+// Members returns every staff document of the category
+func (c Category) Members(repo staff.Repository) ([]map[string]interface{}, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	members := []map[string]interface{}{}
+	for _, doc := range docs {
+		if doc["category"] == c.Name {
+			delete(doc, "_rev")
+			members = append(members, doc)
+		}
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		a, _ := members[i]["full_name"].(string)
+		b, _ := members[j]["full_name"].(string)
+		return a < b
+	})
+	return members, nil
+}
+
+// CreateMember adds a support staff member of category c. Pay details are
+// only stored when an admin creates the record.
+func CreateMember(w http.ResponseWriter, r *http.Request, repo staff.Repository, c Category) {
+	m := c.new()
+	if err := json.NewDecoder(r.Body).Decode(m); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	id := m.support().ID
+	if id == "" {
+		http.Error(w, "Staff ID missing", http.StatusBadRequest)
+		return
+	}
+
+	_, err := repo.Create(id, c.document(m, seesPay(r)))
+	if err == store.ErrConflict {
+		http.Error(w, "Staff ID already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to create staff", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": c.Label + " created successfully"})
+}
+
+// GetMember returns one member of category c (?id=..)
+func GetMember(w http.ResponseWriter, r *http.Request, repo staff.Repository, c Category) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Staff ID missing", http.StatusBadRequest)
+		return
+	}
+	doc, err := c.load(repo, id)
+	if err != nil {
+		http.Error(w, c.Label+" not found", http.StatusNotFound)
+		return
+	}
+	if !seesPay(r) {
+		redactPay(doc)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// GetMembers lists category c's members by name, optionally only those
+// with one employment_status (?employment_status=..)
+func GetMembers(w http.ResponseWriter, r *http.Request, repo staff.Repository, c Category) {
+	members, err := c.Members(repo)
+	if err != nil {
+		http.Error(w, "Failed to fetch staff members", http.StatusInternalServerError)
+		return
+	}
+	if status := r.URL.Query().Get("employment_status"); status != "" {
+		matching := []map[string]interface{}{}
+		for _, m := range members {
+			if m["employment_status"] == status {
+				matching = append(matching, m)
+			}
+		}
+		members = matching
+	}
+	if !seesPay(r) {
+		for _, m := range members {
+			redactPay(m)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(members)
+}
+
+// UpdateMember replaces a member's details. Attendance, anything else this
+// endpoint doesn't manage and, for callers other than admins, pay details
+// are carried over.
+func UpdateMember(w http.ResponseWriter, r *http.Request, repo staff.Repository, c Category) {
+	m := c.new()
+	if err := json.NewDecoder(r.Body).Decode(m); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	id := m.support().ID
+
+	existingDoc, err := c.load(repo, id)
+	if err != nil {
+		http.Error(w, c.Label+" not found", http.StatusNotFound)
+		return
+	}
+	doc := c.document(m, seesPay(r))
+	for key, value := range existingDoc {
+		if _, ok := doc[key]; !ok && key != "_id" && key != "_rev" {
+			doc[key] = value
+		}
+	}
+
+	_, err = repo.Update(id, doc, store.Rev(existingDoc))
+	if err == store.ErrConflict {
+		http.Error(w, "staff member was modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update staff member", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": c.Label + " updated successfully"})
+}
+
+// DeleteMember removes a member of category c (?id=..)
+func DeleteMember(w http.ResponseWriter, r *http.Request, repo staff.Repository, c Category) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Staff ID missing", http.StatusBadRequest)
+		return
+	}
+	existingDoc, err := c.load(repo, id)
+	if err != nil {
+		http.Error(w, c.Label+" not found", http.StatusNotFound)
+		return
+	}
+
+	if err := repo.Delete(id, store.Rev(existingDoc)); err != nil {
+		http.Error(w, "failed to delete staff member", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": c.Label + " deleted successfully"})
+}
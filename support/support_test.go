@@ -0,0 +1,63 @@
+package support
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"data-access/auth"
+	"data-access/staff"
+)
+
+func as(role auth.Role, method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	return r.WithContext(auth.NewContext(r.Context(), auth.Identity{ID: "caller", Role: role}))
+}
+
+func TestPayDetailsAreAdminOnly(t *testing.T) {
+	repo := staff.NewMemoryRepository()
+	cook := `{"id":"c1","full_name":"Meena","start_date":"2024-01-01","salary":240000,"payroll_info":{"direct_deposit":"SBIN0000001:1234"},"station":"grill"}`
+
+	w := httptest.NewRecorder()
+	CreateMember(w, as(auth.Admin, "POST", "/cooks", cook), repo, Cooks)
+	if w.Code != http.StatusOK {
+		t.Fatalf("CreateMember = %d %s", w.Code, w.Body)
+	}
+
+	// A staff member updating the record can't change the salary
+	w = httptest.NewRecorder()
+	update := strings.Replace(strings.Replace(cook, "240000", "999999", 1), "grill", "bakery", 1)
+	UpdateMember(w, as(auth.Staff, "PUT", "/cooks/update", update), repo, Cooks)
+	if w.Code != http.StatusOK {
+		t.Fatalf("UpdateMember = %d %s", w.Code, w.Body)
+	}
+	doc, _ := repo.Get("c1")
+	if doc["salary"] != float64(240000) || doc["payroll_info"] == nil || doc["station"] != "bakery" {
+		t.Errorf("after staff update = %v", doc)
+	}
+
+	get := func(role auth.Role, handler func(http.ResponseWriter, *http.Request, staff.Repository, Category), target string) string {
+		w := httptest.NewRecorder()
+		handler(w, as(role, "GET", target, ""), repo, Cooks)
+		return w.Body.String()
+	}
+	for _, body := range []string{get(auth.Staff, GetMember, "/cooks/get?id=c1"), get(auth.Staff, GetMembers, "/cooks")} {
+		if strings.Contains(body, "salary") || strings.Contains(body, "payroll_info") || !strings.Contains(body, "Meena") {
+			t.Errorf("staff view = %s", body)
+		}
+	}
+	var member map[string]interface{}
+	json.Unmarshal([]byte(get(auth.Admin, GetMember, "/cooks/get?id=c1")), &member)
+	if member["salary"] != float64(240000) {
+		t.Errorf("admin view = %v", member)
+	}
+
+	// Records created by other staff start without pay details
+	w = httptest.NewRecorder()
+	CreateMember(w, as(auth.Staff, "POST", "/cooks", strings.Replace(cook, "c1", "c2", 1)), repo, Cooks)
+	if doc, _ := repo.Get("c2"); w.Code != http.StatusOK || doc["salary"] != nil {
+		t.Errorf("staff create = %d, stored %v", w.Code, doc)
+	}
+}
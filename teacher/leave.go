@@ -0,0 +1,447 @@
+package teacher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"data-access/auth"
+	"data-access/store"
+)
+
+// Leave statuses. A request starts pending and is then approved, rejected
+// or cancelled; those three are final.
+const (
+	LeavePending   = "pending"
+	LeaveApproved  = "approved"
+	LeaveRejected  = "rejected"
+	LeaveCancelled = "cancelled"
+)
+
+// LeaveAllowances is the number of leave days per calendar year for each
+// leave type. A zero allowance means the type is not capped (unpaid leave).
+var LeaveAllowances = map[string]int{
+	"casual": 12,
+	"sick":   10,
+	"earned": 15,
+	"unpaid": 0,
+}
+
+var (
+	errLeaveNotFound   = errors.New("leave request not found")
+	errLeaveTransition = errors.New("leave request is no longer pending")
+	errLeaveOverlap    = errors.New("leave overlaps an existing pending or approved request")
+	errLeaveBalance    = errors.New("not enough leave balance for this request")
+)
+
+// LeaveBalance is a teacher's use of one leave type in a year. Remaining is
+// left out for types without an allowance.
+type LeaveBalance struct {
+	Type      string `json:"type"`
+	Allowance int    `json:"allowance"`
+	Approved  int    `json:"approved"`
+	Pending   int    `json:"pending"`
+	Remaining *int   `json:"remaining,omitempty"`
+}
+
+// canTransition reports whether a leave request may move from one status
+// to another
+func canTransition(from, to string) bool {
+	if strings.ToLower(from) != LeavePending {
+		return false
+	}
+	switch to {
+	case LeaveApproved, LeaveRejected, LeaveCancelled:
+		return true
+	}
+	return false
+}
+
+// active reports whether a leave request blocks the days it covers
+func (l LeaveRecord) active() bool {
+	status := strings.ToLower(l.Status)
+	return status == LeavePending || status == LeaveApproved
+}
+
+// Covers reports whether the leave includes the given day
+func (l LeaveRecord) Covers(day time.Time) bool {
+	d := day.Format(ctLayout)
+	return d >= l.StartDate.Format(ctLayout) && d <= l.EndDate.Format(ctLayout)
+}
+
+func (l LeaveRecord) overlaps(other LeaveRecord) bool {
+	return l.StartDate.Format(ctLayout) <= other.EndDate.Format(ctLayout) &&
+		other.StartDate.Format(ctLayout) <= l.EndDate.Format(ctLayout)
+}
+
+// daysByYear counts the working days (Monday to Saturday) of the leave in
+// each calendar year it touches
+func (l LeaveRecord) daysByYear() map[int]int {
+	days := make(map[int]int)
+	for d := l.StartDate.Time; !d.After(l.EndDate.Time); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Sunday {
+			days[d.Year()]++
+		}
+	}
+	return days
+}
+
+// LeaveRecords decodes the leave list stored on a teacher document
+func LeaveRecords(doc map[string]interface{}) ([]LeaveRecord, error) {
+	var teacher struct {
+		LeaveRecords []LeaveRecord `json:"leave_records"`
+	}
+	if err := store.Decode(doc, &teacher); err != nil {
+		return nil, err
+	}
+	return teacher.LeaveRecords, nil
+}
+
+// Balances works out each leave type's allowance and use in a year
+func Balances(records []LeaveRecord, year int) []LeaveBalance {
+	var balances []LeaveBalance
+	for leaveType, allowance := range LeaveAllowances {
+		b := LeaveBalance{Type: leaveType, Allowance: allowance}
+		for _, rec := range records {
+			if rec.Type != leaveType {
+				continue
+			}
+			switch strings.ToLower(rec.Status) {
+			case LeaveApproved:
+				b.Approved += rec.daysByYear()[year]
+			case LeavePending:
+				b.Pending += rec.daysByYear()[year]
+			}
+		}
+		if allowance > 0 {
+			remaining := allowance - b.Approved - b.Pending
+			b.Remaining = &remaining
+		}
+		balances = append(balances, b)
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Type < balances[j].Type })
+	return balances
+}
+
+// updateLeave applies change to a teacher's leave records and saves them
+func updateLeave(repo Repository, teacherID string, change func([]LeaveRecord) ([]LeaveRecord, error)) error {
+	_, err := store.Modify(repo, teacherID, func(doc map[string]interface{}) error {
+		records, err := LeaveRecords(doc)
+		if err != nil {
+			return err
+		}
+		if records, err = change(records); err != nil {
+			return err
+		}
+		doc["leave_records"] = records
+		return nil
+	})
+	return err
+}
+
+// writeLeaveError reports a failed leave update
+func writeLeaveError(w http.ResponseWriter, err error) {
+	switch err {
+	case store.ErrNotFound:
+		http.Error(w, "Teacher not found", http.StatusNotFound)
+	case errLeaveNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errLeaveTransition, errLeaveOverlap:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errLeaveBalance:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to update leave", http.StatusInternalServerError)
+	}
+}
+
+func newLeaveID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "leave-" + hex.EncodeToString(b)
+}
+
+// RequestLeave submits a pending leave request for the teacher in ?id=..
+func RequestLeave(w http.ResponseWriter, r *http.Request, repo Repository) {
+	teacherID := r.URL.Query().Get("id")
+	if teacherID == "" {
+		http.Error(w, "Teacher ID missing", http.StatusBadRequest)
+		return
+	}
+	var request struct {
+		Type      string `json:"type"`
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	leaveType := strings.ToLower(request.Type)
+	allowance, ok := LeaveAllowances[leaveType]
+	if !ok {
+		http.Error(w, "unknown leave type", http.StatusBadRequest)
+		return
+	}
+	start, err1 := time.Parse(ctLayout, request.StartDate)
+	end, err2 := time.Parse(ctLayout, request.EndDate)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "start_date and end_date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if end.Before(start) {
+		http.Error(w, "end_date is before start_date", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	leave := LeaveRecord{
+		ID:          newLeaveID(),
+		Type:        leaveType,
+		StartDate:   CustomTime{Time: start},
+		EndDate:     CustomTime{Time: end},
+		Reason:      request.Reason,
+		Status:      LeavePending,
+		RequestedAt: &now,
+	}
+	err := updateLeave(repo, teacherID, func(records []LeaveRecord) ([]LeaveRecord, error) {
+		for _, rec := range records {
+			if rec.active() && rec.overlaps(leave) {
+				return nil, errLeaveOverlap
+			}
+		}
+		if allowance > 0 {
+			for year, days := range leave.daysByYear() {
+				for _, b := range Balances(records, year) {
+					if b.Type == leaveType && days > *b.Remaining {
+						return nil, errLeaveBalance
+					}
+				}
+			}
+		}
+		return append(records, leave), nil
+	})
+	if err != nil {
+		writeLeaveError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Leave requested successfully",
+		"leave":   leave,
+	})
+}
+
+// setLeaveStatus moves one leave request to a new status
+func setLeaveStatus(repo Repository, teacherID, leaveID, status string, review func(*LeaveRecord)) (LeaveRecord, error) {
+	var updated LeaveRecord
+	err := updateLeave(repo, teacherID, func(records []LeaveRecord) ([]LeaveRecord, error) {
+		for i := range records {
+			if records[i].ID != leaveID {
+				continue
+			}
+			if !canTransition(records[i].Status, status) {
+				return nil, errLeaveTransition
+			}
+			records[i].Status = status
+			if review != nil {
+				review(&records[i])
+			}
+			updated = records[i]
+			return records, nil
+		}
+		return nil, errLeaveNotFound
+	})
+	return updated, err
+}
+
+// ReviewLeave approves or rejects a pending leave request
+func ReviewLeave(w http.ResponseWriter, r *http.Request, repo Repository) {
+	var request struct {
+		TeacherID string `json:"teacher_id"`
+		LeaveID   string `json:"leave_id"`
+		Decision  string `json:"decision"`
+		Comment   string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	decision := strings.ToLower(request.Decision)
+	if decision != LeaveApproved && decision != LeaveRejected {
+		http.Error(w, "decision must be approved or rejected", http.StatusBadRequest)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+
+	leave, err := setLeaveStatus(repo, request.TeacherID, request.LeaveID, decision, func(l *LeaveRecord) {
+		now := time.Now()
+		l.ReviewedBy = caller.Email
+		l.ReviewComment = request.Comment
+		l.ReviewedAt = &now
+	})
+	if err != nil {
+		writeLeaveError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Leave " + decision,
+		"leave":   leave,
+	})
+}
+
+// CancelLeave withdraws a pending leave request of the teacher in ?id=..
+func CancelLeave(w http.ResponseWriter, r *http.Request, repo Repository) {
+	teacherID := r.URL.Query().Get("id")
+	var request struct {
+		LeaveID string `json:"leave_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if teacherID == "" || request.LeaveID == "" {
+		http.Error(w, "Teacher ID and leave_id are required", http.StatusBadRequest)
+		return
+	}
+
+	leave, err := setLeaveStatus(repo, teacherID, request.LeaveID, LeaveCancelled, nil)
+	if err != nil {
+		writeLeaveError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Leave cancelled",
+		"leave":   leave,
+	})
+}
+
+// GetLeave lists a teacher's leave requests with their balances for a year
+// (?id=..&year=.., default this year)
+func GetLeave(w http.ResponseWriter, r *http.Request, repo Repository) {
+	teacherID := r.URL.Query().Get("id")
+	if teacherID == "" {
+		http.Error(w, "Teacher ID missing", http.StatusBadRequest)
+		return
+	}
+	year := time.Now().Year()
+	if s := r.URL.Query().Get("year"); s != "" {
+		t, err := time.Parse("2006", s)
+		if err != nil {
+			http.Error(w, "year must be a four-digit year", http.StatusBadRequest)
+			return
+		}
+		year = t.Year()
+	}
+
+	doc, err := repo.Get(teacherID)
+	if err != nil {
+		http.Error(w, "Teacher not found", http.StatusNotFound)
+		return
+	}
+	records, err := LeaveRecords(doc)
+	if err != nil {
+		http.Error(w, "failed to read leave records", http.StatusInternalServerError)
+		return
+	}
+	if records == nil {
+		records = []LeaveRecord{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"teacher_id": teacherID,
+		"year":       year,
+		"leave":      records,
+		"balances":   Balances(records, year),
+	})
+}
+
+// Absence is a teacher out on approved (or, if asked for, pending) leave
+type Absence struct {
+	TeacherID  string      `json:"teacher_id"`
+	FullName   string      `json:"full_name"`
+	Department string      `json:"department"`
+	Leave      LeaveRecord `json:"leave"`
+}
+
+// Absences returns the teachers on leave on day
+func Absences(repo Repository, day time.Time, includePending bool) ([]Absence, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	absences := []Absence{}
+	for _, doc := range docs {
+		records, err := LeaveRecords(doc)
+		if err != nil {
+			continue
+		}
+		for _, rec := range records {
+			status := strings.ToLower(rec.Status)
+			if !rec.Covers(day) || !(status == LeaveApproved || (includePending && status == LeavePending)) {
+				continue
+			}
+			a := Absence{Leave: rec}
+			a.TeacherID, _ = doc["_id"].(string)
+			a.FullName, _ = doc["full_name"].(string)
+			a.Department, _ = doc["department"].(string)
+			absences = append(absences, a)
+		}
+	}
+	sort.Slice(absences, func(i, j int) bool { return absences[i].FullName < absences[j].FullName })
+	return absences, nil
+}
+
+// GetLeaveCalendar lists who is out on each day of a range
+// (?from=..&to=.., default today; include_pending=true adds pending leave)
+func GetLeaveCalendar(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query := r.URL.Query()
+	today := time.Now().Format(ctLayout)
+	fromStr, toStr := query.Get("from"), query.Get("to")
+	if fromStr == "" {
+		fromStr = today
+	}
+	if toStr == "" {
+		toStr = fromStr
+	}
+	from, err1 := time.Parse(ctLayout, fromStr)
+	to, err2 := time.Parse(ctLayout, toStr)
+	if err1 != nil || err2 != nil || to.Before(from) {
+		http.Error(w, "from and to must be formatted as YYYY-MM-DD, from first", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > 62*24*time.Hour {
+		http.Error(w, "calendar range is limited to two months", http.StatusBadRequest)
+		return
+	}
+	includePending := query.Get("include_pending") == "true"
+
+	type day struct {
+		Date string    `json:"date"`
+		Out  []Absence `json:"out"`
+	}
+	calendar := []day{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		out, err := Absences(repo, d, includePending)
+		if err != nil {
+			http.Error(w, "Failed to fetch teachers", http.StatusInternalServerError)
+			return
+		}
+		calendar = append(calendar, day{Date: d.Format(ctLayout), Out: out})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(calendar)
+}
@@ -0,0 +1,117 @@
+package teacher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) CustomTime {
+	t, _ := time.Parse(ctLayout, s)
+	return CustomTime{Time: t}
+}
+
+func balanceOf(balances []LeaveBalance, leaveType string) LeaveBalance {
+	for _, b := range balances {
+		if b.Type == leaveType {
+			return b
+		}
+	}
+	return LeaveBalance{}
+}
+
+func TestBalances(t *testing.T) {
+	records := []LeaveRecord{
+		// Monday to Sunday: six working days
+		{Type: "casual", Status: LeaveApproved, StartDate: day("2024-01-01"), EndDate: day("2024-01-07")},
+		{Type: "casual", Status: LeavePending, StartDate: day("2024-02-05"), EndDate: day("2024-02-06")},
+		{Type: "casual", Status: LeaveRejected, StartDate: day("2024-03-04"), EndDate: day("2024-03-08")},
+		{Type: "casual", Status: LeaveCancelled, StartDate: day("2024-04-01"), EndDate: day("2024-04-02")},
+		// Spans new year: two days in 2024, two in 2025
+		{Type: "sick", Status: "Approved", StartDate: day("2024-12-30"), EndDate: day("2025-01-02")},
+		{Type: "unpaid", Status: LeaveApproved, StartDate: day("2024-05-06"), EndDate: day("2024-05-06")},
+	}
+
+	balances := Balances(records, 2024)
+	if len(balances) != len(LeaveAllowances) {
+		t.Fatalf("got %d balances, want one per leave type", len(balances))
+	}
+	casual := balanceOf(balances, "casual")
+	if casual.Approved != 6 || casual.Pending != 2 || casual.Remaining == nil || *casual.Remaining != 4 {
+		t.Errorf("casual = %+v, want 6 approved, 2 pending, 4 remaining", casual)
+	}
+	if sick := balanceOf(balances, "sick"); sick.Approved != 2 || *sick.Remaining != 8 {
+		t.Errorf("sick 2024 = %+v, want 2 approved", sick)
+	}
+	if sick := balanceOf(Balances(records, 2025), "sick"); sick.Approved != 2 {
+		t.Errorf("sick 2025 = %+v, want 2 approved", sick)
+	}
+	if unpaid := balanceOf(balances, "unpaid"); unpaid.Approved != 1 || unpaid.Remaining != nil {
+		t.Errorf("unpaid = %+v, want 1 approved and no remaining", unpaid)
+	}
+}
+
+func leaveRequest(handler func(http.ResponseWriter, *http.Request, Repository), repo Repository, target, body string) (int, LeaveRecord) {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", target, strings.NewReader(body)), repo)
+	var result struct {
+		Leave LeaveRecord `json:"leave"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	return w.Code, result.Leave
+}
+
+func TestRequestLeave(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.Create("t1", map[string]interface{}{"full_name": "Ravi"})
+
+	// Two weeks of casual leave is 12 working days, the whole allowance
+	code, first := leaveRequest(RequestLeave, repo, "/teachers/leave/request?id=t1", `{"type":"casual","start_date":"2024-01-01","end_date":"2024-01-14"}`)
+	if code != http.StatusOK || first.Status != LeavePending || first.ID == "" {
+		t.Fatalf("first request = %d %+v", code, first)
+	}
+	if code, _ := leaveRequest(RequestLeave, repo, "/teachers/leave/request?id=t1", `{"type":"casual","start_date":"2024-03-01","end_date":"2024-03-01"}`); code != http.StatusBadRequest {
+		t.Errorf("request beyond the allowance = %d, want 400", code)
+	}
+	if code, _ := leaveRequest(RequestLeave, repo, "/teachers/leave/request?id=t1", `{"type":"sick","start_date":"2024-01-10","end_date":"2024-01-11"}`); code != http.StatusConflict {
+		t.Errorf("overlapping request = %d, want 409", code)
+	}
+
+	// Cancelling frees both the days and the balance
+	body := `{"leave_id":"` + first.ID + `"}`
+	if code, l := leaveRequest(CancelLeave, repo, "/teachers/leave/cancel?id=t1", body); code != http.StatusOK || l.Status != LeaveCancelled {
+		t.Fatalf("cancel = %d %+v", code, l)
+	}
+	if code, _ := leaveRequest(CancelLeave, repo, "/teachers/leave/cancel?id=t1", body); code != http.StatusConflict {
+		t.Errorf("cancelling twice = %d, want 409", code)
+	}
+	if code, _ := leaveRequest(RequestLeave, repo, "/teachers/leave/request?id=t1", `{"type":"casual","start_date":"2024-01-10","end_date":"2024-01-11"}`); code != http.StatusOK {
+		t.Errorf("request after cancelling = %d, want 200", code)
+	}
+
+	if code, _ := leaveRequest(RequestLeave, repo, "/teachers/leave/request?id=nobody", `{"type":"sick","start_date":"2024-01-10","end_date":"2024-01-11"}`); code != http.StatusNotFound {
+		t.Errorf("request for a missing teacher = %d, want 404", code)
+	}
+}
+
+func TestReviewLeave(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.Create("t1", map[string]interface{}{"full_name": "Ravi"})
+	_, leave := leaveRequest(RequestLeave, repo, "/teachers/leave/request?id=t1", `{"type":"sick","start_date":"2024-01-10","end_date":"2024-01-11"}`)
+
+	body := `{"teacher_id":"t1","leave_id":"` + leave.ID + `","decision":"approved"}`
+	if code, l := leaveRequest(ReviewLeave, repo, "/teachers/leave/review", body); code != http.StatusOK || l.Status != LeaveApproved || l.ReviewedAt == nil {
+		t.Fatalf("approve = %d %+v", code, l)
+	}
+	if code, _ := leaveRequest(ReviewLeave, repo, "/teachers/leave/review", strings.Replace(body, "approved", "rejected", 1)); code != http.StatusConflict {
+		t.Errorf("rejecting approved leave = %d, want 409", code)
+	}
+
+	absent, err := Absences(repo, day("2024-01-11").Time, false)
+	if err != nil || len(absent) != 1 || absent[0].TeacherID != "t1" {
+		t.Errorf("Absences = %v, %v", absent, err)
+	}
+}
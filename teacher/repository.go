@@ -0,0 +1,39 @@
+package teacher
+
+import (
+	"log"
+
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding teacher documents
+const DBName = "teacher_db"
+
+// Kind identifies teacher documents in QR tokens and check-ins
+const Kind = "teacher"
+
+// Repository is the storage backend for teacher documents
+type Repository = store.Repository
+
+// Index lists the fields GetAllTeachers can filter and sort by
+var Index = store.Index{
+	Filters: []string{"department", "gender", "email_address"},
+	Sorts:   []string{"full_name", "department", "joining_date"},
+}
+
+// NewCouchRepository returns a Repository backed by the teacher_db database,
+// creating the views GetAllTeachers pages through
+func NewCouchRepository(client *couchdb.Client) Repository {
+	db := store.NewCouchDB(client, DBName)
+	if err := db.EnsureIndex(Index); err != nil {
+		log.Printf("Failed to create %s query views: %v", DBName, err)
+	}
+	return db
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
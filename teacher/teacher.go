@@ -3,41 +3,31 @@ package teacher
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 
-	"github.com/fjl/go-couchdb"
+	"data-access/domain"
+	"data-access/qrtoken"
+	"data-access/store"
+
 	"github.com/skip2/go-qrcode"
 )
 
-type CustomTime struct {
-	time.Time
-}
+// CustomTime is a "YYYY-MM-DD" date
+type CustomTime = domain.Date
 
-const ctLayout = "2006-01-02"
+const ctLayout = domain.DateLayout
 
-func (ct *CustomTime) UnmarshalJSON(b []byte) (err error) {
-	s := strings.Trim(string(b), "\"")
-	if s == "null" {
-		ct.Time = time.Time{}
-		return
-	}
-	ct.Time, err = time.Parse(ctLayout, s)
-	return
-}
+// PayrollInfo is the account a teacher is paid into and the percentages
+// of pay withheld, by name
+type PayrollInfo = domain.PayrollInfo
 
 type Teacher struct {
-	ID             string          `json:"id"`
-	FullName       string          `json:"full_name"`
-	DateOfBirth    CustomTime      `json:"date_of_birth"`
-	Gender         string          `json:"gender"`
-	Address        string          `json:"address"`
-	ContactNumber  string          `json:"contact_number"`
-	EmailAddress   string          `json:"email_address"`
+	domain.Person
 	Department     string          `json:"department"`
 	SubjectsTaught []string        `json:"subjects_taught"`
 	Qualification  []Qualification `json:"qualification"`
@@ -45,6 +35,7 @@ type Teacher struct {
 	JoiningDate    CustomTime      `json:"joining_date"`
 	PreviousSchool string          `json:"previous_school"`
 	Salary         float64         `json:"salary"`
+	PayrollInfo    PayrollInfo     `json:"payroll_info"`
 	LeaveRecords   []LeaveRecord   `json:"leave_records"`
 }
 
@@ -55,14 +46,22 @@ type Qualification struct {
 	Institute string `json:"institute"`
 }
 
+// LeaveRecord is one leave request. Records created before the leave
+// workflow have no ID or type.
 type LeaveRecord struct {
-	StartDate CustomTime `json:"start_date"`
-	EndDate   CustomTime `json:"end_date"`
-	Reason    string     `json:"reason"`
-	Status    string     `json:"status"`
+	ID            string     `json:"id,omitempty"`
+	Type          string     `json:"type,omitempty"`
+	StartDate     CustomTime `json:"start_date"`
+	EndDate       CustomTime `json:"end_date"`
+	Reason        string     `json:"reason"`
+	Status        string     `json:"status"`
+	RequestedAt   *time.Time `json:"requested_at,omitempty"`
+	ReviewedBy    string     `json:"reviewed_by,omitempty"`
+	ReviewComment string     `json:"review_comment,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
 }
 
-func CreateTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func CreateTeacher(w http.ResponseWriter, r *http.Request, repo Repository) {
 	var teacher Teacher
 
 	log.Println("Received request to create teacher")
@@ -74,31 +73,13 @@ func CreateTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		return
 	}
 
-	log.Printf("Raw request body: %s", string(body))
-
 	if err := json.Unmarshal(body, &teacher); err != nil {
 		log.Printf("Error decoding request body: %v", err)
 		http.Error(w, "invalid request payload", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Decoded Teacher: %+v", teacher)
-
-	var existingDoc map[string]interface{}
-	err = client.DB("teacher_db").Get(teacher.ID, &existingDoc, couchdb.Options{})
-	if err == nil {
-		http.Error(w, "Teacher ID already exists", http.StatusConflict)
-		return
-	}
-
-	doc := map[string]interface{}{
-		"_id":             teacher.ID,
-		"full_name":       teacher.FullName,
-		"date_of_birth":   teacher.DateOfBirth.Format(ctLayout),
-		"gender":          teacher.Gender,
-		"address":         teacher.Address,
-		"contact_number":  teacher.ContactNumber,
-		"email_address":   teacher.EmailAddress,
+	doc := teacher.Person.Document(map[string]interface{}{
 		"department":      teacher.Department,
 		"subjects_taught": teacher.SubjectsTaught,
 		"qualification":   teacher.Qualification,
@@ -106,10 +87,15 @@ func CreateTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		"joining_date":    teacher.JoiningDate.Format(ctLayout),
 		"previous_school": teacher.PreviousSchool,
 		"salary":          teacher.Salary,
+		"payroll_info":    teacher.PayrollInfo,
 		"leave_records":   teacher.LeaveRecords,
-	}
+	})
 
-	_, err = client.DB("teacher_db").Put(teacher.ID, doc, "")
+	_, err = repo.Create(teacher.ID, doc)
+	if err == store.ErrConflict {
+		http.Error(w, "Teacher ID already exists", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to create teacher", http.StatusInternalServerError)
 		return
@@ -119,7 +105,7 @@ func CreateTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 	json.NewEncoder(w).Encode(map[string]string{"message": "Teacher created successfully"})
 }
 
-func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, repo Repository, signer *qrtoken.Signer) {
 	teacherID := r.URL.Query().Get("id")
 	if teacherID == "" {
 		http.Error(w, "Teacher ID missing", http.StatusBadRequest)
@@ -127,31 +113,22 @@ func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, client *couch
 	}
 
 	// Fetch student data from CouchDB
-	var teacher map[string]interface{}
-	err := client.DB("teacher_db").Get(teacherID, &teacher, couchdb.Options{})
+	teacher, err := repo.Get(teacherID)
 	if err != nil {
 		http.Error(w, "tecaher not found", http.StatusNotFound)
 		return
 	}
 
-	// Create a map to store the data for the QR code
-	teacherDataForQR := map[string]interface{}{
-		"id":              teacher["_id"],
-		"full_name":       teacher["full_name"],
-		"department":      teacher["contact_number"],
-		"email_address":   teacher["email_address"],
-		"subjects_taught": teacher["subjects_taught"],
-	}
-
-	// Convert the QR code data to JSON
-	qrData, err := json.Marshal(teacherDataForQR)
+	// The QR code carries only a signed token identifying the holder, so it
+	// can't be forged and doesn't leak contact details
+	token, claims, err := signer.Sign(teacherID, Kind, time.Now())
 	if err != nil {
-		http.Error(w, "Failed to marshal student data for QR code", http.StatusInternalServerError)
+		http.Error(w, "Failed to sign QR code token", http.StatusInternalServerError)
 		return
 	}
 
 	// Generate the QR code in memory
-	qrCode, err := qrcode.Encode(string(qrData), qrcode.Medium, 256)
+	qrCode, err := qrcode.Encode(token, qrcode.Medium, 256)
 	if err != nil {
 		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
 		return
@@ -162,9 +139,10 @@ func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, client *couch
 
 	// Add the Base64 QR code to the student document
 	teacher["qr_code"] = qrCodeBase64
+	teacher["qr_expires_at"] = time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC3339)
 
 	// Update the student document in the database
-	_, err = client.DB("teacher_db").Put(teacherID, teacher, teacher["_rev"].(string))
+	_, err = repo.Update(teacherID, teacher, store.Rev(teacher))
 	if err != nil {
 		http.Error(w, "Failed to save QR code in the database", http.StatusInternalServerError)
 		return
@@ -172,13 +150,14 @@ func GenerateAndSaveQRCode(w http.ResponseWriter, r *http.Request, client *couch
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "QR code generated and saved successfully",
-		"qr_code": qrCodeBase64,
+		"message":       "QR code generated and saved successfully",
+		"qr_code":       qrCodeBase64,
+		"qr_expires_at": teacher["qr_expires_at"].(string),
 	})
 }
 
 // Retrieve a student by ID
-func GetTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func GetTeacher(w http.ResponseWriter, r *http.Request, repo Repository) {
 	teacherID := r.URL.Query().Get("id")
 	if teacherID == "" {
 		http.Error(w, "teacher ID missing", http.StatusBadRequest)
@@ -186,8 +165,7 @@ func GetTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Client)
 	}
 
 	// var student Student
-	var teacher map[string]interface{}
-	err := client.DB("teacher_db").Get(teacherID, &teacher, couchdb.Options{})
+	teacher, err := repo.Get(teacherID)
 	fmt.Println("api got hit")
 	if err != nil {
 		http.Error(w, "teacher not found", http.StatusNotFound)
@@ -198,45 +176,53 @@ func GetTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Client)
 	json.NewEncoder(w).Encode(teacher)
 }
 
-func GetAllTeachers(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
-	// Define a struct for the result of AllDocs
-	type AllDocsResult struct {
-		Rows []struct {
-			ID  string          `json:"id"`
-			Doc json.RawMessage `json:"doc"`
-		} `json:"rows"`
+// GetAllTeachers returns one page of teachers, filtered by any of
+// department and gender and ordered by sort (full_name, department or
+// joining_date, "-" prefix for descending, default ID). limit sets the
+// page size; when there are more results the X-Next-Cursor header holds
+// the cursor for the next page.
+func GetAllTeachers(w http.ResponseWriter, r *http.Request, repo Repository) {
+	query, err := store.ParseQuery(r.URL.Query(), Index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	var result AllDocsResult
-
-	// Fetch all documents (students) from the "student_db"
-	err := client.DB("teacher_db").AllDocs(&result, couchdb.Options{
-		"include_docs": true, // Include full documents, not just IDs
-	})
+	// Fetch one page of documents from the repository
+	page, err := repo.Find(query)
+	if err == store.ErrBadQuery {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, store.ErrUnavailable) {
+		log.Printf("Failed to query %s: %v", DBName, err)
+		http.Error(w, "listing is temporarily unavailable, retry", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch students", http.StatusInternalServerError)
 		return
 	}
 
 	// Prepare a slice to store the student details
-	var teachers []map[string]interface{}
+	teachers := make([]map[string]interface{}, 0, len(page.Docs))
 
 	// Iterate over the rows and append the student details to the slice
-	for _, row := range result.Rows {
-		var teacher map[string]interface{}
-		if err := json.Unmarshal(row.Doc, &teacher); err == nil {
-			// Exclude the _rev field if necessary
-			delete(teacher, "_rev")
-			teachers = append(teachers, teacher)
-		}
+	for _, teacher := range page.Docs {
+		// Exclude the _rev field if necessary
+		delete(teacher, "_rev")
+		teachers = append(teachers, teacher)
 	}
 
 	// Respond with the list of students
+	if page.Next != "" {
+		w.Header().Set("X-Next-Cursor", page.Next)
+	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(teachers)
 }
 
-func UpdateTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func UpdateTeacher(w http.ResponseWriter, r *http.Request, repo Repository) {
 	var teacher Teacher
 
 	// Read the request body
@@ -247,9 +233,6 @@ func UpdateTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		return
 	}
 
-	// Log the raw request body
-	log.Printf("Raw request body: %s", string(body))
-
 	// Decode the request body into the teacher struct
 	if err := json.Unmarshal(body, &teacher); err != nil {
 		log.Printf("Error decoding request body: %v", err)
@@ -257,24 +240,13 @@ func UpdateTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		return
 	}
 
-	log.Printf("Decoded Teacher: %+v", teacher)
-
-	var existingDoc map[string]interface{}
-	err = client.DB("teacher_db").Get(teacher.ID, &existingDoc, couchdb.Options{})
+	existingDoc, err := repo.Get(teacher.ID)
 	if err != nil {
 		http.Error(w, "Teacher not found", http.StatusNotFound)
 		return
 	}
 
-	doc := map[string]interface{}{
-		"_id":             teacher.ID,
-		"_rev":            existingDoc["_rev"],
-		"full_name":       teacher.FullName,
-		"date_of_birth":   teacher.DateOfBirth.Format(ctLayout),
-		"gender":          teacher.Gender,
-		"address":         teacher.Address,
-		"contact_number":  teacher.ContactNumber,
-		"email_address":   teacher.EmailAddress,
+	doc := teacher.Person.Document(map[string]interface{}{
 		"department":      teacher.Department,
 		"subjects_taught": teacher.SubjectsTaught,
 		"qualification":   teacher.Qualification,
@@ -282,10 +254,22 @@ func UpdateTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 		"joining_date":    teacher.JoiningDate.Format(ctLayout),
 		"previous_school": teacher.PreviousSchool,
 		"salary":          teacher.Salary,
-		"leave_records":   teacher.LeaveRecords,
+		"payroll_info":    teacher.PayrollInfo,
+	})
+
+	// Attendance is written by gate check-ins and leave by the leave
+	// workflow, not by this endpoint
+	for _, key := range []string{"attendance_records", "leave_records"} {
+		if records, ok := existingDoc[key]; ok {
+			doc[key] = records
+		}
 	}
 
-	_, err = client.DB("teacher_db").Put(teacher.ID, doc, existingDoc["_rev"].(string))
+	_, err = repo.Update(teacher.ID, doc, store.Rev(existingDoc))
+	if err == store.ErrConflict {
+		http.Error(w, "teacher was modified concurrently, retry", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		log.Printf("Error updating teacher: %v", err)
 		http.Error(w, "failed to update teacher", http.StatusInternalServerError)
@@ -297,21 +281,20 @@ func UpdateTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Clien
 }
 
 // Delete student by ID
-func DeleteTeacher(w http.ResponseWriter, r *http.Request, client *couchdb.Client) {
+func DeleteTeacher(w http.ResponseWriter, r *http.Request, repo Repository) {
 	teacherID := r.URL.Query().Get("id")
 	if teacherID == "" {
 		http.Error(w, "Tecaher ID missing", http.StatusBadRequest)
 		return
 	}
 
-	var existingDoc map[string]interface{}
-	err := client.DB("teacher_db").Get(teacherID, &existingDoc, couchdb.Options{})
+	existingDoc, err := repo.Get(teacherID)
 	if err != nil {
 		http.Error(w, "teacher not found", http.StatusNotFound)
 		return
 	}
 
-	_, err = client.DB("teacher_db").Delete(teacherID, existingDoc["_rev"].(string))
+	err = repo.Delete(teacherID, store.Rev(existingDoc))
 	if err != nil {
 		http.Error(w, "failed to delete teacher", http.StatusInternalServerError)
 		return
@@ -0,0 +1,275 @@
+package timetable
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"data-access/teacher"
+)
+
+// Requirement is how many periods a week a subject needs. TeacherID is
+// optional; without it any teacher whose SubjectsTaught includes the
+// subject may be assigned.
+type Requirement struct {
+	Subject   string `json:"subject"`
+	Hours     int    `json:"hours"`
+	TeacherID string `json:"teacher_id"`
+}
+
+type period struct{ day, slot string }
+
+// generator fills a class's week one requirement at a time, keeping
+// track of which periods the class and each teacher already have
+type generator struct {
+	class, section string
+	days, slots    []string
+	classBusy      map[period]bool
+	teacherBusy    map[string]map[period]bool
+	load           map[string]int // periods per teacher, to spread work
+	entries        []Entry
+}
+
+func (g *generator) teacherFree(id string, p period) bool {
+	return !g.teacherBusy[id][p]
+}
+
+// freeCandidate returns the first candidate free in p, or ""
+func (g *generator) freeCandidate(candidates []string, p period) string {
+	for _, id := range candidates {
+		if g.teacherFree(id, p) {
+			return id
+		}
+	}
+	return ""
+}
+
+func (g *generator) book(id string, p period, subject string) {
+	if g.teacherBusy[id] == nil {
+		g.teacherBusy[id] = make(map[period]bool)
+	}
+	g.teacherBusy[id][p] = true
+	g.classBusy[p] = true
+	g.load[id]++
+	g.entries = append(g.entries, Entry{
+		Day: p.day, TimeSlot: p.slot, Subject: subject,
+		Class: g.class, Section: g.section, TeacherID: id,
+	})
+}
+
+// best picks the free period for subject that keeps it spread over the
+// week: the day with the fewest periods of that subject so far, counting
+// pending ones not booked yet, earliest slot first
+func (g *generator) best(subject string, pending []period, free func(period) bool) (period, bool) {
+	perDay := make(map[string]int)
+	for _, e := range g.entries {
+		if e.Subject == subject {
+			perDay[e.Day]++
+		}
+	}
+	taken := make(map[period]bool)
+	for _, p := range pending {
+		perDay[p.day]++
+		taken[p] = true
+	}
+	var found period
+	bestCount := -1
+	for _, slot := range g.slots {
+		for _, day := range g.days {
+			p := period{day, slot}
+			if g.classBusy[p] || taken[p] || !free(p) {
+				continue
+			}
+			if bestCount == -1 || perDay[day] < bestCount {
+				found, bestCount = p, perDay[day]
+			}
+		}
+	}
+	return found, bestCount != -1
+}
+
+// place books hours periods of a subject. It first tries to give the whole
+// subject to one teacher, least loaded first, and falls back to sharing
+// it between candidates. It returns how many periods could not be placed.
+func (g *generator) place(subject string, hours int, candidates []string) int {
+	sort.SliceStable(candidates, func(i, j int) bool { return g.load[candidates[i]] < g.load[candidates[j]] })
+
+	for _, id := range candidates {
+		var periods []period
+		for len(periods) < hours {
+			p, ok := g.best(subject, periods, func(p period) bool { return g.teacherFree(id, p) })
+			if !ok {
+				break
+			}
+			periods = append(periods, p)
+		}
+		if len(periods) == hours {
+			for _, p := range periods {
+				g.book(id, p, subject)
+			}
+			return 0
+		}
+	}
+
+	missing := hours
+	for ; missing > 0; missing-- {
+		p, ok := g.best(subject, nil, func(p period) bool { return g.freeCandidate(candidates, p) != "" })
+		if !ok {
+			break
+		}
+		g.book(g.freeCandidate(candidates, p), p, subject)
+	}
+	return missing
+}
+
+// GenerateTimetable builds a week for a class from subject-hour
+// requirements, avoiding periods in which the chosen teachers already
+// teach elsewhere. With "save": true the result replaces the class's
+// timetable. If some periods can't be placed, nothing is saved and the
+// partial timetable is returned with the shortfall per subject.
+func GenerateTimetable(w http.ResponseWriter, r *http.Request, repo Repository, teachers teacher.Repository) {
+	var request struct {
+		Class        string        `json:"class"`
+		Section      string        `json:"section"`
+		Days         []string      `json:"days"`
+		Slots        []string      `json:"slots"`
+		Requirements []Requirement `json:"requirements"`
+		Save         bool          `json:"save"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if request.Class == "" || len(request.Slots) == 0 || len(request.Requirements) == 0 {
+		http.Error(w, "class, slots and requirements are required", http.StatusBadRequest)
+		return
+	}
+	days := Days[:5]
+	if len(request.Days) > 0 {
+		days = nil
+		for _, d := range request.Days {
+			day, ok := ParseDay(d)
+			if !ok {
+				http.Error(w, fmt.Sprintf("unknown day %q", d), http.StatusBadRequest)
+				return
+			}
+			days = append(days, day)
+		}
+	}
+
+	taught, err := subjectsTaught(teachers)
+	if err != nil {
+		http.Error(w, "Failed to fetch teachers", http.StatusInternalServerError)
+		return
+	}
+
+	// Candidate teachers per requirement
+	total := 0
+	candidates := make([][]string, len(request.Requirements))
+	for i, req := range request.Requirements {
+		if req.Subject == "" || req.Hours <= 0 {
+			http.Error(w, "every requirement needs a subject and positive hours", http.StatusBadRequest)
+			return
+		}
+		total += req.Hours
+		subject := strings.ToLower(req.Subject)
+		if req.TeacherID != "" {
+			if !taught[req.TeacherID][subject] {
+				http.Error(w, fmt.Sprintf("teacher %s does not teach %s", req.TeacherID, req.Subject), http.StatusBadRequest)
+				return
+			}
+			candidates[i] = []string{req.TeacherID}
+			continue
+		}
+		for id, subjects := range taught {
+			if subjects[subject] {
+				candidates[i] = append(candidates[i], id)
+			}
+		}
+		if len(candidates[i]) == 0 {
+			http.Error(w, "no teacher teaches "+req.Subject, http.StatusBadRequest)
+			return
+		}
+		sort.Strings(candidates[i])
+	}
+	if total > len(days)*len(request.Slots) {
+		http.Error(w, fmt.Sprintf("requirements need %d periods but the week only has %d", total, len(days)*len(request.Slots)), http.StatusBadRequest)
+		return
+	}
+
+	g := &generator{
+		class: request.Class, section: request.Section,
+		days: days, slots: request.Slots,
+		classBusy:   make(map[period]bool),
+		teacherBusy: make(map[string]map[period]bool),
+		load:        make(map[string]int),
+	}
+	others, err := otherEntries(repo, timetableID(request.Class, request.Section))
+	if err != nil {
+		http.Error(w, "Failed to fetch timetables", http.StatusInternalServerError)
+		return
+	}
+	self := Entry{Class: request.Class, Section: request.Section}
+	for _, e := range others {
+		p := period{e.Day, e.TimeSlot}
+		if e.TeacherID != "" {
+			if g.teacherBusy[e.TeacherID] == nil {
+				g.teacherBusy[e.TeacherID] = make(map[period]bool)
+			}
+			g.teacherBusy[e.TeacherID][p] = true
+			g.load[e.TeacherID]++
+		}
+		if sameClass(e, self) {
+			g.classBusy[p] = true // e.g. a whole-class period over this section
+		}
+	}
+
+	// Most constrained requirements first: fewest teachers, then most hours
+	order := make([]int, len(request.Requirements))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		if len(candidates[i]) != len(candidates[j]) {
+			return len(candidates[i]) < len(candidates[j])
+		}
+		return request.Requirements[i].Hours > request.Requirements[j].Hours
+	})
+	unplaced := map[string]int{}
+	for _, i := range order {
+		req := request.Requirements[i]
+		if missing := g.place(req.Subject, req.Hours, candidates[i]); missing > 0 {
+			unplaced[req.Subject] += missing
+		}
+	}
+
+	t := Timetable{ID: timetableID(request.Class, request.Section), Class: request.Class, Section: request.Section, Entries: g.entries}
+	sortEntries(t.Entries)
+	if len(unplaced) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":     "not every period could be placed",
+			"unplaced":  unplaced,
+			"timetable": t,
+		})
+		return
+	}
+
+	if request.Save {
+		clashes, err := save(repo, teachers, &t)
+		if err != nil || len(clashes) > 0 {
+			writeSaveError(w, clashes, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "Timetable generated",
+		"saved":     request.Save,
+		"timetable": t,
+	})
+}
@@ -0,0 +1,23 @@
+package timetable
+
+import (
+	"data-access/store"
+
+	"github.com/fjl/go-couchdb"
+)
+
+// DBName is the CouchDB database holding class timetables
+const DBName = "timetable_db"
+
+// Repository is the storage backend for timetable documents
+type Repository = store.Repository
+
+// NewCouchRepository returns a Repository backed by the timetable_db database
+func NewCouchRepository(client *couchdb.Client) Repository {
+	return store.NewCouchDB(client, DBName)
+}
+
+// NewMemoryRepository returns an empty in-memory Repository
+func NewMemoryRepository() Repository {
+	return store.NewMemory()
+}
@@ -0,0 +1,453 @@
+package timetable
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"data-access/auth"
+	"data-access/store"
+	"data-access/teacher"
+)
+
+const dateLayout = "2006-01-02"
+
+// Substitution records a teacher covering another teacher's period on one
+// date
+type Substitution struct {
+	ID           string `json:"id"`
+	Date         string `json:"date"`
+	Day          string `json:"day"`
+	TimeSlot     string `json:"time_slot"`
+	Subject      string `json:"subject"`
+	Class        string `json:"class"`
+	Section      string `json:"section,omitempty"`
+	TeacherID    string `json:"teacher_id"` // the teacher on leave
+	SubstituteID string `json:"substitute_id"`
+	LeaveID      string `json:"leave_id,omitempty"`
+	AssignedBy   string `json:"assigned_by"`
+	AssignedAt   string `json:"assigned_at"`
+}
+
+// Candidate is a teacher free to cover a period. Periods counts what they
+// already teach or cover that day, so the least busy come first.
+type Candidate struct {
+	TeacherID string `json:"teacher_id"`
+	FullName  string `json:"full_name"`
+	Periods   int    `json:"periods"`
+}
+
+// Cover is one period left by a teacher on leave, with the substitution
+// made for it, if any, and the teachers who could take it
+type Cover struct {
+	Date string `json:"date"`
+	Entry
+	Substitution *Substitution `json:"substitution,omitempty"`
+	Candidates   []Candidate   `json:"candidates"`
+}
+
+func substitutionID(date, slot, class, section string) string {
+	return "substitution:" + date + ":" + slot + ":" + strings.TrimPrefix(timetableID(class, section), "timetable:")
+}
+
+// dayName returns the timetable day of a date; Sundays have none
+func dayName(d time.Time) (string, bool) {
+	if d.Weekday() == time.Sunday {
+		return "", false
+	}
+	return Days[int(d.Weekday())-1], true
+}
+
+// Substitutions returns the substitutions made for a date, or for every
+// date if date is ""
+func Substitutions(repo Repository, date string) ([]Substitution, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	subs := []Substitution{}
+	for _, doc := range docs {
+		if doc["type"] != "substitution" || (date != "" && doc["date"] != date) {
+			continue
+		}
+		var s Substitution
+		if store.Decode(doc, &s) != nil {
+			continue
+		}
+		s.ID, _ = doc["_id"].(string)
+		subs = append(subs, s)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].Date != subs[j].Date {
+			return subs[i].Date < subs[j].Date
+		}
+		return subs[i].TimeSlot < subs[j].TimeSlot
+	})
+	return subs, nil
+}
+
+// schedule is what each teacher does on one date: their timetable periods
+// for the weekday plus any periods they cover
+type schedule struct {
+	busy    map[string]map[string]bool // teacher ID, then slot
+	periods map[string]int
+	subs    []Substitution
+	absent  map[string]teacher.LeaveRecord
+}
+
+func loadSchedule(repo Repository, teachers teacher.Repository, timetables []Timetable, d time.Time) (*schedule, error) {
+	date := d.Format(dateLayout)
+	day, _ := dayName(d)
+	s := &schedule{
+		busy:    make(map[string]map[string]bool),
+		periods: make(map[string]int),
+		absent:  make(map[string]teacher.LeaveRecord),
+	}
+	book := func(id, slot string) {
+		if s.busy[id] == nil {
+			s.busy[id] = make(map[string]bool)
+		}
+		s.busy[id][slot] = true
+		s.periods[id]++
+	}
+	for _, t := range timetables {
+		for _, e := range t.Entries {
+			if e.Day == day && e.TeacherID != "" {
+				book(e.TeacherID, e.TimeSlot)
+			}
+		}
+	}
+	var err error
+	if s.subs, err = Substitutions(repo, date); err != nil {
+		return nil, err
+	}
+	for _, sub := range s.subs {
+		book(sub.SubstituteID, sub.TimeSlot)
+	}
+	absences, err := teacher.Absences(teachers, d, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range absences {
+		s.absent[a.TeacherID] = a.Leave
+	}
+	return s, nil
+}
+
+// substitution returns the substitution made for a period, if any
+func (s *schedule) substitution(e Entry) *Substitution {
+	for i, sub := range s.subs {
+		if sub.TimeSlot == e.TimeSlot && sub.Class == e.Class && sub.Section == e.Section {
+			return &s.subs[i]
+		}
+	}
+	return nil
+}
+
+// candidates lists the teachers who teach the entry's subject and are
+// neither on leave nor busy in its slot
+func (s *schedule) candidates(e Entry, taught map[string]map[string]bool, names map[string]string) []Candidate {
+	candidates := []Candidate{}
+	for id, subjects := range taught {
+		if id == e.TeacherID || !subjects[strings.ToLower(e.Subject)] {
+			continue
+		}
+		if _, out := s.absent[id]; out || s.busy[id][e.TimeSlot] {
+			continue
+		}
+		candidates = append(candidates, Candidate{TeacherID: id, FullName: names[id], Periods: s.periods[id]})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Periods != candidates[j].Periods {
+			return candidates[i].Periods < candidates[j].Periods
+		}
+		return candidates[i].TeacherID < candidates[j].TeacherID
+	})
+	return candidates
+}
+
+func teacherNames(teachers teacher.Repository) (map[string]string, error) {
+	docs, err := teachers.List()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, doc := range docs {
+		id, _ := doc["_id"].(string)
+		names[id], _ = doc["full_name"].(string)
+	}
+	return names, nil
+}
+
+// coverage lists every period a teacher misses between from and to
+func coverage(repo Repository, teachers teacher.Repository, teacherID string, from, to time.Time) ([]Cover, error) {
+	timetables, err := List(repo)
+	if err != nil {
+		return nil, err
+	}
+	taught, err := subjectsTaught(teachers)
+	if err != nil {
+		return nil, err
+	}
+	names, err := teacherNames(teachers)
+	if err != nil {
+		return nil, err
+	}
+
+	covers := []Cover{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day, ok := dayName(d)
+		if !ok {
+			continue
+		}
+		var missed []Entry
+		for _, t := range timetables {
+			for _, e := range t.Entries {
+				if e.Day == day && e.TeacherID == teacherID {
+					missed = append(missed, e)
+				}
+			}
+		}
+		if len(missed) == 0 {
+			continue
+		}
+		s, err := loadSchedule(repo, teachers, timetables, d)
+		if err != nil {
+			return nil, err
+		}
+		sortEntries(missed)
+		for _, e := range missed {
+			c := Cover{Date: d.Format(dateLayout), Entry: e, Substitution: s.substitution(e)}
+			c.Candidates = s.candidates(e, taught, names)
+			covers = append(covers, c)
+		}
+	}
+	return covers, nil
+}
+
+// GetCover lists the periods left uncovered by an approved leave request
+// (?id=..&leave_id=..), each with its substitution if one has been made and
+// the qualified teachers free to take it
+func GetCover(w http.ResponseWriter, r *http.Request, repo Repository, teachers teacher.Repository) {
+	teacherID, leaveID := r.URL.Query().Get("id"), r.URL.Query().Get("leave_id")
+	if teacherID == "" || leaveID == "" {
+		http.Error(w, "Teacher ID and leave_id are required", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := teachers.Get(teacherID)
+	if err != nil {
+		http.Error(w, "Teacher not found", http.StatusNotFound)
+		return
+	}
+	records, err := teacher.LeaveRecords(doc)
+	if err != nil {
+		http.Error(w, "failed to read leave records", http.StatusInternalServerError)
+		return
+	}
+	var leave *teacher.LeaveRecord
+	for i := range records {
+		if records[i].ID == leaveID {
+			leave = &records[i]
+		}
+	}
+	if leave == nil {
+		http.Error(w, "leave request not found", http.StatusNotFound)
+		return
+	}
+	if !strings.EqualFold(leave.Status, teacher.LeaveApproved) {
+		http.Error(w, "leave request is not approved", http.StatusConflict)
+		return
+	}
+
+	covers, err := coverage(repo, teachers, teacherID, leave.StartDate.Time, leave.EndDate.Time)
+	if err != nil {
+		http.Error(w, "Failed to work out cover", http.StatusInternalServerError)
+		return
+	}
+	uncovered := 0
+	for _, c := range covers {
+		if c.Substitution == nil {
+			uncovered++
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"teacher_id": teacherID,
+		"leave":      leave,
+		"uncovered":  uncovered,
+		"periods":    covers,
+	})
+}
+
+// AssignSubstitute records who covers a period of a teacher on approved
+// leave, replacing any earlier assignment for that period. The substitute
+// must teach the subject and be free and not on leave at the time.
+func AssignSubstitute(w http.ResponseWriter, r *http.Request, repo Repository, teachers teacher.Repository) {
+	var request struct {
+		Date         string `json:"date"`
+		TimeSlot     string `json:"time_slot"`
+		Class        string `json:"class"`
+		Section      string `json:"section"`
+		SubstituteID string `json:"substitute_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if request.TimeSlot == "" || request.Class == "" || request.SubstituteID == "" {
+		http.Error(w, "time_slot, class and substitute_id are required", http.StatusBadRequest)
+		return
+	}
+	d, err := time.Parse(dateLayout, request.Date)
+	if err != nil {
+		http.Error(w, "date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	day, ok := dayName(d)
+	if !ok {
+		http.Error(w, "no classes on Sundays", http.StatusBadRequest)
+		return
+	}
+
+	timetables, err := List(repo)
+	if err != nil {
+		http.Error(w, "Failed to fetch timetables", http.StatusInternalServerError)
+		return
+	}
+	var entry *Entry
+	for _, t := range timetables {
+		if t.ID != timetableID(request.Class, request.Section) {
+			continue
+		}
+		for i, e := range t.Entries {
+			if e.Day == day && e.TimeSlot == request.TimeSlot {
+				entry = &t.Entries[i]
+			}
+		}
+	}
+	if entry == nil || entry.TeacherID == "" {
+		http.Error(w, "no taught period in that class's timetable at that time", http.StatusNotFound)
+		return
+	}
+
+	s, err := loadSchedule(repo, teachers, timetables, d)
+	if err != nil {
+		http.Error(w, "Failed to fetch schedules", http.StatusInternalServerError)
+		return
+	}
+	leave, onLeave := s.absent[entry.TeacherID]
+	if !onLeave {
+		http.Error(w, "the period's teacher is not on approved leave that day", http.StatusConflict)
+		return
+	}
+	taught, err := subjectsTaught(teachers)
+	if err != nil {
+		http.Error(w, "Failed to fetch teachers", http.StatusInternalServerError)
+		return
+	}
+	if _, ok := taught[request.SubstituteID]; !ok {
+		http.Error(w, "substitute not found", http.StatusNotFound)
+		return
+	}
+	if !taught[request.SubstituteID][strings.ToLower(entry.Subject)] {
+		http.Error(w, "substitute does not teach "+entry.Subject, http.StatusBadRequest)
+		return
+	}
+	if _, out := s.absent[request.SubstituteID]; out {
+		http.Error(w, "substitute is on leave that day", http.StatusConflict)
+		return
+	}
+	previous := s.substitution(*entry)
+	alreadyCovering := previous != nil && previous.SubstituteID == request.SubstituteID
+	if s.busy[request.SubstituteID][entry.TimeSlot] && !alreadyCovering {
+		http.Error(w, "substitute already has a period at that time", http.StatusConflict)
+		return
+	}
+
+	caller, _ := auth.FromContext(r.Context())
+	sub := Substitution{
+		ID:           substitutionID(request.Date, entry.TimeSlot, entry.Class, entry.Section),
+		Date:         request.Date,
+		Day:          day,
+		TimeSlot:     entry.TimeSlot,
+		Subject:      entry.Subject,
+		Class:        entry.Class,
+		Section:      entry.Section,
+		TeacherID:    entry.TeacherID,
+		SubstituteID: request.SubstituteID,
+		LeaveID:      leave.ID,
+		AssignedBy:   caller.Email,
+		AssignedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	doc := map[string]interface{}{
+		"type":          "substitution",
+		"date":          sub.Date,
+		"day":           sub.Day,
+		"time_slot":     sub.TimeSlot,
+		"subject":       sub.Subject,
+		"class":         sub.Class,
+		"section":       sub.Section,
+		"teacher_id":    sub.TeacherID,
+		"substitute_id": sub.SubstituteID,
+		"leave_id":      sub.LeaveID,
+		"assigned_by":   sub.AssignedBy,
+		"assigned_at":   sub.AssignedAt,
+	}
+	existing, err := repo.Get(sub.ID)
+	switch err {
+	case nil:
+		_, err = repo.Update(sub.ID, doc, store.Rev(existing))
+	case store.ErrNotFound:
+		_, err = repo.Create(sub.ID, doc)
+	}
+	if err == store.ErrConflict {
+		http.Error(w, "substitution was modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save substitution", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Substitute assigned successfully",
+		"substitution": sub,
+	})
+}
+
+// GetSubstitutions lists the substitutions for a date (?date=.., default
+// today), optionally only those involving one teacher (?id=..)
+func GetSubstitutions(w http.ResponseWriter, r *http.Request, repo Repository) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = time.Now().Format(dateLayout)
+	}
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		http.Error(w, "date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	teacherID := r.URL.Query().Get("id")
+
+	subs, err := Substitutions(repo, date)
+	if err != nil {
+		http.Error(w, "Failed to fetch substitutions", http.StatusInternalServerError)
+		return
+	}
+	matching := []Substitution{}
+	for _, s := range subs {
+		if teacherID == "" || s.TeacherID == teacherID || s.SubstituteID == teacherID {
+			matching = append(matching, s)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"date":          date,
+		"substitutions": matching,
+	})
+}
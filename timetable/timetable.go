@@ -0,0 +1,399 @@
+package timetable
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"data-access/store"
+	"data-access/teacher"
+)
+
+// Days are the school days a timetable can use, in week order
+var Days = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+// Entry is one period: a subject taught to a class by a teacher. Time
+// slots are labels such as "09:00-09:45"; every class shares the same bell
+// schedule, so two entries clash when their day and slot are equal.
+type Entry struct {
+	Day       string `json:"day"`
+	TimeSlot  string `json:"time_slot"`
+	Subject   string `json:"subject"`
+	Class     string `json:"class"`
+	Section   string `json:"section,omitempty"`
+	TeacherID string `json:"teacher_id"`
+}
+
+// Timetable is the weekly timetable of a class, or of one section of it
+type Timetable struct {
+	ID      string  `json:"id"`
+	Class   string  `json:"class"`
+	Section string  `json:"section"`
+	Entries []Entry `json:"entries"`
+}
+
+// Clash is a teacher or a class booked twice in the same period
+type Clash struct {
+	Kind      string  `json:"kind"` // "teacher" or "class"
+	Day       string  `json:"day"`
+	TimeSlot  string  `json:"time_slot"`
+	TeacherID string  `json:"teacher_id,omitempty"`
+	Entries   []Entry `json:"entries"`
+}
+
+func timetableID(class, section string) string {
+	if section == "" {
+		return "timetable:" + class
+	}
+	return "timetable:" + class + ":" + section
+}
+
+// ParseDay accepts a day name or its three-letter abbreviation in any case
+func ParseDay(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, day := range Days {
+		if s == strings.ToLower(day) || s == strings.ToLower(day[:3]) {
+			return day, true
+		}
+	}
+	return "", false
+}
+
+func dayIndex(day string) int {
+	for i, d := range Days {
+		if d == day {
+			return i
+		}
+	}
+	return len(Days)
+}
+
+// sortEntries orders entries by day of the week, then slot
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if a, b := dayIndex(entries[i].Day), dayIndex(entries[j].Day); a != b {
+			return a < b
+		}
+		return entries[i].TimeSlot < entries[j].TimeSlot
+	})
+}
+
+// sameClass reports whether two entries are for the same group of
+// students. A whole-class entry overlaps every section of that class.
+func sameClass(a, b Entry) bool {
+	return a.Class == b.Class && (a.Section == "" || b.Section == "" || a.Section == b.Section)
+}
+
+// FindClashes reports every period in which a teacher or a class is booked
+// more than once
+func FindClashes(entries []Entry) []Clash {
+	type period struct{ day, slot string }
+	byPeriod := make(map[period][]Entry)
+	var periods []period
+	for _, e := range entries {
+		p := period{e.Day, e.TimeSlot}
+		if _, ok := byPeriod[p]; !ok {
+			periods = append(periods, p)
+		}
+		byPeriod[p] = append(byPeriod[p], e)
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		if a, b := dayIndex(periods[i].day), dayIndex(periods[j].day); a != b {
+			return a < b
+		}
+		return periods[i].slot < periods[j].slot
+	})
+
+	clashes := []Clash{}
+	for _, p := range periods {
+		group := byPeriod[p]
+		byTeacher := make(map[string][]Entry)
+		var teachers []string
+		for i, e := range group {
+			if e.TeacherID != "" {
+				if _, ok := byTeacher[e.TeacherID]; !ok {
+					teachers = append(teachers, e.TeacherID)
+				}
+				byTeacher[e.TeacherID] = append(byTeacher[e.TeacherID], e)
+			}
+			for _, other := range group[i+1:] {
+				if sameClass(e, other) {
+					clashes = append(clashes, Clash{Kind: "class", Day: p.day, TimeSlot: p.slot, Entries: []Entry{e, other}})
+				}
+			}
+		}
+		for _, id := range teachers {
+			if len(byTeacher[id]) > 1 {
+				clashes = append(clashes, Clash{Kind: "teacher", Day: p.day, TimeSlot: p.slot, TeacherID: id, Entries: byTeacher[id]})
+			}
+		}
+	}
+	return clashes
+}
+
+// involves reports whether a clash includes an entry of the given class
+func (c Clash) involves(class, section string) bool {
+	for _, e := range c.Entries {
+		if e.Class == class && e.Section == section {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns every stored timetable
+func List(repo Repository) ([]Timetable, error) {
+	docs, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	timetables := []Timetable{}
+	for _, doc := range docs {
+		if doc["type"] != "timetable" {
+			continue
+		}
+		var t Timetable
+		if store.Decode(doc, &t) != nil {
+			continue
+		}
+		t.ID, _ = doc["_id"].(string)
+		timetables = append(timetables, t)
+	}
+	return timetables, nil
+}
+
+// otherEntries returns the entries of every timetable except the one with
+// the given ID
+func otherEntries(repo Repository, id string) ([]Entry, error) {
+	timetables, err := List(repo)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, t := range timetables {
+		if t.ID != id {
+			entries = append(entries, t.Entries...)
+		}
+	}
+	return entries, nil
+}
+
+// subjectsTaught looks up what each teacher teaches, keyed by teacher ID
+// and lower-cased subject
+func subjectsTaught(teachers teacher.Repository) (map[string]map[string]bool, error) {
+	docs, err := teachers.List()
+	if err != nil {
+		return nil, err
+	}
+	taught := make(map[string]map[string]bool)
+	for _, doc := range docs {
+		var t struct {
+			SubjectsTaught []string `json:"subjects_taught"`
+		}
+		if store.Decode(doc, &t) != nil {
+			continue
+		}
+		id, _ := doc["_id"].(string)
+		taught[id] = make(map[string]bool)
+		for _, subject := range t.SubjectsTaught {
+			taught[id][strings.ToLower(subject)] = true
+		}
+	}
+	return taught, nil
+}
+
+// normalize checks a timetable's entries and fills in their class and
+// section from the timetable
+func (t *Timetable) normalize(taught map[string]map[string]bool) error {
+	if t.Class == "" {
+		return fmt.Errorf("class missing")
+	}
+	for i := range t.Entries {
+		e := &t.Entries[i]
+		day, ok := ParseDay(e.Day)
+		if !ok {
+			return fmt.Errorf("entry %d: unknown day %q", i+1, e.Day)
+		}
+		e.Day, e.Class, e.Section = day, t.Class, t.Section
+		if e.TimeSlot == "" || e.Subject == "" {
+			return fmt.Errorf("entry %d: time_slot and subject are required", i+1)
+		}
+		if e.TeacherID == "" {
+			continue // free period or teacher still to be assigned
+		}
+		subjects, ok := taught[e.TeacherID]
+		if !ok {
+			return fmt.Errorf("entry %d: teacher %s not found", i+1, e.TeacherID)
+		}
+		if !subjects[strings.ToLower(e.Subject)] {
+			return fmt.Errorf("entry %d: teacher %s does not teach %s", i+1, e.TeacherID, e.Subject)
+		}
+	}
+	sortEntries(t.Entries)
+	return nil
+}
+
+// save validates a timetable against every other class's timetable and
+// stores it, replacing the class's previous timetable. It returns the
+// clashes that prevented saving, if any.
+func save(repo Repository, teachers teacher.Repository, t *Timetable) ([]Clash, error) {
+	taught, err := subjectsTaught(teachers)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.normalize(taught); err != nil {
+		return nil, badRequest{err}
+	}
+	t.ID = timetableID(t.Class, t.Section)
+
+	others, err := otherEntries(repo, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	var clashes []Clash
+	for _, c := range FindClashes(append(others, t.Entries...)) {
+		if c.involves(t.Class, t.Section) {
+			clashes = append(clashes, c)
+		}
+	}
+	if len(clashes) > 0 {
+		return clashes, nil
+	}
+
+	doc := map[string]interface{}{
+		"type":    "timetable",
+		"class":   t.Class,
+		"section": t.Section,
+		"entries": t.Entries,
+	}
+	existing, err := repo.Get(t.ID)
+	switch err {
+	case nil:
+		_, err = repo.Update(t.ID, doc, store.Rev(existing))
+	case store.ErrNotFound:
+		_, err = repo.Create(t.ID, doc)
+	}
+	return nil, err
+}
+
+// badRequest marks validation errors that should be reported to the caller
+type badRequest struct{ error }
+
+// writeSaveError reports a failed save
+func writeSaveError(w http.ResponseWriter, clashes []Clash, err error) {
+	if _, ok := err.(badRequest); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == store.ErrConflict {
+		http.Error(w, "timetable was modified concurrently, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save timetable", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   "timetable clashes with existing bookings",
+		"clashes": clashes,
+	})
+}
+
+// SetTimetable creates or replaces the weekly timetable of a class (and
+// optionally section). Every teacher must teach the subject they are
+// assigned, and neither the class nor any teacher may be booked twice in
+// one period, including by other classes' timetables.
+func SetTimetable(w http.ResponseWriter, r *http.Request, repo Repository, teachers teacher.Repository) {
+	var t Timetable
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	clashes, err := save(repo, teachers, &t)
+	if err != nil || len(clashes) > 0 {
+		writeSaveError(w, clashes, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "Timetable saved successfully",
+		"timetable": t,
+	})
+}
+
+// GetTimetable returns the timetable of a class (?class=..&section=..)
+func GetTimetable(w http.ResponseWriter, r *http.Request, repo Repository) {
+	class, section := r.URL.Query().Get("class"), r.URL.Query().Get("section")
+	if class == "" {
+		http.Error(w, "class missing", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := repo.Get(timetableID(class, section))
+	if err != nil {
+		http.Error(w, "Timetable not found", http.StatusNotFound)
+		return
+	}
+	var t Timetable
+	if err := store.Decode(doc, &t); err != nil {
+		http.Error(w, "failed to read timetable", http.StatusInternalServerError)
+		return
+	}
+	t.ID, _ = doc["_id"].(string)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(t)
+}
+
+// GetTeacherTimetable collects a teacher's periods across every class
+// timetable (?id=..)
+func GetTeacherTimetable(w http.ResponseWriter, r *http.Request, repo Repository) {
+	teacherID := r.URL.Query().Get("id")
+	if teacherID == "" {
+		http.Error(w, "Teacher ID missing", http.StatusBadRequest)
+		return
+	}
+
+	timetables, err := List(repo)
+	if err != nil {
+		http.Error(w, "Failed to fetch timetables", http.StatusInternalServerError)
+		return
+	}
+	entries := []Entry{}
+	for _, t := range timetables {
+		for _, e := range t.Entries {
+			if e.TeacherID == teacherID {
+				entries = append(entries, e)
+			}
+		}
+	}
+	sortEntries(entries)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"teacher_id": teacherID,
+		"periods":    len(entries),
+		"entries":    entries,
+	})
+}
+
+// GetClashes checks every stored timetable for double bookings
+func GetClashes(w http.ResponseWriter, r *http.Request, repo Repository) {
+	timetables, err := List(repo)
+	if err != nil {
+		http.Error(w, "Failed to fetch timetables", http.StatusInternalServerError)
+		return
+	}
+	var entries []Entry
+	for _, t := range timetables {
+		entries = append(entries, t.Entries...)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"clashes": FindClashes(entries)})
+}
@@ -0,0 +1,131 @@
+package timetable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"data-access/store"
+	"data-access/teacher"
+)
+
+func TestFindClashes(t *testing.T) {
+	entry := func(day, slot, class, section, teacherID string) Entry {
+		return Entry{Day: day, TimeSlot: slot, Subject: "Maths", Class: class, Section: section, TeacherID: teacherID}
+	}
+	tests := []struct {
+		name    string
+		entries []Entry
+		kinds   []string
+	}{
+		{"no overlap", []Entry{
+			entry("Monday", "1", "5", "A", "t1"),
+			entry("Monday", "2", "5", "A", "t1"),
+			entry("Monday", "1", "5", "B", "t2"),
+		}, nil},
+		{"teacher in two classes", []Entry{
+			entry("Monday", "1", "5", "A", "t1"),
+			entry("Monday", "1", "6", "", "t1"),
+		}, []string{"teacher"}},
+		{"class booked twice", []Entry{
+			entry("Tuesday", "2", "5", "A", "t1"),
+			entry("Tuesday", "2", "5", "A", "t2"),
+		}, []string{"class"}},
+		{"whole class over a section", []Entry{
+			entry("Friday", "3", "5", "", "t1"),
+			entry("Friday", "3", "5", "B", "t2"),
+		}, []string{"class"}},
+		{"same teacher, same section", []Entry{
+			entry("Monday", "1", "5", "A", "t1"),
+			entry("Monday", "1", "5", "A", "t1"),
+		}, []string{"class", "teacher"}},
+		{"free periods have no teacher", []Entry{
+			entry("Monday", "1", "5", "A", ""),
+			entry("Monday", "1", "6", "A", ""),
+		}, nil},
+	}
+	for _, tt := range tests {
+		clashes := FindClashes(tt.entries)
+		var kinds []string
+		for _, c := range clashes {
+			kinds = append(kinds, c.Kind)
+		}
+		if strings.Join(kinds, ",") != strings.Join(tt.kinds, ",") {
+			t.Errorf("%s: clashes = %v, want %v", tt.name, kinds, tt.kinds)
+		}
+	}
+}
+
+// setup returns a timetable store in which t1 already teaches class 6 on
+// Monday in slot 1, and the teachers t1 (Maths) and t2 (English)
+func setup(t *testing.T) (Repository, teacher.Repository) {
+	repo, teachers := NewMemoryRepository(), teacher.NewMemoryRepository()
+	teachers.Create("t1", map[string]interface{}{"subjects_taught": []string{"Maths"}})
+	teachers.Create("t2", map[string]interface{}{"subjects_taught": []string{"English"}})
+	other := Timetable{Class: "6", Entries: []Entry{{Day: "Monday", TimeSlot: "1", Subject: "Maths", TeacherID: "t1"}}}
+	if clashes, err := save(repo, teachers, &other); err != nil || len(clashes) > 0 {
+		t.Fatalf("save = %v, %v", clashes, err)
+	}
+	return repo, teachers
+}
+
+func generate(repo Repository, teachers teacher.Repository, body string) (int, map[string]json.RawMessage) {
+	w := httptest.NewRecorder()
+	GenerateTimetable(w, httptest.NewRequest("POST", "/timetables/generate", strings.NewReader(body)), repo, teachers)
+	var resp map[string]json.RawMessage
+	json.NewDecoder(w.Body).Decode(&resp)
+	return w.Code, resp
+}
+
+func TestGenerateTimetable(t *testing.T) {
+	repo, teachers := setup(t)
+	code, resp := generate(repo, teachers, `{
+		"class": "5", "section": "A", "days": ["mon", "tue"], "slots": ["1", "2"], "save": true,
+		"requirements": [{"subject": "Maths", "hours": 2}, {"subject": "English", "hours": 2}]
+	}`)
+	if code != http.StatusOK {
+		t.Fatalf("GenerateTimetable = %d %s", code, resp["error"])
+	}
+	var generated Timetable
+	json.Unmarshal(resp["timetable"], &generated)
+	if len(generated.Entries) != 4 {
+		t.Fatalf("entries = %v, want 4", generated.Entries)
+	}
+	for _, e := range generated.Entries {
+		if e.TeacherID == "t1" && e.Day == "Monday" && e.TimeSlot == "1" {
+			t.Errorf("t1 booked in class 5 while teaching class 6: %+v", e)
+		}
+	}
+
+	all, _ := List(repo)
+	var entries []Entry
+	for _, tt := range all {
+		entries = append(entries, tt.Entries...)
+	}
+	if len(all) != 2 || len(FindClashes(entries)) != 0 {
+		t.Errorf("stored timetables = %+v, clashes %v", all, FindClashes(entries))
+	}
+}
+
+func TestGenerateTimetableShortfall(t *testing.T) {
+	repo, teachers := setup(t)
+	// t1 is busy in one of the three Monday slots, so one Maths period
+	// can't be placed
+	code, resp := generate(repo, teachers, `{
+		"class": "5", "days": ["Monday"], "slots": ["1", "2", "3"], "save": true,
+		"requirements": [{"subject": "Maths", "hours": 3}]
+	}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("GenerateTimetable = %d, want 422", code)
+	}
+	var unplaced map[string]int
+	json.Unmarshal(resp["unplaced"], &unplaced)
+	if unplaced["Maths"] != 1 {
+		t.Errorf("unplaced = %v, want 1 Maths period", unplaced)
+	}
+	if _, err := repo.Get(timetableID("5", "")); err != store.ErrNotFound {
+		t.Errorf("partial timetable saved: %v", err)
+	}
+}